@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/initialize"
+)
+
+func init() {
+	register(&command{
+		name:  "backup",
+		usage: "backup [-o <file>]",
+		run:   runBackup,
+	})
+	register(&command{
+		name:  "restore",
+		usage: "restore [-force] <file>",
+		run:   runRestore,
+	})
+}
+
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	output := fs.String("o", "", "write the backup to this file instead of the backup directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := initialize.ConnectDB(); err != nil {
+		return err
+	}
+	defer initialize.CloseDB()
+	path := *output
+	if path == "" {
+		cfg := global.GetConfig().Backup
+		var err error
+		if path, err = initialize.CreateBackup(initialize.DB, cfg.Dir, cfg.Retention); err != nil {
+			return err
+		}
+	} else if err := initialize.BackupSQLite(initialize.DB, path); err != nil {
+		return err
+	}
+	fmt.Println("database backed up to " + path)
+	return nil
+}
+
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	force := fs.Bool("force", false, "restore even if the backup fails validation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: restore [-force] <file>")
+	}
+	if global.GetConfig().Database.Dsn() != "" {
+		return initialize.ErrNotSQLite
+	}
+	old, err := initialize.RestoreSQLite(fs.Arg(0), initialize.SQLitePath, *force)
+	if err != nil {
+		return err
+	}
+	fmt.Println("database restored from " + fs.Arg(0))
+	if old != "" {
+		fmt.Println("the previous database was moved to " + old)
+	}
+	return nil
+}
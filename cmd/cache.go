@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/server"
+)
+
+func init() {
+	register(&command{
+		name:  "cache",
+		usage: "cache flush [-rate-limit]",
+		run:   runCache,
+	})
+}
+
+func runCache(args []string) error {
+	if len(args) == 0 || args[0] != "flush" {
+		return errors.New("usage: cache flush [-rate-limit]")
+	}
+	fs := flag.NewFlagSet("cache flush", flag.ContinueOnError)
+	rateLimit := fs.Bool("rate-limit", false, "also clear the rate limit records")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	closeAll, err := connect()
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+	// 未启用 Redis 时缓存只存在于各个服务进程的内存中，命令行无法清理
+	if !initialize.RedisEnabled {
+		fmt.Println("Redis is not enabled, there is nothing to flush")
+		return nil
+	}
+	deleted, err := server.FlushCache(context.Background(), *rateLimit)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d cache key(s) deleted\n", deleted)
+	return nil
+}
@@ -0,0 +1,71 @@
+// Package cmd 实现命令行子命令，例如 one-api -c config.yaml migrate status
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/9688101/hx-admin/initialize"
+)
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = make(map[string]*command)
+
+func register(c *command) {
+	commands[c.name] = c
+}
+
+// Run 执行 args[0] 对应的子命令，配置与日志需要事先初始化
+func Run(args []string) error {
+	c, ok := commands[args[0]]
+	if !ok {
+		PrintUsage()
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+	return c.run(args[1:])
+}
+
+// PrintUsage 打印所有子命令的用法
+func PrintUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  "+commands[name].usage)
+	}
+}
+
+func init() {
+	register(&command{
+		name:  "help",
+		usage: "help",
+		run: func(args []string) error {
+			PrintUsage()
+			return nil
+		},
+	})
+}
+
+// connect 连接数据库与 Redis，与启动服务时使用相同的配置，返回的函数用于关闭连接
+func connect() (func(), error) {
+	if err := initialize.ConnectDB(); err != nil {
+		return nil, err
+	}
+	if err := initialize.InitRedisClient(); err != nil {
+		_ = initialize.CloseDB()
+		return nil, err
+	}
+	return func() {
+		_ = initialize.CloseRedis()
+		_ = initialize.CloseDB()
+	}, nil
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"gorm.io/gorm"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/initialize"
+)
+
+func init() {
+	register(&command{
+		name:  "copy",
+		usage: "copy -to <dsn> [-from <dsn>] [-log] [-tables <t1,t2>] [-batch <n>] [-resume]",
+		run:   runCopy,
+	})
+}
+
+func runCopy(args []string) error {
+	fs := flag.NewFlagSet("copy", flag.ContinueOnError)
+	from := fs.String("from", "", "source dsn, defaults to the configured database")
+	to := fs.String("to", "", "target dsn, postgres://..., sqlite://<path> or a MySQL dsn")
+	logDB := fs.Bool("log", false, "copy the log database (LOG_SQL_DSN) instead of the main database")
+	tables := fs.String("tables", "", "comma separated tables to copy, defaults to all tables")
+	batch := fs.Int("batch", 1000, "number of rows inserted in one statement")
+	resume := fs.Bool("resume", false, "continue an interrupted copy instead of failing on non-empty tables")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *to == "" {
+		return errors.New("-to is required")
+	}
+
+	migrations := initialize.Migrations
+	if *logDB {
+		migrations = initialize.LogMigrations
+	}
+	var src *gorm.DB
+	if *from == "" {
+		if err := initialize.ConnectDB(); err != nil {
+			return err
+		}
+		defer initialize.CloseDB()
+		src = initialize.DB
+		if *logDB {
+			src = initialize.LOG_DB
+		}
+	} else {
+		var err error
+		if src, err = initialize.OpenDSN(*from); err != nil {
+			return err
+		}
+	}
+	dst, err := initialize.OpenDSN(*to)
+	if err != nil {
+		return err
+	}
+
+	status, err := initialize.GetMigrationStatus(src, migrations)
+	if err != nil {
+		return err
+	}
+	for _, s := range status {
+		if !s.Applied {
+			logger.SysWarnf("migration %d_%s is not applied to the source database, run `migrate up` on it first to copy all columns", s.Version, s.Name)
+		}
+	}
+	// 目标数据库的表结构由迁移建立，以适配对应数据库的类型
+	if _, err = initialize.MigrateUp(dst, migrations, 0); err != nil {
+		return err
+	}
+
+	opts := initialize.CopyOptions{
+		BatchSize: *batch,
+		Resume:    *resume,
+	}
+	if *tables != "" {
+		opts.Tables = strings.Split(*tables, ",")
+	}
+	results, err := initialize.CopyDatabase(src, dst, opts)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TABLE\tCOPIED\tSOURCE ROWS\tTARGET ROWS")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\n", r.Table, r.Copied, r.SourceRow, r.TargetRow)
+	}
+	_ = w.Flush()
+	return err
+}
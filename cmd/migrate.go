@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/initialize"
+)
+
+func init() {
+	register(&command{
+		name:  "migrate",
+		usage: "migrate status | up [-to <version>] | down [-steps <n>] [-log]",
+		run:   runMigrate,
+	})
+}
+
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate status | up [-to <version>] | down [-steps <n>] [-log]")
+	}
+	fs := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	to := fs.Int("to", 0, "migrate up to this version, 0 means the latest")
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	logDB := fs.Bool("log", false, "operate on the log database (LOG_SQL_DSN)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if err := initialize.ConnectDB(); err != nil {
+		return err
+	}
+	defer initialize.CloseDB()
+	separateLogDB := global.GetConfig().LogDatabase.Dsn() != ""
+
+	switch args[0] {
+	case "status":
+		if err := printMigrationStatus("database", initialize.DB, initialize.Migrations); err != nil {
+			return err
+		}
+		if separateLogDB {
+			return printMigrationStatus("log database", initialize.LOG_DB, initialize.LogMigrations)
+		}
+		return nil
+	case "up":
+		count, err := initialize.MigrateUp(initialize.DB, initialize.Migrations, *to)
+		fmt.Printf("database: %d migration(s) applied\n", count)
+		if err != nil || !separateLogDB {
+			return err
+		}
+		count, err = initialize.MigrateUp(initialize.LOG_DB, initialize.LogMigrations, *to)
+		fmt.Printf("log database: %d migration(s) applied\n", count)
+		return err
+	case "down":
+		db, migrations := initialize.DB, initialize.Migrations
+		if *logDB {
+			if !separateLogDB {
+				return errors.New("LOG_SQL_DSN is not set, logs are kept in the main database")
+			}
+			db, migrations = initialize.LOG_DB, initialize.LogMigrations
+		}
+		count, err := initialize.MigrateDown(db, migrations, *steps)
+		fmt.Printf("%d migration(s) rolled back\n", count)
+		return err
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+}
+
+func printMigrationStatus(title string, db *gorm.DB, migrations []initialize.Migration) error {
+	status, err := initialize.GetMigrationStatus(db, migrations)
+	if err != nil {
+		return err
+	}
+	fmt.Println(title + ":")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range status {
+		state := "pending"
+		appliedAt := ""
+		if s.Applied {
+			state = "applied"
+			appliedAt = time.Unix(s.AppliedAt, 0).Format(time.DateTime)
+		}
+		if s.Unknown {
+			state = "unknown"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/server"
+)
+
+const optionUsage = "option get [-secrets] [<key>] | set <key> <value>"
+
+func init() {
+	register(&command{
+		name:  "option",
+		usage: optionUsage,
+		run:   runOption,
+	})
+}
+
+func runOption(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: " + optionUsage)
+	}
+	fs := flag.NewFlagSet("option "+args[0], flag.ContinueOnError)
+	secrets := fs.Bool("secrets", false, "also print the options ending with Token or Secret")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	closeAll, err := connect()
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+	// 与服务启动时相同，先加载默认值与数据库中保存的值
+	server.InitOptionMap()
+
+	switch args[0] {
+	case "get":
+		if fs.NArg() > 1 {
+			return errors.New("usage: option get [-secrets] [<key>]")
+		}
+		options := server.ExportOptions(*secrets || fs.NArg() == 1)
+		if fs.NArg() == 1 {
+			value, ok := options[fs.Arg(0)]
+			if !ok {
+				return fmt.Errorf("option %s does not exist", fs.Arg(0))
+			}
+			fmt.Println(value)
+			return nil
+		}
+		keys := make([]string, 0, len(options))
+		for key := range options {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("%s=%s\n", key, options[key])
+		}
+		return nil
+	case "set":
+		if fs.NArg() != 2 {
+			return errors.New("usage: option set <key> <value>")
+		}
+		option := &model.Option{Key: fs.Arg(0), Value: fs.Arg(1)}
+		if err = server.ValidateOptions([]*model.Option{option}); err != nil {
+			return err
+		}
+		// 修改记录中的操作人为空，表示来自命令行；启用 Redis 时其他节点会立即收到变更
+		if err = server.UpdateOption(context.Background(), 0, option.Key, option.Value); err != nil {
+			return err
+		}
+		fmt.Printf("option %s updated\n", option.Key)
+		return nil
+	default:
+		return fmt.Errorf("unknown option command %q", args[0])
+	}
+}
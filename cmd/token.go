@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/9688101/hx-admin/server"
+	"github.com/9688101/hx-admin/utils"
+)
+
+const tokenUsage = "token revoke <id>... | revoke -user <username>"
+
+func init() {
+	register(&command{
+		name:  "token",
+		usage: tokenUsage,
+		run:   runToken,
+	})
+}
+
+func runToken(args []string) error {
+	if len(args) == 0 || args[0] != "revoke" {
+		return errors.New("usage: " + tokenUsage)
+	}
+	fs := flag.NewFlagSet("token revoke", flag.ContinueOnError)
+	username := fs.String("user", "", "revoke all tokens and the access token of this user")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if (*username == "") == (fs.NArg() == 0) {
+		return errors.New("usage: " + tokenUsage)
+	}
+	closeAll, err := connect()
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+
+	if *username != "" {
+		user, err := findUser(*username)
+		if err != nil {
+			return err
+		}
+		count, err := server.DeleteTokensByUserId(context.Background(), user.Id)
+		if err != nil {
+			return err
+		}
+		// 同时重新生成用于系统管理的 access token，使旧的失效
+		user.AccessToken = utils.GetUUID()
+		if err = server.UpdateUser(context.Background(), user, false); err != nil {
+			return err
+		}
+		fmt.Printf("%d token(s) and the access token of user %s revoked\n", count, *username)
+		return nil
+	}
+	for _, arg := range fs.Args() {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("invalid token id %q", arg)
+		}
+		token, err := server.GetTokenById(context.Background(), id)
+		if err != nil {
+			return fmt.Errorf("token %d does not exist", id)
+		}
+		if err = server.DeleteToken(context.Background(), token); err != nil {
+			return err
+		}
+		fmt.Printf("token %d (%s) of user %d revoked\n", token.Id, token.Name, token.UserId)
+	}
+	return nil
+}
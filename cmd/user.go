@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/server"
+	"github.com/9688101/hx-admin/utils"
+)
+
+const userUsage = "user list [-n <num>] | create [-password <p>] [-role <role>] [-display-name <name>] <username> | reset-password [-password <p>] <username> | set-role <username> <role> | disable <username> | enable <username>"
+
+var roleNames = map[string]int{
+	"user":  server.RoleCommonUser,
+	"admin": server.RoleAdminUser,
+	"root":  server.RoleRootUser,
+}
+
+func init() {
+	register(&command{
+		name:  "user",
+		usage: userUsage,
+		run:   runUser,
+	})
+}
+
+func runUser(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: " + userUsage)
+	}
+	fs := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	num := fs.Int("n", 50, "number of users to list")
+	password := fs.String("password", "", "password, a random one is generated and printed if empty")
+	role := fs.String("role", "user", "role of the new user: user, admin or root")
+	displayName := fs.String("display-name", "", "display name, defaults to the username")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	closeAll, err := connect()
+	if err != nil {
+		return err
+	}
+	defer closeAll()
+
+	switch args[0] {
+	case "list":
+		return listUsers(*num)
+	case "create":
+		if fs.NArg() != 1 {
+			return errors.New("usage: user create [-password <p>] [-role <role>] [-display-name <name>] <username>")
+		}
+		return createUser(fs.Arg(0), *password, *role, *displayName)
+	case "reset-password":
+		if fs.NArg() != 1 {
+			return errors.New("usage: user reset-password [-password <p>] <username>")
+		}
+		return resetPassword(fs.Arg(0), *password)
+	case "set-role":
+		if fs.NArg() != 2 {
+			return errors.New("usage: user set-role <username> <role>")
+		}
+		return setRole(fs.Arg(0), fs.Arg(1))
+	case "disable", "enable":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: user %s <username>", args[0])
+		}
+		return setUserStatus(fs.Arg(0), args[0] == "enable")
+	default:
+		return fmt.Errorf("unknown user command %q", args[0])
+	}
+}
+
+func listUsers(num int) error {
+	users, err := server.GetAllUsers(context.Background(), 0, num, "")
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tDISPLAY NAME\tROLE\tSTATUS\tEMAIL")
+	for _, u := range users {
+		status := "enabled"
+		if u.Status == server.UserStatusDisabled {
+			status = "disabled"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", u.Id, u.Username, u.DisplayName, roleName(u.Role), status, u.Email)
+	}
+	return w.Flush()
+}
+
+func createUser(username string, password string, role string, displayName string) error {
+	roleValue, err := parseRole(role)
+	if err != nil {
+		return err
+	}
+	if server.IsUsernameAlreadyTaken(context.Background(), username) {
+		return fmt.Errorf("user %s already exists", username)
+	}
+	generated := password == ""
+	if generated {
+		password = utils.GetRandomString(16)
+	}
+	if displayName == "" {
+		displayName = username
+	}
+	user := &model.User{
+		Username:    username,
+		Password:    password,
+		DisplayName: displayName,
+	}
+	if err = utils.Validate.Struct(user); err != nil {
+		return err
+	}
+	if err = server.InsertUser(context.Background(), user, 0); err != nil {
+		return err
+	}
+	if roleValue != server.RoleCommonUser {
+		user.Role = roleValue
+		if err = server.UpdateUser(context.Background(), user, false); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("user %s created with id %d\n", username, user.Id)
+	if generated {
+		fmt.Println("password: " + password)
+	}
+	return nil
+}
+
+func resetPassword(username string, password string) error {
+	user, err := findUser(username)
+	if err != nil {
+		return err
+	}
+	generated := password == ""
+	if generated {
+		password = utils.GetRandomString(16)
+	}
+	if err = utils.Validate.Var(password, "min=8,max=20"); err != nil {
+		return fmt.Errorf("password must be 8 to 20 characters: %w", err)
+	}
+	user.Password = password
+	if err = server.UpdateUser(context.Background(), user, true); err != nil {
+		return err
+	}
+	fmt.Printf("password of user %s has been reset\n", username)
+	if generated {
+		fmt.Println("password: " + password)
+	}
+	return nil
+}
+
+func setRole(username string, role string) error {
+	roleValue, err := parseRole(role)
+	if err != nil {
+		return err
+	}
+	user, err := findUser(username)
+	if err != nil {
+		return err
+	}
+	if user.Role == server.RoleRootUser && roleValue != server.RoleRootUser {
+		var roots int64
+		err = initialize.DB.Model(model.NewUser()).Where("role = ? AND status = ?", server.RoleRootUser, server.UserStatusEnabled).Count(&roots).Error
+		if err != nil {
+			return err
+		}
+		if roots <= 1 {
+			return errors.New("至少需要保留一个超级管理员")
+		}
+	}
+	user.Role = roleValue
+	if err = server.UpdateUser(context.Background(), user, false); err != nil {
+		return err
+	}
+	fmt.Printf("role of user %s set to %s\n", username, roleName(roleValue))
+	return nil
+}
+
+func setUserStatus(username string, enabled bool) error {
+	user, err := findUser(username)
+	if err != nil {
+		return err
+	}
+	if !enabled && user.Role == server.RoleRootUser {
+		return errors.New("无法禁用超级管理员用户")
+	}
+	user.Status = server.UserStatusDisabled
+	if enabled {
+		user.Status = server.UserStatusEnabled
+	}
+	if err = server.UpdateUser(context.Background(), user, false); err != nil {
+		return err
+	}
+	server.InvalidateUserCache(context.Background(), user.Id)
+	if enabled {
+		fmt.Printf("user %s enabled\n", username)
+	} else {
+		fmt.Printf("user %s disabled\n", username)
+	}
+	return nil
+}
+
+func findUser(username string) (*model.User, error) {
+	user := model.NewUserByUsername(username)
+	if err := server.FillUserByUsername(context.Background(), user); err != nil || user.Id == 0 {
+		return nil, fmt.Errorf("user %s does not exist", username)
+	}
+	return user, nil
+}
+
+func parseRole(role string) (int, error) {
+	if value, ok := roleNames[role]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(role)
+	if err != nil || (value != server.RoleCommonUser && value != server.RoleAdminUser && value != server.RoleRootUser) {
+		return 0, fmt.Errorf("invalid role %q, must be user, admin or root", role)
+	}
+	return value, nil
+}
+
+func roleName(role int) string {
+	for name, value := range roleNames {
+		if value == role {
+			return name
+		}
+	}
+	return strconv.Itoa(role)
+}
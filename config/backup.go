@@ -0,0 +1,8 @@
+package config
+
+// Backup SQLite 数据库的定时备份配置，使用 MySQL 或 PostgreSQL 时不生效
+type Backup struct {
+	Dir       string `mapstructure:"dir" json:"dir" yaml:"dir"`                   // 备份文件保存的目录
+	Interval  int    `mapstructure:"interval" json:"interval" yaml:"interval"`    // 定时备份的间隔（分钟），0 表示不开启
+	Retention int    `mapstructure:"retention" json:"retention" yaml:"retention"` // 最多保留的备份文件个数，0 表示不清理
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"net/url"
+	"regexp"
+)
+
+// Config 从配置文件、环境变量和命令行参数合并得到的启动配置
+// 优先级: 命令行 > 环境变量 > 配置文件 > 默认值
+type Config struct {
+	System      System    `mapstructure:"system" json:"system" yaml:"system"`
+	Database    DB        `mapstructure:"database" json:"database" yaml:"database"`
+	LogDatabase DB        `mapstructure:"log-database" json:"log-database" yaml:"log-database"` // 为 logs 表使用的独立数据库，为空时与 database 相同
+	Redis       Redis     `mapstructure:"redis" json:"redis" yaml:"redis"`
+	RateLimit   RateLimit `mapstructure:"rate-limit" json:"rate-limit" yaml:"rate-limit"`
+	SMTP        SMTP      `mapstructure:"smtp" json:"smtp" yaml:"smtp"`
+	Tracing     Tracing   `mapstructure:"tracing" json:"tracing" yaml:"tracing"`
+	Backup      Backup    `mapstructure:"backup" json:"backup" yaml:"backup"`
+	MailQueue   MailQueue `mapstructure:"mail-queue" json:"mail-queue" yaml:"mail-queue"`
+	Webhook     Webhook   `mapstructure:"webhook" json:"webhook" yaml:"webhook"`
+}
+
+// redacted 与 url.URL.Redacted 使用的占位符保持一致
+const redacted = "xxxxx"
+
+// mysqlPasswordPattern matches the password in a DSN like user:password@tcp(host:port)/dbname
+var mysqlPasswordPattern = regexp.MustCompile(`^([^:@/]*):([^@]*)@`)
+
+// Redacted returns a copy of the config with passwords, tokens and secrets masked, it is safe to print.
+func (c Config) Redacted() Config {
+	c.System.SessionSecret = redactString(c.System.SessionSecret)
+	c.Database = c.Database.redacted()
+	c.LogDatabase = c.LogDatabase.redacted()
+	c.Redis.Password = redactString(c.Redis.Password)
+	c.Redis.ConnString = redactDSN(c.Redis.ConnString)
+	c.SMTP.Token = redactString(c.SMTP.Token)
+	return c
+}
+
+func (d DB) redacted() DB {
+	d.Password = redactString(d.Password)
+	d.DSN = redactDSN(d.DSN)
+	return d
+}
+
+func redactString(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func redactDSN(dsn string) string {
+	if dsn == "" {
+		return ""
+	}
+	if u, err := url.Parse(dsn); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return mysqlPasswordPattern.ReplaceAllString(dsn, "${1}:"+redacted+"@")
+}
@@ -0,0 +1,11 @@
+package config
+
+// MailQueue 发件队列的配置，邮件先保存到数据库，再由后台任务发送并在失败时重试
+type MailQueue struct {
+	PollInterval    int `mapstructure:"poll-interval" json:"poll-interval" yaml:"poll-interval"`          // 检查待发送邮件的间隔（秒）
+	MaxAttempts     int `mapstructure:"max-attempts" json:"max-attempts" yaml:"max-attempts"`             // 最多发送的次数，仍然失败的邮件不再重试
+	RetryBackoff    int `mapstructure:"retry-backoff" json:"retry-backoff" yaml:"retry-backoff"`          // 第一次重试的间隔（秒），之后每次翻倍，最长一小时
+	RecipientLimit  int `mapstructure:"recipient-limit" json:"recipient-limit" yaml:"recipient-limit"`    // 每个收件人在 RecipientWindow 内最多的邮件数，0 表示不限制
+	RecipientWindow int `mapstructure:"recipient-window" json:"recipient-window" yaml:"recipient-window"` // 收件人限流的时间窗口（秒）
+	Retention       int `mapstructure:"retention" json:"retention" yaml:"retention"`                      // 已发送与发送失败的邮件保留的天数，0 表示不清理
+}
@@ -1,9 +1,9 @@
 package config
 
-// type Mysql struct {
-// 	GeneralDB `yaml:",inline" mapstructure:",squash"`
-// }
+type Mysql struct {
+	GeneralDB `yaml:",inline" mapstructure:",squash"`
+}
 
-// func (m *Mysql) Dsn() string {
-// 	return m.Username + ":" + m.Password + "@tcp(" + m.Path + ":" + m.Port + ")/" + m.Dbname + "?" + m.Config
-// }
+func (m *Mysql) Dsn() string {
+	return m.Username + ":" + m.Password + "@tcp(" + m.Path + ":" + m.Port + ")/" + m.Dbname + "?" + m.Config
+}
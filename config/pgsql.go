@@ -1,17 +1,22 @@
 package config
 
-// type Pgsql struct {
-// 	GeneralDB `yaml:",inline" mapstructure:",squash"`
-// }
+import (
+	"net"
+	"net/url"
+)
 
-// // Dsn 基于配置文件获取 dsn
-// // Author [SliverHorn](https://github.com/SliverHorn)
-// func (p *Pgsql) Dsn() string {
-// 	return "host=" + p.Path + " user=" + p.Username + " password=" + p.Password + " dbname=" + p.Dbname + " port=" + p.Port + " " + p.Config
-// }
+type Pgsql struct {
+	GeneralDB `yaml:",inline" mapstructure:",squash"`
+}
 
-// // LinkDsn 根据 dbname 生成 dsn
-// // Author [SliverHorn](https://github.com/SliverHorn)
-// func (p *Pgsql) LinkDsn(dbname string) string {
-// 	return "host=" + p.Path + " user=" + p.Username + " password=" + p.Password + " dbname=" + dbname + " port=" + p.Port + " " + p.Config
-// }
+// Dsn 基于配置文件获取 dsn，使用 postgres:// 的形式以便按前缀识别数据库类型
+func (p *Pgsql) Dsn() string {
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(p.Username, p.Password),
+		Host:     net.JoinHostPort(p.Path, p.Port),
+		Path:     "/" + p.Dbname,
+		RawQuery: p.Config,
+	}
+	return dsn.String()
+}
@@ -0,0 +1,15 @@
+package config
+
+// RateLimit 速率限制配置，Num 为时间窗口内的最大请求数，Duration 为时间窗口（秒）
+type RateLimit struct {
+	GlobalApiNum      int   `mapstructure:"global-api-num" json:"global-api-num" yaml:"global-api-num"`
+	GlobalApiDuration int64 `mapstructure:"global-api-duration" json:"global-api-duration" yaml:"global-api-duration"`
+	GlobalWebNum      int   `mapstructure:"global-web-num" json:"global-web-num" yaml:"global-web-num"`
+	GlobalWebDuration int64 `mapstructure:"global-web-duration" json:"global-web-duration" yaml:"global-web-duration"`
+	UploadNum         int   `mapstructure:"upload-num" json:"upload-num" yaml:"upload-num"`
+	UploadDuration    int64 `mapstructure:"upload-duration" json:"upload-duration" yaml:"upload-duration"`
+	DownloadNum       int   `mapstructure:"download-num" json:"download-num" yaml:"download-num"`
+	DownloadDuration  int64 `mapstructure:"download-duration" json:"download-duration" yaml:"download-duration"`
+	CriticalNum       int   `mapstructure:"critical-num" json:"critical-num" yaml:"critical-num"`
+	CriticalDuration  int64 `mapstructure:"critical-duration" json:"critical-duration" yaml:"critical-duration"`
+}
@@ -1,90 +1,17 @@
 package config
 
-// import (
-// 	"context"
-// 	"os"
-// 	"strings"
-// 	"time"
-
-// 	"github.com/9688101/hx-admin/core/logger"
-// 	"github.com/go-redis/redis/v8"
-// )
-
-// type Redis struct {
-// 	Name         string   `mapstructure:"name" json:"name" yaml:"name"`                         // 代表当前实例的名字
-// 	Addr         string   `mapstructure:"addr" json:"addr" yaml:"addr"`                         // 服务器地址:端口
-// 	Password     string   `mapstructure:"password" json:"password" yaml:"password"`             // 密码
-// 	DB           int      `mapstructure:"db" json:"db" yaml:"db"`                               // 单实例模式下redis的哪个数据库
-// 	UseCluster   bool     `mapstructure:"useCluster" json:"useCluster" yaml:"useCluster"`       // 是否使用集群模式
-// 	ClusterAddrs []string `mapstructure:"clusterAddrs" json:"clusterAddrs" yaml:"clusterAddrs"` // 集群模式下的节点地址列表
-// }
-
-// var RDB redis.Cmdable
-// var RedisEnabled = true
-
-// // InitRedisClient This function is called after init()
-// func InitRedisClient() (err error) {
-// 	if os.Getenv("REDIS_CONN_STRING") == "" {
-// 		RedisEnabled = false
-// 		logger.SysLog("REDIS_CONN_STRING not set, Redis is not enabled")
-// 		return nil
-// 	}
-// 	if os.Getenv("SYNC_FREQUENCY") == "" {
-// 		RedisEnabled = false
-// 		logger.SysLog("SYNC_FREQUENCY not set, Redis is disabled")
-// 		return nil
-// 	}
-// 	redisConnString := os.Getenv("REDIS_CONN_STRING")
-// 	if os.Getenv("REDIS_MASTER_NAME") == "" {
-// 		logger.SysLog("Redis is enabled")
-// 		opt, err := redis.ParseURL(redisConnString)
-// 		if err != nil {
-// 			logger.FatalLog("failed to parse Redis connection string: " + err.Error())
-// 		}
-// 		RDB = redis.NewClient(opt)
-// 	} else {
-// 		// cluster mode
-// 		logger.SysLog("Redis cluster mode enabled")
-// 		RDB = redis.NewUniversalClient(&redis.UniversalOptions{
-// 			Addrs:      strings.Split(redisConnString, ","),
-// 			Password:   os.Getenv("REDIS_PASSWORD"),
-// 			MasterName: os.Getenv("REDIS_MASTER_NAME"),
-// 		})
-// 	}
-// 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-// 	defer cancel()
-
-// 	_, err = RDB.Ping(ctx).Result()
-// 	if err != nil {
-// 		logger.FatalLog("Redis ping test failed: " + err.Error())
-// 	}
-// 	return err
-// }
-
-// func ParseRedisOption() *redis.Options {
-// 	opt, err := redis.ParseURL(os.Getenv("REDIS_CONN_STRING"))
-// 	if err != nil {
-// 		logger.FatalLog("failed to parse Redis connection string: " + err.Error())
-// 	}
-// 	return opt
-// }
-
-// func RedisSet(key string, value string, expiration time.Duration) error {
-// 	ctx := context.Background()
-// 	return RDB.Set(ctx, key, value, expiration).Err()
-// }
-
-// func RedisGet(key string) (string, error) {
-// 	ctx := context.Background()
-// 	return RDB.Get(ctx, key).Result()
-// }
-
-// func RedisDel(key string) error {
-// 	ctx := context.Background()
-// 	return RDB.Del(ctx, key).Err()
-// }
-
-// func RedisDecrease(key string, value int64) error {
-// 	ctx := context.Background()
-// 	return RDB.DecrBy(ctx, key, value).Err()
-// }
+// Redis ConnString 不为空时沿用 REDIS_CONN_STRING 的语义，否则使用 Addr 或 ClusterAddrs
+type Redis struct {
+	ConnString   string   `mapstructure:"conn-string" json:"conn-string" yaml:"conn-string"`    // 连接字符串，对应 REDIS_CONN_STRING
+	Name         string   `mapstructure:"name" json:"name" yaml:"name"`                         // 代表当前实例的名字
+	Addr         string   `mapstructure:"addr" json:"addr" yaml:"addr"`                         // 服务器地址:端口
+	Password     string   `mapstructure:"password" json:"password" yaml:"password"`             // 密码
+	DB           int      `mapstructure:"db" json:"db" yaml:"db"`                               // 单实例模式下redis的哪个数据库
+	UseCluster   bool     `mapstructure:"useCluster" json:"useCluster" yaml:"useCluster"`       // 是否使用集群模式
+	ClusterAddrs []string `mapstructure:"clusterAddrs" json:"clusterAddrs" yaml:"clusterAddrs"` // 集群模式下的节点地址列表
+	MasterName   string   `mapstructure:"master-name" json:"master-name" yaml:"master-name"`    // 哨兵模式下主节点的名称
+}
+
+func (r Redis) Enabled() bool {
+	return r.ConnString != "" || r.Addr != "" || (r.UseCluster && len(r.ClusterAddrs) > 0)
+}
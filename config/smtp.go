@@ -0,0 +1,18 @@
+package config
+
+// SMTP 作为邮件配置的初始值，管理员在系统设置中保存的值优先
+type SMTP struct {
+	Server  string `mapstructure:"server" json:"server" yaml:"server"`    // SMTP服务器地址
+	Port    int    `mapstructure:"port" json:"port" yaml:"port"`          // SMTP端口号
+	Account string `mapstructure:"account" json:"account" yaml:"account"` // SMTP账号
+	From    string `mapstructure:"from" json:"from" yaml:"from"`          // 发件人邮箱
+	Token   string `mapstructure:"token" json:"token" yaml:"token"`       // SMTP授权令牌
+
+	Security           string `mapstructure:"security" json:"security" yaml:"security"`                                     // 加密方式：auto、tls、starttls、none
+	InsecureSkipVerify bool   `mapstructure:"insecure-skip-verify" json:"insecure-skip-verify" yaml:"insecure-skip-verify"` // 跳过证书校验，仅用于自签名证书
+
+	// DKIM 签名，domain 为空时不签名
+	DKIMDomain         string `mapstructure:"dkim-domain" json:"dkim-domain" yaml:"dkim-domain"`
+	DKIMSelector       string `mapstructure:"dkim-selector" json:"dkim-selector" yaml:"dkim-selector"`
+	DKIMPrivateKeyFile string `mapstructure:"dkim-private-key-file" json:"dkim-private-key-file" yaml:"dkim-private-key-file"` // PEM 格式的 RSA 或 Ed25519 私钥
+}
@@ -1,13 +1,14 @@
 package config
 
-// import (
-// 	"path/filepath"
-// )
+import (
+	"fmt"
+)
 
-// type Sqlite struct {
-// 	GeneralDB `yaml:",inline" mapstructure:",squash"`
-// }
+type Sqlite struct {
+	Path        string
+	BusyTimeout int
+}
 
-// func (s *Sqlite) Dsn() string {
-// 	return filepath.Join(s.Path, s.Dbname+".db")
-// }
+func (s *Sqlite) Dsn() string {
+	return fmt.Sprintf("%s?_busy_timeout=%d", s.Path, s.BusyTimeout)
+}
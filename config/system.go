@@ -0,0 +1,29 @@
+package config
+
+type System struct {
+	Port                      int      `mapstructure:"port" json:"port" yaml:"port"`                                                                   // 监听端口
+	LogDir                    string   `mapstructure:"log-dir" json:"log-dir" yaml:"log-dir"`                                                          // 日志目录
+	SessionSecret             string   `mapstructure:"session-secret" json:"session-secret" yaml:"session-secret"`                                     // 会话密钥
+	NodeType                  string   `mapstructure:"node-type" json:"node-type" yaml:"node-type"`                                                    // master 或 slave
+	SyncFrequency             int      `mapstructure:"sync-frequency" json:"sync-frequency" yaml:"sync-frequency"`                                     // 与数据库同步的频率（秒），0 表示未设置
+	MemoryCacheEnabled        bool     `mapstructure:"memory-cache-enabled" json:"memory-cache-enabled" yaml:"memory-cache-enabled"`                   // 内存缓存开关
+	Debug                     bool     `mapstructure:"debug" json:"debug" yaml:"debug"`                                                                // 调试模式开关
+	DebugSQL                  bool     `mapstructure:"debug-sql" json:"debug-sql" yaml:"debug-sql"`                                                    // SQL调试开关
+	CorsAllowedOrigins        []string `mapstructure:"cors-allowed-origins" json:"cors-allowed-origins" yaml:"cors-allowed-origins"`                   // 允许携带凭据跨域访问的来源，FrontendBaseUrl 的来源总是允许，为空时不允许其他来源
+	LogLevel                  string   `mapstructure:"log-level" json:"log-level" yaml:"log-level"`                                                    // 最低日志级别：debug、info、warn、error
+	LogFormat                 string   `mapstructure:"log-format" json:"log-format" yaml:"log-format"`                                                 // 日志格式：text 或 json
+	LogMaxSize                int      `mapstructure:"log-max-size" json:"log-max-size" yaml:"log-max-size"`                                           // 单个日志文件的最大大小（MB）
+	LogMaxAge                 int      `mapstructure:"log-max-age" json:"log-max-age" yaml:"log-max-age"`                                              // 轮转后的日志文件保留天数，0 表示不按时间清理
+	LogMaxBackups             int      `mapstructure:"log-max-backups" json:"log-max-backups" yaml:"log-max-backups"`                                  // 轮转后的日志文件最多保留个数，0 表示不按个数清理
+	LogCompress               bool     `mapstructure:"log-compress" json:"log-compress" yaml:"log-compress"`                                           // 是否压缩轮转后的日志文件
+	ShutdownTimeout           int      `mapstructure:"shutdown-timeout" json:"shutdown-timeout" yaml:"shutdown-timeout"`                               // 退出时等待请求与后台任务结束的最长时间（秒）
+	ShutdownDelay             int      `mapstructure:"shutdown-delay" json:"shutdown-delay" yaml:"shutdown-delay"`                                     // 收到退出信号后，标记为未就绪到停止接收请求之间的等待时间（秒）
+	WatchConfig               bool     `mapstructure:"watch-config" json:"watch-config" yaml:"watch-config"`                                           // 配置文件修改后自动重新加载
+	FrontendBaseUrl           string   `mapstructure:"frontend-base-url" json:"frontend-base-url" yaml:"frontend-base-url"`                            // 从节点的前端重定向地址
+	Theme                     string   `mapstructure:"theme" json:"theme" yaml:"theme"`                                                                // 系统主题
+	OnlyOneLogFile            bool     `mapstructure:"only-one-log-file" json:"only-one-log-file" yaml:"only-one-log-file"`                            // 单日志文件模式
+	RelayProxy                string   `mapstructure:"relay-proxy" json:"relay-proxy" yaml:"relay-proxy"`                                              // 请求转发代理
+	RelayTimeout              int      `mapstructure:"relay-timeout" json:"relay-timeout" yaml:"relay-timeout"`                                        // 转发超时时间（秒）
+	UserContentRequestProxy   string   `mapstructure:"user-content-request-proxy" json:"user-content-request-proxy" yaml:"user-content-request-proxy"` // 用户内容请求代理
+	UserContentRequestTimeout int      `mapstructure:"user-content-request-timeout" json:"user-content-request-timeout" yaml:"user-content-request-timeout"`
+}
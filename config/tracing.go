@@ -0,0 +1,11 @@
+package config
+
+// Tracing OpenTelemetry 链路追踪配置
+type Tracing struct {
+	Enabled     bool    `mapstructure:"enabled" json:"enabled" yaml:"enabled"`                // 是否开启链路追踪
+	Exporter    string  `mapstructure:"exporter" json:"exporter" yaml:"exporter"`             // otlp 或 file
+	Endpoint    string  `mapstructure:"endpoint" json:"endpoint" yaml:"endpoint"`             // OTLP/HTTP 地址，例如 http://localhost:4318，为空时使用 OTEL_EXPORTER_OTLP_* 环境变量
+	FilePath    string  `mapstructure:"file-path" json:"file-path" yaml:"file-path"`          // file 导出器写入的文件，为空时写入日志目录下的 traces.json
+	SampleRatio float64 `mapstructure:"sample-ratio" json:"sample-ratio" yaml:"sample-ratio"` // 采样比例，0 到 1
+	ServiceName string  `mapstructure:"service-name" json:"service-name" yaml:"service-name"` // 上报的服务名
+}
@@ -0,0 +1,10 @@
+package config
+
+// Webhook 领域事件 webhook 的投递配置，事件先保存到数据库，再由后台任务投递并在失败时重试
+type Webhook struct {
+	PollInterval int `mapstructure:"poll-interval" json:"poll-interval" yaml:"poll-interval"` // 检查待投递事件的间隔（秒）
+	MaxAttempts  int `mapstructure:"max-attempts" json:"max-attempts" yaml:"max-attempts"`    // 每次投递最多尝试的次数，仍然失败的投递不再重试
+	RetryBackoff int `mapstructure:"retry-backoff" json:"retry-backoff" yaml:"retry-backoff"` // 第一次重试的间隔（秒），之后每次翻倍，最长一小时
+	Timeout      int `mapstructure:"timeout" json:"timeout" yaml:"timeout"`                   // 每次请求的超时时间（秒）
+	Retention    int `mapstructure:"retention" json:"retention" yaml:"retention"`             // 事件与投递记录保留的天数，0 表示不清理
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -22,7 +23,7 @@ func GetAllUsers(c *gin.Context) {
 	}
 
 	order := c.DefaultQuery("order", "")
-	users, err := server.GetAllUsers(p*global.ItemsPerPage, global.ItemsPerPage, order)
+	users, err := server.GetAllUsers(c.Request.Context(), p*global.ItemsPerPage, global.ItemsPerPage, order)
 
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
@@ -41,7 +42,7 @@ func GetAllUsers(c *gin.Context) {
 
 func SearchUsers(c *gin.Context) {
 	keyword := c.Query("keyword")
-	users, err := server.SearchUsers(keyword)
+	users, err := server.SearchUsers(c.Request.Context(), keyword)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -66,7 +67,7 @@ func GetUser(c *gin.Context) {
 		})
 		return
 	}
-	u, err := server.GetUserById(id, false)
+	u, err := server.GetUserById(c.Request.Context(), id, false)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -134,7 +135,7 @@ func UpdateUser(c *gin.Context) {
 		})
 		return
 	}
-	originUser, err := server.GetUserById(u.Id, false)
+	originUser, err := server.GetUserById(c.Request.Context(), u.Id, false)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -161,7 +162,7 @@ func UpdateUser(c *gin.Context) {
 		u.Password = "" // rollback to what it should be
 	}
 	updatePassword := u.Password != ""
-	if err := server.UpdateUser(u, updatePassword); err != nil {
+	if err := server.UpdateUser(c.Request.Context(), u, updatePassword); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": err.Error(),
@@ -187,7 +188,7 @@ func DeleteUser(c *gin.Context) {
 		})
 		return
 	}
-	originUser, err := server.GetUserById(id, false)
+	originUser, err := server.GetUserById(c.Request.Context(), id, false)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -203,7 +204,7 @@ func DeleteUser(c *gin.Context) {
 		})
 		return
 	}
-	err = server.DeleteUserById(id)
+	err = server.DeleteUserById(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
@@ -227,7 +228,7 @@ func ManageUser(c *gin.Context) {
 	}
 	u := model.NewUserByUsername(req.Username)
 	// Fill attributes
-	server.FillUserByUsername(u)
+	server.FillUserByUsername(c.Request.Context(), u)
 	if u.Id == 0 {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -263,7 +264,30 @@ func ManageUser(c *gin.Context) {
 			})
 			return
 		}
-		if err := server.DeleteUser(u); err != nil {
+		if err := server.DeleteUser(c.Request.Context(), u); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "ban":
+		if u.Role == server.RoleRootUser {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": "无法封禁超级管理员用户",
+			})
+			return
+		}
+		if err := server.BanUser(c.Request.Context(), u.Id, req.Reason, req.Duration, c.GetInt(ctxkey.Id)); err != nil {
+			c.JSON(http.StatusOK, gin.H{
+				"success": false,
+				"message": err.Error(),
+			})
+			return
+		}
+	case "unban":
+		if err := server.UnbanUser(c.Request.Context(), u.Id); err != nil {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
 				"message": err.Error(),
@@ -304,13 +328,15 @@ func ManageUser(c *gin.Context) {
 		u.Role = server.RoleCommonUser
 	}
 
-	if err := server.UpdateUser(u, false); err != nil {
+	if err := server.UpdateUser(c.Request.Context(), u, false); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": err.Error(),
 		})
 		return
 	}
+	server.Notify(c.Request.Context(), model.NotificationEventAdminAction, "管理员操作",
+		fmt.Sprintf("管理员 %s 对用户 %s 执行了 %s 操作", c.GetString("username"), u.Username, req.Action))
 	clearUser := model.User{
 		Role:   u.Role,
 		Status: u.Status,
@@ -322,3 +348,21 @@ func ManageUser(c *gin.Context) {
 	})
 	return
 }
+
+// GetUserBans 获取当前被禁用或被封禁的用户及封禁原因与过期时间
+func GetUserBans(c *gin.Context) {
+	users, err := server.GetBannedUsers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    users,
+	})
+	return
+}
@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/core/i18n"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/server"
+	"github.com/9688101/hx-admin/utils/ctxkey"
+)
+
+// GetAnnouncements 分页获取所有公告，包括未生效与已结束的公告
+func GetAnnouncements(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	announcements, err := server.GetAnnouncements(c.Request.Context(), p*global.ItemsPerPage, global.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    announcements,
+	})
+	return
+}
+
+// GetAnnouncementStats 获取公告的已读与已关闭人数
+func GetAnnouncementStats(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	stats, err := server.GetAnnouncementStats(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    stats,
+	})
+	return
+}
+
+// AddAnnouncement 添加公告，响应中的公告内容为清理后的内容
+func AddAnnouncement(c *gin.Context) {
+	announcement := model.NewAnnouncement()
+	if err := json.NewDecoder(c.Request.Body).Decode(announcement); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	if err := server.CreateAnnouncement(c.Request.Context(), announcement, c.GetInt(ctxkey.Id)); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    announcement,
+	})
+	return
+}
+
+// UpdateAnnouncement 修改公告
+func UpdateAnnouncement(c *gin.Context) {
+	announcement := model.NewAnnouncement()
+	if err := json.NewDecoder(c.Request.Body).Decode(announcement); err != nil || announcement.Id == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	if err := server.UpdateAnnouncement(c.Request.Context(), announcement); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    announcement,
+	})
+	return
+}
+
+// DeleteAnnouncement 删除公告及用户的阅读记录
+func DeleteAnnouncement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = server.DeleteAnnouncement(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// GetUserAnnouncements 获取面向当前用户的生效中的公告，dismissed=true 时包括已关闭的公告
+func GetUserAnnouncements(c *gin.Context) {
+	announcements, err := server.GetUserAnnouncements(c.Request.Context(), c.GetInt(ctxkey.Id), c.Query("dismissed") == "true")
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    announcements,
+	})
+	return
+}
+
+// ReadAnnouncement 将公告标记为已读
+func ReadAnnouncement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = server.ReadAnnouncement(c.Request.Context(), c.GetInt(ctxkey.Id), id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// DismissAnnouncement 关闭公告，关闭后不再出现在公告列表中
+func DismissAnnouncement(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = server.DismissAnnouncement(c.Request.Context(), c.GetInt(ctxkey.Id), id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
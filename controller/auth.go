@@ -2,6 +2,7 @@ package controller
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	"github.com/9688101/hx-admin/core/i18n"
@@ -9,6 +10,7 @@ import (
 	"github.com/9688101/hx-admin/model"
 	"github.com/9688101/hx-admin/server"
 	"github.com/9688101/hx-admin/utils"
+	"github.com/9688101/hx-admin/utils/ctxkey"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
@@ -48,7 +50,7 @@ func Login(c *gin.Context) {
 		Username: username,
 		Password: password,
 	}
-	err = server.ValidateAndFill(&user)
+	err = server.ValidateAndFill(c.Request.Context(), &user)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": err.Error(),
@@ -74,6 +76,12 @@ func SetupLogin(user *model.User, c *gin.Context) {
 		})
 		return
 	}
+	c.Set(ctxkey.LoginSucceeded, true)
+	if user.Role >= server.RoleAdminUser {
+		server.Notify(c.Request.Context(), model.NotificationEventLoginAlert, "管理员登录",
+			fmt.Sprintf("管理员 %s 已登录，IP：%s，设备：%s", user.Username, c.ClientIP(), c.Request.UserAgent()))
+		server.QueueLoginAlertEmail(c.Request.Context(), user, i18n.GetLang(c), c.ClientIP(), c.Request.UserAgent())
+	}
 	cleanUser := model.User{
 		Id:          user.Id,
 		Username:    user.Username,
@@ -145,7 +153,7 @@ func Register(c *gin.Context) {
 			})
 			return
 		}
-		if !utils.VerifyCodeWithKey(user.Email, user.VerificationCode, utils.EmailVerificationPurpose) {
+		if !server.VerifyCode(c.Request.Context(), user.Email, user.VerificationCode, utils.EmailVerificationPurpose) {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
 				"message": "验证码错误或已过期",
@@ -154,7 +162,7 @@ func Register(c *gin.Context) {
 		}
 	}
 	affCode := user.AffCode // this code is the inviter's code, not the user's own code
-	inviterId, _ := server.GetUserIdByAffCode(affCode)
+	inviterId, _ := server.GetUserIdByAffCode(c.Request.Context(), affCode)
 	cleanUser := model.User{
 		Username:    user.Username,
 		Password:    user.Password,
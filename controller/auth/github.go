@@ -2,6 +2,7 @@ package auth
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/9688101/hx-admin/controller"
 	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/core/tracing"
 	"github.com/9688101/hx-admin/global"
 	"github.com/9688101/hx-admin/model"
 	"github.com/9688101/hx-admin/server"
@@ -26,7 +28,7 @@ type GitHubOAuthResponse struct {
 	TokenType   string `json:"token_type"`
 }
 
-func getGitHubUserInfoByCode(code string) (*model.GitHubUser, error) {
+func getGitHubUserInfoByCode(ctx context.Context, code string) (*model.GitHubUser, error) {
 	if code == "" {
 		return nil, errors.New("无效的参数")
 	}
@@ -35,14 +37,15 @@ func getGitHubUserInfoByCode(code string) (*model.GitHubUser, error) {
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest("POST", "https://github.com/login/oauth/access_token", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://github.com/login/oauth/access_token", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 	client := http.Client{
-		Timeout: 5 * time.Second,
+		Transport: tracing.NewTransport(nil),
+		Timeout:   5 * time.Second,
 	}
 	res, err := client.Do(req)
 	if err != nil {
@@ -55,7 +58,7 @@ func getGitHubUserInfoByCode(code string) (*model.GitHubUser, error) {
 	if err != nil {
 		return nil, err
 	}
-	req, err = http.NewRequest("GET", "https://api.github.com/user", nil)
+	req, err = http.NewRequestWithContext(ctx, "GET", "https://api.github.com/user", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -102,7 +105,7 @@ func GitHubOAuth(c *gin.Context) {
 		return
 	}
 	code := c.Query("code")
-	githubUser, err := getGitHubUserInfoByCode(code)
+	githubUser, err := getGitHubUserInfoByCode(c.Request.Context(), code)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -111,8 +114,8 @@ func GitHubOAuth(c *gin.Context) {
 		return
 	}
 	u := model.NewUserByGitHubId(githubUser.Login)
-	if server.IsGitHubIdAlreadyTaken(u.GitHubId) {
-		err := server.FillUserByGitHubId(u)
+	if server.IsGitHubIdAlreadyTaken(c.Request.Context(), u.GitHubId) {
+		err := server.FillUserByGitHubId(c.Request.Context(), u)
 		if err != nil {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -122,7 +125,7 @@ func GitHubOAuth(c *gin.Context) {
 		}
 	} else {
 		if global.RegisterEnabled {
-			u.Username = "github_" + strconv.Itoa(server.GetMaxUserId()+1)
+			u.Username = "github_" + strconv.Itoa(server.GetMaxUserId(c.Request.Context())+1)
 			if githubUser.Name != "" {
 				u.DisplayName = githubUser.Name
 			} else {
@@ -167,7 +170,7 @@ func GitHubBind(c *gin.Context) {
 		return
 	}
 	code := c.Query("code")
-	githubUser, err := getGitHubUserInfoByCode(code)
+	githubUser, err := getGitHubUserInfoByCode(c.Request.Context(), code)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -176,7 +179,7 @@ func GitHubBind(c *gin.Context) {
 		return
 	}
 	u := model.NewUserByGitHubId(githubUser.Login)
-	if server.IsGitHubIdAlreadyTaken(u.GitHubId) {
+	if server.IsGitHubIdAlreadyTaken(c.Request.Context(), u.GitHubId) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "该 GitHub 账户已被绑定",
@@ -187,7 +190,7 @@ func GitHubBind(c *gin.Context) {
 	id := session.Get("id")
 	// id := c.GetInt("id")  // critical bug!
 	u.Id = id.(int)
-	err = server.FillUserById(u)
+	err = server.FillUserById(c.Request.Context(), u)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -196,7 +199,7 @@ func GitHubBind(c *gin.Context) {
 		return
 	}
 	u.GitHubId = githubUser.Login
-	err = server.UpdateUser(u, false)
+	err = server.UpdateUser(c.Request.Context(), u, false)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/9688101/hx-admin/controller"
 	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/core/tracing"
 	"github.com/9688101/hx-admin/global"
 	"github.com/9688101/hx-admin/model"
 	"github.com/9688101/hx-admin/server"
@@ -28,7 +30,7 @@ type LarkUser struct {
 	OpenID string `json:"open_id"`
 }
 
-func getLarkUserInfoByCode(code string) (*LarkUser, error) {
+func getLarkUserInfoByCode(ctx context.Context, code string) (*LarkUser, error) {
 	if code == "" {
 		return nil, errors.New("无效的参数")
 	}
@@ -43,14 +45,15 @@ func getLarkUserInfoByCode(code string) (*LarkUser, error) {
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest("POST", "https://open.feishu.cn/open-apis/authen/v2/oauth/token", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", "https://open.feishu.cn/open-apis/authen/v2/oauth/token", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 	client := http.Client{
-		Timeout: 5 * time.Second,
+		Transport: tracing.NewTransport(nil),
+		Timeout:   5 * time.Second,
 	}
 	res, err := client.Do(req)
 	if err != nil {
@@ -63,7 +66,7 @@ func getLarkUserInfoByCode(code string) (*LarkUser, error) {
 	if err != nil {
 		return nil, err
 	}
-	req, err = http.NewRequest("GET", "https://passport.feishu.cn/suite/passport/oauth/userinfo", nil)
+	req, err = http.NewRequestWithContext(ctx, "GET", "https://passport.feishu.cn/suite/passport/oauth/userinfo", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -98,7 +101,7 @@ func LarkOAuth(c *gin.Context) {
 		return
 	}
 	code := c.Query("code")
-	larkUser, err := getLarkUserInfoByCode(code)
+	larkUser, err := getLarkUserInfoByCode(c.Request.Context(), code)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -109,8 +112,8 @@ func LarkOAuth(c *gin.Context) {
 	user := model.User{
 		LarkId: larkUser.OpenID,
 	}
-	if server.IsLarkIdAlreadyTaken(user.LarkId) {
-		err := server.FillUserByLarkId(&user)
+	if server.IsLarkIdAlreadyTaken(c.Request.Context(), user.LarkId) {
+		err := server.FillUserByLarkId(c.Request.Context(), &user)
 		if err != nil {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -120,7 +123,7 @@ func LarkOAuth(c *gin.Context) {
 		}
 	} else {
 		if global.RegisterEnabled {
-			user.Username = "lark_" + strconv.Itoa(server.GetMaxUserId()+1)
+			user.Username = "lark_" + strconv.Itoa(server.GetMaxUserId(c.Request.Context())+1)
 			if larkUser.Name != "" {
 				user.DisplayName = larkUser.Name
 			} else {
@@ -157,7 +160,7 @@ func LarkOAuth(c *gin.Context) {
 
 func LarkBind(c *gin.Context) {
 	code := c.Query("code")
-	larkUser, err := getLarkUserInfoByCode(code)
+	larkUser, err := getLarkUserInfoByCode(c.Request.Context(), code)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -168,7 +171,7 @@ func LarkBind(c *gin.Context) {
 	user := model.User{
 		LarkId: larkUser.OpenID,
 	}
-	if server.IsLarkIdAlreadyTaken(user.LarkId) {
+	if server.IsLarkIdAlreadyTaken(c.Request.Context(), user.LarkId) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "该飞书账户已被绑定",
@@ -179,7 +182,7 @@ func LarkBind(c *gin.Context) {
 	id := session.Get("id")
 	// id := c.GetInt("id")  // critical bug!
 	user.Id = id.(int)
-	err = server.FillUserById(&user)
+	err = server.FillUserById(c.Request.Context(), &user)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -188,7 +191,7 @@ func LarkBind(c *gin.Context) {
 		return
 	}
 	user.LarkId = larkUser.OpenID
-	err = server.UpdateUser(&user, false)
+	err = server.UpdateUser(c.Request.Context(), &user, false)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/9688101/hx-admin/controller"
 	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/core/tracing"
 	"github.com/9688101/hx-admin/global"
 	"github.com/9688101/hx-admin/model"
 	"github.com/9688101/hx-admin/server"
@@ -36,7 +38,7 @@ type OidcUser struct {
 	Picture           string `json:"picture"`
 }
 
-func getOidcUserInfoByCode(code string) (*OidcUser, error) {
+func getOidcUserInfoByCode(ctx context.Context, code string) (*OidcUser, error) {
 	if code == "" {
 		return nil, errors.New("无效的参数")
 	}
@@ -51,14 +53,15 @@ func getOidcUserInfoByCode(code string) (*OidcUser, error) {
 	if err != nil {
 		return nil, err
 	}
-	req, err := http.NewRequest("POST", global.OidcTokenEndpoint, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", global.OidcTokenEndpoint, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
 	client := http.Client{
-		Timeout: 5 * time.Second,
+		Transport: tracing.NewTransport(nil),
+		Timeout:   5 * time.Second,
 	}
 	res, err := client.Do(req)
 	if err != nil {
@@ -71,7 +74,7 @@ func getOidcUserInfoByCode(code string) (*OidcUser, error) {
 	if err != nil {
 		return nil, err
 	}
-	req, err = http.NewRequest("GET", global.OidcUserinfoEndpoint, nil)
+	req, err = http.NewRequestWithContext(ctx, "GET", global.OidcUserinfoEndpoint, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -113,7 +116,7 @@ func OidcAuth(c *gin.Context) {
 		return
 	}
 	code := c.Query("code")
-	oidcUser, err := getOidcUserInfoByCode(code)
+	oidcUser, err := getOidcUserInfoByCode(c.Request.Context(), code)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -124,8 +127,8 @@ func OidcAuth(c *gin.Context) {
 	user := model.User{
 		OidcId: oidcUser.OpenID,
 	}
-	if server.IsOidcIdAlreadyTaken(user.OidcId) {
-		err := server.FillUserByOidcId(&user)
+	if server.IsOidcIdAlreadyTaken(c.Request.Context(), user.OidcId) {
+		err := server.FillUserByOidcId(c.Request.Context(), &user)
 		if err != nil {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -139,7 +142,7 @@ func OidcAuth(c *gin.Context) {
 			if oidcUser.PreferredUsername != "" {
 				user.Username = oidcUser.PreferredUsername
 			} else {
-				user.Username = "oidc_" + strconv.Itoa(server.GetMaxUserId()+1)
+				user.Username = "oidc_" + strconv.Itoa(server.GetMaxUserId(c.Request.Context())+1)
 			}
 			if oidcUser.Name != "" {
 				user.DisplayName = oidcUser.Name
@@ -182,7 +185,7 @@ func OidcBind(c *gin.Context) {
 		return
 	}
 	code := c.Query("code")
-	oidcUser, err := getOidcUserInfoByCode(code)
+	oidcUser, err := getOidcUserInfoByCode(c.Request.Context(), code)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -193,7 +196,7 @@ func OidcBind(c *gin.Context) {
 	user := model.User{
 		OidcId: oidcUser.OpenID,
 	}
-	if server.IsOidcIdAlreadyTaken(user.OidcId) {
+	if server.IsOidcIdAlreadyTaken(c.Request.Context(), user.OidcId) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "该 OIDC 账户已被绑定",
@@ -204,7 +207,7 @@ func OidcBind(c *gin.Context) {
 	id := session.Get("id")
 	// id := c.GetInt("id")  // critical bug!
 	user.Id = id.(int)
-	err = server.FillUserById(&user)
+	err = server.FillUserById(c.Request.Context(), &user)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -213,7 +216,7 @@ func OidcBind(c *gin.Context) {
 		return
 	}
 	user.OidcId = oidcUser.OpenID
-	err = server.UpdateUser(&user, false)
+	err = server.UpdateUser(c.Request.Context(), &user, false)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
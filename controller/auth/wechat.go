@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/9688101/hx-admin/controller"
+	"github.com/9688101/hx-admin/core/tracing"
 	"github.com/9688101/hx-admin/global"
 	"github.com/9688101/hx-admin/model"
 	"github.com/9688101/hx-admin/server"
@@ -23,17 +25,18 @@ type wechatLoginResponse struct {
 	Data    string `json:"data"`
 }
 
-func getWeChatIdByCode(code string) (string, error) {
+func getWeChatIdByCode(ctx context.Context, code string) (string, error) {
 	if code == "" {
 		return "", errors.New("无效的参数")
 	}
-	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/wechat/user?code=%s", global.WeChatServerAddress, code), nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/api/wechat/user?code=%s", global.WeChatServerAddress, code), nil)
 	if err != nil {
 		return "", err
 	}
 	req.Header.Set("Authorization", global.WeChatServerToken)
 	client := http.Client{
-		Timeout: 5 * time.Second,
+		Transport: tracing.NewTransport(nil),
+		Timeout:   5 * time.Second,
 	}
 	httpResponse, err := client.Do(req)
 	if err != nil {
@@ -64,7 +67,7 @@ func WeChatAuth(c *gin.Context) {
 		return
 	}
 	code := c.Query("code")
-	wechatId, err := getWeChatIdByCode(code)
+	wechatId, err := getWeChatIdByCode(c.Request.Context(), code)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": err.Error(),
@@ -75,8 +78,8 @@ func WeChatAuth(c *gin.Context) {
 	user := model.User{
 		WeChatId: wechatId,
 	}
-	if server.IsWeChatIdAlreadyTaken(wechatId) {
-		err := server.FillUserByWeChatId(&user)
+	if server.IsWeChatIdAlreadyTaken(c.Request.Context(), wechatId) {
+		err := server.FillUserByWeChatId(c.Request.Context(), &user)
 		if err != nil {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
@@ -86,7 +89,7 @@ func WeChatAuth(c *gin.Context) {
 		}
 	} else {
 		if global.RegisterEnabled {
-			user.Username = "wechat_" + strconv.Itoa(server.GetMaxUserId()+1)
+			user.Username = "wechat_" + strconv.Itoa(server.GetMaxUserId(c.Request.Context())+1)
 			user.DisplayName = "WeChat User"
 			user.Role = server.RoleCommonUser
 			user.Status = server.UserStatusEnabled
@@ -126,7 +129,7 @@ func WeChatBind(c *gin.Context) {
 		return
 	}
 	code := c.Query("code")
-	wechatId, err := getWeChatIdByCode(code)
+	wechatId, err := getWeChatIdByCode(c.Request.Context(), code)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"message": err.Error(),
@@ -134,7 +137,7 @@ func WeChatBind(c *gin.Context) {
 		})
 		return
 	}
-	if server.IsWeChatIdAlreadyTaken(wechatId) {
+	if server.IsWeChatIdAlreadyTaken(c.Request.Context(), wechatId) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "该微信账号已被绑定",
@@ -145,7 +148,7 @@ func WeChatBind(c *gin.Context) {
 	user := model.User{
 		Id: id,
 	}
-	err = server.FillUserById(&user)
+	err = server.FillUserById(c.Request.Context(), &user)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -154,7 +157,7 @@ func WeChatBind(c *gin.Context) {
 		return
 	}
 	user.WeChatId = wechatId
-	err = server.UpdateUser(&user, false)
+	err = server.UpdateUser(c.Request.Context(), &user, false)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
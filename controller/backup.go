@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/server"
+)
+
+// DownloadBackup 生成 SQLite 数据库的一致性快照并直接下载，不保存到备份目录
+func DownloadBackup(c *gin.Context) {
+	path, err := server.CreateTempBackup()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	defer os.RemoveAll(filepath.Dir(path))
+	c.FileAttachment(path, "one-api-"+time.Now().Format("20060102-150405")+".db")
+	return
+}
+
+// CreateBackup 在服务器的备份目录中生成备份
+func CreateBackup(c *gin.Context) {
+	path, err := server.CreateBackup()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    filepath.Base(path),
+	})
+	return
+}
+
+// GetBackups 列出备份目录中的备份文件
+func GetBackups(c *gin.Context) {
+	files, err := server.ListBackups()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    files,
+	})
+	return
+}
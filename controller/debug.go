@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/middleware"
+	"github.com/9688101/hx-admin/server"
+	"github.com/9688101/hx-admin/utils"
+)
+
+// maxRateLimitKeys 使用 Redis 时最多返回的限流 key 数量
+const maxRateLimitKeys = 1000
+
+// Pprof 转发到 net/http/pprof，路径 /api/debug/pprof/ 之后的部分为 profile 名称
+func Pprof(c *gin.Context) {
+	name := strings.TrimPrefix(c.Param("name"), "/")
+	switch name {
+	case "":
+		pprof.Index(c.Writer, c.Request)
+	case "cmdline":
+		pprof.Cmdline(c.Writer, c.Request)
+	case "profile":
+		pprof.Profile(c.Writer, c.Request)
+	case "symbol":
+		pprof.Symbol(c.Writer, c.Request)
+	case "trace":
+		pprof.Trace(c.Writer, c.Request)
+	default:
+		pprof.Handler(name).ServeHTTP(c.Writer, c.Request)
+	}
+	return
+}
+
+// GetRuntimeStats 获取当前节点的协程数、内存与 GC、运行时长、数据库连接池与缓存大小
+func GetRuntimeStats(c *gin.Context) {
+	stats := server.GetRuntimeStats()
+	rateLimitState, err := middleware.RateLimitState(c.Request.Context(), maxRateLimitKeys)
+	if err == nil {
+		stats.Caches["rate_limit_keys"] = len(rateLimitState)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    stats,
+	})
+	return
+}
+
+// GetRateLimitState 获取限流器的状态
+func GetRateLimitState(c *gin.Context) {
+	state, err := middleware.RateLimitState(c.Request.Context(), maxRateLimitKeys)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    state,
+	})
+	return
+}
+
+// GetBannedUsers 获取当前节点内存中的封禁用户列表
+func GetBannedUsers(c *gin.Context) {
+	ids := utils.BannedUserIds()
+	if ids == nil {
+		ids = []int{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    ids,
+	})
+	return
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/server"
+)
+
+// Healthz 存活检查，只要进程能处理请求就返回 200
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"status":  "ok",
+	})
+	return
+}
+
+// Readyz 就绪检查，依赖不可用或正在退出时返回 503，供 Kubernetes 与负载均衡器摘除节点
+func Readyz(c *gin.Context) {
+	role := "master"
+	if !global.IsMasterNode {
+		role = "slave"
+	}
+	if global.ShuttingDown.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"status":  "shutting_down",
+			"role":    role,
+		})
+		return
+	}
+	ready, components := server.CheckDependencies(c.Request.Context())
+	status := "ready"
+	code := http.StatusOK
+	if !ready {
+		status = "not_ready"
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, gin.H{
+		"success":    ready,
+		"status":     status,
+		"role":       role,
+		"components": components,
+	})
+	return
+}
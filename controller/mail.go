@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/core/i18n"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/server"
+	"github.com/9688101/hx-admin/utils/message"
+)
+
+// GetMails 分页获取发件队列中的邮件，可以按状态筛选，不返回邮件内容
+func GetMails(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	status, _ := strconv.Atoi(c.Query("status"))
+	mails, err := server.GetMails(c.Request.Context(), status, p*global.ItemsPerPage, global.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    mails,
+	})
+	return
+}
+
+// ResendMail 重新发送已发送或发送失败的邮件
+func ResendMail(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = server.ResendMail(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// GetEmailTemplates 获取所有邮件事件在各语言下当前使用的模板，修改模板通过更新对应的配置项完成
+func GetEmailTemplates(c *gin.Context) {
+	templates, err := server.GetEmailTemplates()
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    templates,
+	})
+	return
+}
+
+// PreviewEmailTemplate 使用示例数据渲染邮件模板，可以预览尚未保存的模板
+func PreviewEmailTemplate(c *gin.Context) {
+	var req model.EmailTemplatePreviewRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	if req.Language == "" {
+		req.Language = message.MatchEmailLanguage(i18n.GetLang(c))
+	}
+	email, err := server.PreviewEmailTemplate(req.Event, req.Language, req.Content, req.Data)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    email,
+	})
+	return
+}
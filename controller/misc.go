@@ -43,18 +43,18 @@ func GetStatus(c *gin.Context) {
 			"oidc_authorization_endpoint": global.OidcAuthorizationEndpoint,
 			"oidc_token_endpoint":         global.OidcTokenEndpoint,
 			"oidc_userinfo_endpoint":      global.OidcUserinfoEndpoint,
+			"option_revision":             server.GetLastAppliedOptionRevision(),
 		},
 	})
 	return
 }
 
+// GetNotice 获取系统设置中的公告与生效中的公开公告，面向特定用户的公告通过 /api/user/announcements 获取
 func GetNotice(c *gin.Context) {
-	global.OptionMapRWMutex.RLock()
-	defer global.OptionMapRWMutex.RUnlock()
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
-		"data":    global.OptionMap["Notice"],
+		"data":    server.GetNotice(c.Request.Context()),
 	})
 	return
 }
@@ -106,7 +106,7 @@ func SendEmailVerification(c *gin.Context) {
 			return
 		}
 	}
-	if server.IsEmailAlreadyTaken(email) {
+	if server.IsEmailAlreadyTaken(c.Request.Context(), email) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "邮箱地址已被占用",
@@ -114,19 +114,25 @@ func SendEmailVerification(c *gin.Context) {
 		return
 	}
 	code := utils.GenerateVerificationCode(6)
-	utils.RegisterVerificationCodeWithKey(email, code, utils.EmailVerificationPurpose)
-	subject := fmt.Sprintf("%s 邮箱验证邮件", global.SystemName)
-	content := message.EmailTemplate(
-		subject,
-		fmt.Sprintf(`
-			<p>您好！</p>
-			<p>您正在进行 %s 邮箱验证。</p>
-			<p>您的验证码为：</p>
-			<p style="font-size: 24px; font-weight: bold; color: #333; background-color: #f8f8f8; padding: 10px; text-align: center; border-radius: 4px;">%s</p>
-			<p style="color: #666;">验证码 %d 分钟内有效，如果不是本人操作，请忽略。</p>
-		`, global.SystemName, code, utils.VerificationValidMinutes),
-	)
-	err := message.SendEmail(subject, email, content)
+	if err := server.RegisterVerificationCode(c.Request.Context(), email, code, utils.EmailVerificationPurpose); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	mail, err := message.RenderEmail(message.EmailEventVerification, i18n.GetLang(c), map[string]any{
+		"Code":         code,
+		"ValidMinutes": utils.VerificationValidMinutes,
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	err = server.QueueEmail(c.Request.Context(), email, mail)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -150,7 +156,7 @@ func SendPasswordResetEmail(c *gin.Context) {
 		})
 		return
 	}
-	if !server.IsEmailAlreadyTaken(email) {
+	if !server.IsEmailAlreadyTaken(c.Request.Context(), email) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "该邮箱地址未注册",
@@ -158,24 +164,26 @@ func SendPasswordResetEmail(c *gin.Context) {
 		return
 	}
 	code := utils.GenerateVerificationCode(0)
-	utils.RegisterVerificationCodeWithKey(email, code, utils.PasswordResetPurpose)
+	if err := server.RegisterVerificationCode(c.Request.Context(), email, code, utils.PasswordResetPurpose); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
 	link := fmt.Sprintf("%s/user/reset?email=%s&token=%s", global.ServerAddress, email, code)
-	subject := fmt.Sprintf("%s 密码重置", global.SystemName)
-	content := message.EmailTemplate(
-		subject,
-		fmt.Sprintf(`
-			<p>您好！</p>
-			<p>您正在进行 %s 密码重置。</p>
-			<p>请点击下面的按钮进行密码重置：</p>
-			<p style="text-align: center; margin: 30px 0;">
-				<a href="%s" style="background-color: #007bff; color: white; padding: 12px 24px; text-decoration: none; border-radius: 4px; display: inline-block;">重置密码</a>
-			</p>
-			<p style="color: #666;">如果按钮无法点击，请复制以下链接到浏览器中打开：</p>
-			<p style="background-color: #f8f8f8; padding: 10px; border-radius: 4px; word-break: break-all;">%s</p>
-			<p style="color: #666;">重置链接 %d 分钟内有效，如果不是本人操作，请忽略。</p>
-		`, global.SystemName, link, link, utils.VerificationValidMinutes),
-	)
-	err := message.SendEmail(subject, email, content)
+	mail, err := message.RenderEmail(message.EmailEventPasswordReset, i18n.GetLang(c), map[string]any{
+		"Link":         link,
+		"ValidMinutes": utils.VerificationValidMinutes,
+	})
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	err = server.QueueEmail(c.Request.Context(), email, mail)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -205,7 +213,7 @@ func ResetPassword(c *gin.Context) {
 		})
 		return
 	}
-	if !utils.VerifyCodeWithKey(req.Email, req.Token, utils.PasswordResetPurpose) {
+	if !server.VerifyCode(c.Request.Context(), req.Email, req.Token, utils.PasswordResetPurpose) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "重置链接非法或已过期",
@@ -213,7 +221,7 @@ func ResetPassword(c *gin.Context) {
 		return
 	}
 	password := utils.GenerateVerificationCode(12)
-	err = server.ResetUserPasswordByEmail(req.Email, password)
+	err = server.ResetUserPasswordByEmail(c.Request.Context(), req.Email, password)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -221,7 +229,7 @@ func ResetPassword(c *gin.Context) {
 		})
 		return
 	}
-	utils.DeleteKey(req.Email, utils.PasswordResetPurpose)
+	server.DeleteVerificationCode(c.Request.Context(), req.Email, utils.PasswordResetPurpose)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "",
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/core/i18n"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/server"
+)
+
+// GetNotificationChannels 获取已配置的通知渠道，密钥与地址会被隐藏，修改渠道通过更新 NotificationChannels 配置项完成
+func GetNotificationChannels(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    server.GetNotificationChannels(),
+	})
+	return
+}
+
+// TestNotificationChannel 向已保存的或尚未保存的通知渠道发送一条测试通知
+func TestNotificationChannel(c *gin.Context) {
+	var req model.NotificationTestRequest
+	err := json.NewDecoder(c.Request.Body).Decode(&req)
+	if err != nil || (req.Name == "" && req.Channel == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	if err = server.TestNotificationChannel(c.Request.Context(), req.Name, req.Channel); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
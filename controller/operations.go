@@ -66,7 +66,7 @@ func CreateUser(c *gin.Context) {
 
 func GenerateAccessToken(c *gin.Context) {
 	id := c.GetInt(ctxkey.Id)
-	user, err := server.GetUserById(id, true)
+	user, err := server.GetUserById(c.Request.Context(), id, true)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -76,7 +76,7 @@ func GenerateAccessToken(c *gin.Context) {
 	}
 	user.AccessToken = utils.GetUUID()
 
-	if initialize.DB.Where("access_token = ?", user.AccessToken).First(user).RowsAffected != 0 {
+	if initialize.DB.WithContext(c.Request.Context()).Where("access_token = ?", user.AccessToken).First(user).RowsAffected != 0 {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "请重试，系统生成的 UUID 竟然重复了！",
@@ -84,7 +84,7 @@ func GenerateAccessToken(c *gin.Context) {
 		return
 	}
 
-	if err := server.UpdateUser(user, false); err != nil {
+	if err := server.UpdateUser(c.Request.Context(), user, false); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": err.Error(),
@@ -102,7 +102,7 @@ func GenerateAccessToken(c *gin.Context) {
 
 func GetAffCode(c *gin.Context) {
 	id := c.GetInt(ctxkey.Id)
-	user, err := server.GetUserById(id, true)
+	user, err := server.GetUserById(c.Request.Context(), id, true)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -112,7 +112,7 @@ func GetAffCode(c *gin.Context) {
 	}
 	if user.AffCode == "" {
 		user.AffCode = utils.GetRandomString(4)
-		if err := server.UpdateUser(user, false); err != nil {
+		if err := server.UpdateUser(c.Request.Context(), user, false); err != nil {
 			c.JSON(http.StatusOK, gin.H{
 				"success": false,
 				"message": err.Error(),
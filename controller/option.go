@@ -2,7 +2,10 @@ package controller
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/9688101/hx-admin/core/i18n"
@@ -10,15 +13,17 @@ import (
 	"github.com/9688101/hx-admin/model"
 	"github.com/9688101/hx-admin/server"
 	"github.com/9688101/hx-admin/utils"
+	"github.com/9688101/hx-admin/utils/ctxkey"
 
 	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
 )
 
 func GetOptions(c *gin.Context) {
 	var options []*model.Option
 	global.OptionMapRWMutex.Lock()
 	for k, v := range global.OptionMap {
-		if strings.HasSuffix(k, "Token") || strings.HasSuffix(k, "Secret") {
+		if server.IsSecretOption(k) {
 			continue
 		}
 		options = append(options, &model.Option{
@@ -45,49 +50,174 @@ func UpdateOption(c *gin.Context) {
 		})
 		return
 	}
-	switch option.Key {
-	case "Theme":
-		if !global.ValidThemes[option.Value] {
-			c.JSON(http.StatusOK, gin.H{
-				"success": false,
-				"message": "无效的主题",
-			})
-			return
-		}
-	case "GitHubOAuthEnabled":
-		if option.Value == "true" && global.GitHubClientId == "" {
-			c.JSON(http.StatusOK, gin.H{
-				"success": false,
-				"message": "无法启用 GitHub OAuth，请先填入 GitHub Client Id 以及 GitHub Client Secret！",
-			})
-			return
-		}
-	case "EmailDomainRestrictionEnabled":
-		if option.Value == "true" && len(global.EmailDomainWhitelist) == 0 {
-			c.JSON(http.StatusOK, gin.H{
-				"success": false,
-				"message": "无法启用邮箱域名限制，请先填入限制的邮箱域名！",
-			})
-			return
-		}
-	case "WeChatAuthEnabled":
-		if option.Value == "true" && global.WeChatServerAddress == "" {
-			c.JSON(http.StatusOK, gin.H{
-				"success": false,
-				"message": "无法启用微信登录，请先填入微信登录相关配置信息！",
-			})
-			return
-		}
-	case "TurnstileCheckEnabled":
-		if option.Value == "true" && global.TurnstileSiteKey == "" {
-			c.JSON(http.StatusOK, gin.H{
-				"success": false,
-				"message": "无法启用 Turnstile 校验，请先填入 Turnstile 校验相关配置信息！",
-			})
-			return
-		}
+	if err = server.ValidateOptions([]*model.Option{&option}); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	err = server.UpdateOption(c.Request.Context(), c.GetInt(ctxkey.Id), option.Key, option.Value)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+func UpdateOptions(c *gin.Context) {
+	var options []*model.Option
+	err := json.NewDecoder(c.Request.Body).Decode(&options)
+	if err != nil || len(options) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	if err = server.ValidateOptions(options); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	err = server.UpdateOptions(c.Request.Context(), c.GetInt(ctxkey.Id), options)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+func ExportOptions(c *gin.Context) {
+	options := server.ExportOptions(c.Query("include_secrets") == "true")
+	var data []byte
+	var err error
+	format := c.DefaultQuery("format", "json")
+	switch format {
+	case "json":
+		data, err = json.MarshalIndent(options, "", "  ")
+	case "yaml":
+		data, err = yaml.Marshal(options)
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=options.%s", format))
+	c.Data(http.StatusOK, fmt.Sprintf("application/%s; charset=utf-8", format), data)
+}
+
+// ImportOptions applies the options in the uploaded JSON or YAML file,
+// with dry_run=true only the diff is returned and nothing is saved.
+func ImportOptions(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	options := make(map[string]string)
+	if c.Query("format") == "yaml" || strings.Contains(c.ContentType(), "yaml") {
+		err = yaml.Unmarshal(body, &options)
+	} else {
+		err = json.Unmarshal(body, &options)
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	changes, err := server.ImportOptions(c.Request.Context(), c.GetInt(ctxkey.Id), options, c.Query("dry_run") == "true")
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    changes,
+	})
+	return
+}
+
+func GetOptionHistory(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	revisions, err := server.GetOptionRevisions(c.Request.Context(), c.Query("key"), p*global.ItemsPerPage, global.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    revisions,
+	})
+	return
+}
+
+func RollbackOption(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	revision, err := server.GetOptionRevisionById(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = server.ValidateOptions([]*model.Option{{Key: revision.Key, Value: revision.OldValue}}); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
 	}
-	err = server.UpdateOption(option.Key, option.Value)
+	err = server.RollbackOption(c.Request.Context(), c.GetInt(ctxkey.Id), revision)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -27,7 +27,7 @@ func RelayNotFound(c *gin.Context) {
 
 func GetSelf(c *gin.Context) {
 	id := c.GetInt(ctxkey.Id)
-	user, err := server.GetUserById(id, false)
+	user, err := server.GetUserById(c.Request.Context(), id, false)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -75,7 +75,7 @@ func UpdateSelf(c *gin.Context) {
 		cleanUser.Password = ""
 	}
 	updatePassword := user.Password != ""
-	if err := server.UpdateUser(&cleanUser, updatePassword); err != nil {
+	if err := server.UpdateUser(c.Request.Context(), &cleanUser, updatePassword); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": err.Error(),
@@ -92,7 +92,7 @@ func UpdateSelf(c *gin.Context) {
 
 func DeleteSelf(c *gin.Context) {
 	id := c.GetInt("id")
-	user, _ := server.GetUserById(id, false)
+	user, _ := server.GetUserById(c.Request.Context(), id, false)
 
 	if user.Role == server.RoleRootUser {
 		c.JSON(http.StatusOK, gin.H{
@@ -102,7 +102,7 @@ func DeleteSelf(c *gin.Context) {
 		return
 	}
 
-	err := server.DeleteUserById(id)
+	err := server.DeleteUserById(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -120,7 +120,7 @@ func DeleteSelf(c *gin.Context) {
 func EmailBind(c *gin.Context) {
 	email := c.Query("email")
 	code := c.Query("code")
-	if !utils.VerifyCodeWithKey(email, code, utils.EmailVerificationPurpose) {
+	if !server.VerifyCode(c.Request.Context(), email, code, utils.EmailVerificationPurpose) {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": "验证码错误或已过期",
@@ -129,7 +129,7 @@ func EmailBind(c *gin.Context) {
 	}
 	id := c.GetInt("id")
 	u := model.NewUserById(id)
-	err := server.FillUserById(u)
+	err := server.FillUserById(c.Request.Context(), u)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -139,7 +139,7 @@ func EmailBind(c *gin.Context) {
 	}
 	u.Email = email
 	// no need to check if this email already taken, because we have used verification code to check it
-	err = server.UpdateUser(u, false)
+	err = server.UpdateUser(c.Request.Context(), u, false)
 	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/core/i18n"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/server"
+	"github.com/9688101/hx-admin/utils"
+	"github.com/9688101/hx-admin/utils/ctxkey"
+)
+
+// userEventPingInterval 没有事件时推送 ping 的间隔，避免代理关闭空闲的连接
+const userEventPingInterval = 30 * time.Second
+
+// GetUserNotifications 分页获取当前用户的通知，unread=true 时只返回未读的通知
+func GetUserNotifications(c *gin.Context) {
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	unreadOnly := c.Query("unread") == "true"
+	notifications, err := server.GetUserNotifications(c.Request.Context(), c.GetInt(ctxkey.Id), unreadOnly, p*global.ItemsPerPage, global.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    notifications,
+	})
+	return
+}
+
+// GetUnreadUserNotificationCount 获取当前用户未读的通知数
+func GetUnreadUserNotificationCount(c *gin.Context) {
+	count, err := server.CountUnreadUserNotifications(c.Request.Context(), c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    count,
+	})
+	return
+}
+
+// ReadUserNotification 将当前用户的一条通知标记为已读
+func ReadUserNotification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = server.ReadUserNotification(c.Request.Context(), c.GetInt(ctxkey.Id), id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// ReadAllUserNotifications 将当前用户所有的通知标记为已读
+func ReadAllUserNotifications(c *gin.Context) {
+	if err := server.ReadAllUserNotifications(c.Request.Context(), c.GetInt(ctxkey.Id)); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// DeleteUserNotification 删除当前用户的一条通知
+func DeleteUserNotification(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = server.DeleteUserNotification(c.Request.Context(), c.GetInt(ctxkey.Id), id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// BroadcastUserNotification 向所有用户或某个分组中的用户发送通知
+func BroadcastUserNotification(c *gin.Context) {
+	var req model.BroadcastRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	count, err := server.BroadcastUserNotification(c.Request.Context(), &req, c.GetInt(ctxkey.Id))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    count,
+	})
+	return
+}
+
+// UserEvents 通过 SSE 推送当前用户的新通知与强制退出登录事件，连接建立时先推送未读的通知数
+func UserEvents(c *gin.Context) {
+	id := c.GetInt(ctxkey.Id)
+	events, cancel := server.ListenUserEvents(c.Request.Context(), id)
+	defer cancel()
+	unread, err := server.CountUnreadUserNotifications(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	utils.SetEventStreamHeaders(c)
+	_ = utils.EventData(c, model.UserEventUnread, unread)
+	ticker := time.NewTicker(userEventPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err = utils.EventData(c, event.Event, event.Data); err != nil {
+				return
+			}
+			if event.Event == model.UserEventLogout {
+				return
+			}
+		case <-ticker.C:
+			_ = utils.EventData(c, model.UserEventPing, utils.GetTimestamp())
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/core/i18n"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/server"
+)
+
+// GetWebhooks 获取所有 webhook，不返回签名密钥
+func GetWebhooks(c *gin.Context) {
+	webhooks, err := server.GetWebhooks(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    webhooks,
+	})
+	return
+}
+
+// GetWebhookEvents 获取 webhook 可以订阅的事件
+func GetWebhookEvents(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    model.WebhookEvents,
+	})
+	return
+}
+
+// AddWebhook 添加 webhook，响应中包含签名密钥，之后不会再返回
+func AddWebhook(c *gin.Context) {
+	webhook := model.NewWebhook()
+	if err := json.NewDecoder(c.Request.Body).Decode(webhook); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	if err := server.CreateWebhook(c.Request.Context(), webhook); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    webhook,
+	})
+	return
+}
+
+// UpdateWebhook 修改 webhook，secret 为空时保留原来的签名密钥
+func UpdateWebhook(c *gin.Context) {
+	webhook := model.NewWebhook()
+	if err := json.NewDecoder(c.Request.Body).Decode(webhook); err != nil || webhook.Id == 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": i18n.Translate(c, "invalid_parameter"),
+		})
+		return
+	}
+	if err := server.UpdateWebhook(c.Request.Context(), webhook); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// DeleteWebhook 删除 webhook 及其投递记录
+func DeleteWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = server.DeleteWebhook(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// PingWebhook 向 webhook 投递一个 ping 事件，结果在投递记录中查看
+func PingWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err = server.PingWebhook(c.Request.Context(), id); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+	return
+}
+
+// GetWebhookDeliveries 分页获取 webhook 的投递记录，可以按状态筛选
+func GetWebhookDeliveries(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	p, _ := strconv.Atoi(c.Query("p"))
+	if p < 0 {
+		p = 0
+	}
+	status, _ := strconv.Atoi(c.Query("status"))
+	deliveries, err := server.GetWebhookDeliveries(c.Request.Context(), id, status, p*global.ItemsPerPage, global.ItemsPerPage)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    deliveries,
+	})
+	return
+}
+
+// RedeliverWebhook 重新投递一条已完成的投递记录中的事件
+func RedeliverWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	delivery, err := server.RedeliverWebhook(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    delivery,
+	})
+	return
+}
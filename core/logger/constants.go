@@ -1,3 +1,12 @@
 package logger
 
 var LogDir string
+
+// 以下配置由 source.Init 根据启动配置赋值，需在 SetupLogger 之前设置
+var (
+	Format     = "text" // 日志格式：text 或 json
+	MaxSize    = 100    // 单个日志文件的最大大小（MB），超过后轮转
+	MaxAge     = 7      // 轮转后的日志文件保留天数，0 表示不按时间清理
+	MaxBackups = 0      // 轮转后的日志文件最多保留个数，0 表示不按个数清理
+	Compress   = true   // 是否使用 gzip 压缩轮转后的日志文件
+)
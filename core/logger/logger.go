@@ -4,15 +4,18 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/natefinch/lumberjack.v2"
 
 	"github.com/9688101/hx-admin/global"
 	"github.com/9688101/hx-admin/utils"
@@ -28,27 +31,127 @@ const (
 	loggerFatal loggerLevel = "FATAL"
 )
 
+const slogLevelFatal = slog.LevelError + 4
+
+var slogLevels = map[loggerLevel]slog.Level{
+	loggerDEBUG: slog.LevelDebug,
+	loggerINFO:  slog.LevelInfo,
+	loggerWarn:  slog.LevelWarn,
+	loggerError: slog.LevelError,
+	loggerFatal: slogLevelFatal,
+}
+
+// minLevel 低于该级别的日志不输出，FATAL 日志总是输出
+var minLevel atomic.Int64
+
+func init() {
+	minLevel.Store(int64(slog.LevelInfo))
+	current.Store(newLogger(os.Stdout))
+}
+
+// SetLevel 设置最低日志级别，可选 debug、info、warn、error，可在运行时调用
+func SetLevel(level string) error {
+	l := loggerLevel(strings.ToUpper(level))
+	order, ok := slogLevels[l]
+	if !ok || l == loggerFatal {
+		return fmt.Errorf("invalid log level: %s", level)
+	}
+	minLevel.Store(int64(order))
+	return nil
+}
+
+func enabled(level loggerLevel) bool {
+	if level == loggerDEBUG && global.DebugEnabled.Load() {
+		return true
+	}
+	return int64(slogLevels[level]) >= minLevel.Load()
+}
+
+// current 当前使用的 logger，SetupLogger 之前只输出到标准输出
+var current atomic.Pointer[slog.Logger]
+
+func newLogger(w io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       slog.LevelDebug, // 级别已由 enabled 过滤
+		ReplaceAttr: replaceAttr,
+	}
+	if Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+func replaceAttr(groups []string, a slog.Attr) slog.Attr {
+	switch a.Key {
+	case slog.LevelKey:
+		if level, ok := a.Value.Any().(slog.Level); ok && level == slogLevelFatal {
+			return slog.String(slog.LevelKey, string(loggerFatal))
+		}
+	case slog.SourceKey:
+		source, ok := a.Value.Any().(*slog.Source)
+		if !ok || source.File == "" {
+			return slog.Attr{}
+		}
+		return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", shortFile(source.File), source.Line))
+	}
+	return a
+}
+
+// shortFile 只保留文件所在目录和文件名
+func shortFile(file string) string {
+	dir, name := filepath.Split(file)
+	return filepath.Join(filepath.Base(dir), name)
+}
+
 var setupLogOnce sync.Once
 
+// SetupLogger 按配置创建日志文件，之后的日志同时输出到标准输出和文件。
+// 日志文件超过 MaxSize 时轮转，未开启 OnlyOneLogFile 时每天零点也会轮转，
+// 轮转后的文件按 MaxAge、MaxBackups 清理并按 Compress 压缩
 func SetupLogger() {
+	setupLogger(os.Stdout)
+}
+
+// SetupCommandLogger 与 SetupLogger 相同，但输出到标准错误，使子命令的标准输出只包含结果
+func SetupCommandLogger() {
+	setupLogger(os.Stderr)
+}
+
+func setupLogger(console io.Writer) {
 	setupLogOnce.Do(func() {
+		w := console
 		if LogDir != "" {
-			var logPath string
-			if global.OnlyOneLogFile {
-				logPath = filepath.Join(LogDir, "oneapi.log")
-			} else {
-				logPath = filepath.Join(LogDir, fmt.Sprintf("oneapi-%s.log", time.Now().Format("20060102")))
+			fileWriter := &lumberjack.Logger{
+				Filename:   filepath.Join(LogDir, "oneapi.log"),
+				MaxSize:    MaxSize,
+				MaxAge:     MaxAge,
+				MaxBackups: MaxBackups,
+				Compress:   Compress,
+				LocalTime:  true,
 			}
-			fd, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				log.Fatal("failed to open log file")
+			w = io.MultiWriter(console, fileWriter)
+			gin.DefaultWriter = w
+			gin.DefaultErrorWriter = io.MultiWriter(os.Stderr, fileWriter)
+			if !global.OnlyOneLogFile {
+				go rotateDaily(fileWriter)
 			}
-			gin.DefaultWriter = io.MultiWriter(os.Stdout, fd)
-			gin.DefaultErrorWriter = io.MultiWriter(os.Stderr, fd)
 		}
+		current.Store(newLogger(w))
 	})
 }
 
+func rotateDaily(w *lumberjack.Logger) {
+	for {
+		now := time.Now()
+		next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+		time.Sleep(time.Until(next))
+		if err := w.Rotate(); err != nil {
+			SysError("failed to rotate log file: " + err.Error())
+		}
+	}
+}
+
 func SysLog(s string) {
 	logHelper(nil, loggerINFO, s)
 }
@@ -74,9 +177,6 @@ func SysErrorf(format string, a ...any) {
 }
 
 func Debug(ctx context.Context, msg string) {
-	if !global.DebugEnabled {
-		return
-	}
 	logHelper(ctx, loggerDEBUG, msg)
 }
 
@@ -93,7 +193,7 @@ func Error(ctx context.Context, msg string) {
 }
 
 func Debugf(ctx context.Context, format string, a ...any) {
-	if !global.DebugEnabled {
+	if !enabled(loggerDEBUG) {
 		return
 	}
 	logHelper(ctx, loggerDEBUG, fmt.Sprintf(format, a...))
@@ -119,42 +219,48 @@ func FatalLogf(format string, a ...any) {
 	logHelper(nil, loggerFatal, fmt.Sprintf(format, a...))
 }
 
-func logHelper(ctx context.Context, level loggerLevel, msg string) {
-	writer := gin.DefaultErrorWriter
-	if level == loggerINFO {
-		writer = gin.DefaultWriter
+// Access 记录一条 HTTP 访问日志，与其他日志使用相同的格式
+func Access(ctx context.Context, status int, latency time.Duration, clientIP string, method string, path string) {
+	if !enabled(loggerINFO) {
+		return
 	}
-	var requestId string
-	if ctx != nil {
-		rawRequestId := utils.GetRequestID(ctx)
-		if rawRequestId != "" {
-			requestId = fmt.Sprintf(" | %s", rawRequestId)
-		}
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "access", 0)
+	r.AddAttrs(
+		slog.Int("status", status),
+		slog.Float64("latency_ms", float64(latency.Microseconds())/1000),
+		slog.String("client_ip", clientIP),
+		slog.String("method", method),
+		slog.String("path", path),
+	)
+	write(ctx, r)
+}
+
+func logHelper(ctx context.Context, level loggerLevel, msg string) {
+	if !enabled(level) {
+		return
 	}
-	lineInfo, funcName := getLineInfo()
-	now := time.Now()
-	_, _ = fmt.Fprintf(writer, "[%s] %v%s%s %s%s \n", level, now.Format("2006/01/02 - 15:04:05"), requestId, lineInfo, funcName, msg)
-	SetupLogger()
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // 跳过 runtime.Callers、logHelper 及导出的日志函数
+	write(ctx, slog.NewRecord(time.Now(), slogLevels[level], msg, pcs[0]))
 	if level == loggerFatal {
 		os.Exit(1)
 	}
 }
 
-func getLineInfo() (string, string) {
-	funcName := "[unknown] "
-	pc, file, line, ok := runtime.Caller(3)
-	if ok {
-		if fn := runtime.FuncForPC(pc); fn != nil {
-			parts := strings.Split(fn.Name(), ".")
-			funcName = "[" + parts[len(parts)-1] + "] "
-		}
+// write 附加请求 ID 与用户 ID 后输出
+func write(ctx context.Context, r slog.Record) {
+	if ctx == nil {
+		ctx = context.Background()
 	} else {
-		file = "unknown"
-		line = 0
-	}
-	parts := strings.Split(file, "one-api/")
-	if len(parts) > 1 {
-		file = parts[1]
+		if requestId := utils.GetRequestID(ctx); requestId != "" {
+			r.AddAttrs(slog.String("request_id", requestId))
+		}
+		if userId := utils.GetUserID(ctx); userId != 0 {
+			r.AddAttrs(slog.Int("user_id", userId))
+		}
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			r.AddAttrs(slog.String("trace_id", spanContext.TraceID().String()), slog.String("span_id", spanContext.SpanID().String()))
+		}
 	}
-	return fmt.Sprintf(" | %s:%d", file, line), funcName
+	_ = current.Load().Handler().Handle(ctx, r)
 }
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "one_api"
+
+// registry 独立于 prometheus.DefaultRegisterer，只包含这里注册的指标
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	rateLimitRejectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Number of requests rejected by rate limiters, by limiter mark.",
+	}, []string{"mark"})
+	loginsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Number of login attempts by auth method and result.",
+	}, []string{"method", "result"})
+	emailsSentTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emails_sent_total",
+		Help:      "Number of emails sent by result.",
+	}, []string{"result"})
+	redisErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redis_errors_total",
+		Help:      "Number of failed Redis commands by command name.",
+	}, []string{"command"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequestsTotal,
+		httpRequestDuration,
+		rateLimitRejectionsTotal,
+		loginsTotal,
+		emailsSentTotal,
+		redisErrorsTotal,
+	)
+}
+
+// Handler 以 Prometheus 文本格式输出所有指标
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB 注册连接池指标，name 作为 db_name 标签，例如 db、log_db
+func RegisterDB(name string, db *sql.DB) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+func ObserveHTTPRequest(method string, route string, status string, seconds float64) {
+	httpRequestsTotal.WithLabelValues(method, route, status).Inc()
+	httpRequestDuration.WithLabelValues(method, route).Observe(seconds)
+}
+
+func RateLimitRejected(mark string) {
+	rateLimitRejectionsTotal.WithLabelValues(mark).Inc()
+}
+
+func ObserveLogin(method string, success bool) {
+	loginsTotal.WithLabelValues(method, result(success)).Inc()
+}
+
+func ObserveEmail(success bool) {
+	emailsSentTotal.WithLabelValues(result(success)).Inc()
+}
+
+func result(success bool) string {
+	if success {
+		return "success"
+	}
+	return "failure"
+}
+
+// RedisHook 统计执行失败的 Redis 命令，key 不存在（redis.Nil）不算失败
+type RedisHook struct{}
+
+func (RedisHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (RedisHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	if err := cmd.Err(); err != nil && err != redis.Nil {
+		redisErrorsTotal.WithLabelValues(cmd.Name()).Inc()
+	}
+	return nil
+}
+
+func (RedisHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return ctx, nil
+}
+
+func (h RedisHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	for _, cmd := range cmds {
+		_ = h.AfterProcess(ctx, cmd)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "otel:span"
+
+// RegisterGORMCallbacks 为每条 SQL 创建 span，name 用于区分 DB 与 LOG_DB。
+// 只有通过 WithContext 传入请求上下文的查询才会挂在请求的 span 之下
+func RegisterGORMCallbacks(db *gorm.DB, name string) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("otel:before_create", beforeGORM(name, "create")),
+		cb.Create().After("gorm:create").Register("otel:after_create", afterGORM),
+		cb.Query().Before("gorm:query").Register("otel:before_query", beforeGORM(name, "query")),
+		cb.Query().After("gorm:query").Register("otel:after_query", afterGORM),
+		cb.Update().Before("gorm:update").Register("otel:before_update", beforeGORM(name, "update")),
+		cb.Update().After("gorm:update").Register("otel:after_update", afterGORM),
+		cb.Delete().Before("gorm:delete").Register("otel:before_delete", beforeGORM(name, "delete")),
+		cb.Delete().After("gorm:delete").Register("otel:after_delete", afterGORM),
+		cb.Row().Before("gorm:row").Register("otel:before_row", beforeGORM(name, "row")),
+		cb.Row().After("gorm:row").Register("otel:after_row", afterGORM),
+		cb.Raw().Before("gorm:raw").Register("otel:before_raw", beforeGORM(name, "raw")),
+		cb.Raw().After("gorm:raw").Register("otel:after_raw", afterGORM),
+	)
+}
+
+func beforeGORM(name string, op string) func(tx *gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx, span := Tracer().Start(tx.Statement.Context, "gorm."+op,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemKey.String(tx.Dialector.Name()),
+				attribute.String("db.instance", name),
+			),
+		)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func afterGORM(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+	// SQL 中只有占位符，不包含参数值
+	span.SetAttributes(
+		semconv.DBQueryText(tx.Statement.SQL.String()),
+		semconv.DBCollectionName(tx.Statement.Table),
+		attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+	)
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(tx.Error)
+		span.SetStatus(codes.Error, tx.Error.Error())
+	}
+}
@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	"github.com/9688101/hx-admin/config"
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/global"
+)
+
+const instrumentationName = "github.com/9688101/hx-admin"
+
+var provider *sdktrace.TracerProvider
+
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Enabled 未开启时不注册 GORM 回调与 Redis 钩子，避免无用的开销
+func Enabled() bool {
+	return provider != nil
+}
+
+// Init 按配置创建导出器并设置全局 TracerProvider。
+// 无论是否开启都会设置 W3C trace context 传播器，未开启时所有 span 都是空操作
+func Init(cfg config.Tracing, logDir string) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !cfg.Enabled {
+		return nil
+	}
+	exporter, err := newExporter(cfg, logDir)
+	if err != nil {
+		return err
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(global.Version),
+	)
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	logger.SysLogf("tracing enabled, exporting spans with %s exporter", cfg.Exporter)
+	return nil
+}
+
+func newExporter(cfg config.Tracing, logDir string) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	case "file", "":
+		path := cfg.FilePath
+		if path == "" {
+			if logDir == "" {
+				return nil, errors.New("tracing.file-path must be set when log dir is empty")
+			}
+			path = filepath.Join(logDir, "traces.json")
+		}
+		// 与日志文件使用相同的轮转与清理策略
+		writer := &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    logger.MaxSize,
+			MaxAge:     logger.MaxAge,
+			MaxBackups: logger.MaxBackups,
+			Compress:   logger.Compress,
+			LocalTime:  true,
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(writer))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %s", cfg.Exporter)
+	}
+}
+
+// Shutdown 导出尚未发送的 span，退出时在后台任务停止之后调用
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
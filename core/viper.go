@@ -1,71 +1,134 @@
 package core
 
-// import (
-// 	"flag"
-// 	"fmt"
-// 	"github.com/flipped-aurora/gin-vue-admin/server/core/internal"
-// 	"github.com/gin-gonic/gin"
-// 	"os"
-// 	"path/filepath"
+import (
+	"fmt"
 
-// 	"github.com/fsnotify/fsnotify"
-// 	"github.com/spf13/viper"
+	"github.com/spf13/viper"
 
-// 	"github.com/flipped-aurora/gin-vue-admin/server/global"
-// )
+	"github.com/9688101/hx-admin/config"
+)
 
-// // Viper //
-// // 优先级: 命令行 > 环境变量 > 默认值
-// // Author [SliverHorn](https://github.com/SliverHorn)
-// func Viper(path ...string) *viper.Viper {
-// 	var config string
+type binding struct {
+	key          string
+	env          string // 对应的环境变量，为空表示只能通过配置文件设置
+	defaultValue any    // 为 nil 表示没有默认值
+}
 
-// 	if len(path) == 0 {
-// 		flag.StringVar(&config, "c", "", "choose config file.")
-// 		flag.Parse()
-// 		if config == "" { // 判断命令行参数是否为空
-// 			if configEnv := os.Getenv(internal.ConfigEnv); configEnv == "" { // 判断 internal.ConfigEnv 常量存储的环境变量是否为空
-// 				switch gin.Mode() {
-// 				case gin.DebugMode:
-// 					config = internal.ConfigDefaultFile
-// 				case gin.ReleaseMode:
-// 					config = internal.ConfigReleaseFile
-// 				case gin.TestMode:
-// 					config = internal.ConfigTestFile
-// 				}
-// 				fmt.Printf("您正在使用gin模式的%s环境名称,config的路径为%s\n", gin.Mode(), config)
-// 			} else { // internal.ConfigEnv 常量存储的环境变量不为空 将值赋值于config
-// 				config = configEnv
-// 				fmt.Printf("您正在使用%s环境变量,config的路径为%s\n", internal.ConfigEnv, config)
-// 			}
-// 		} else { // 命令行参数不为空 将值赋值于config
-// 			fmt.Printf("您正在使用命令行的-c参数传递的值,config的路径为%s\n", config)
-// 		}
-// 	} else { // 函数传递的可变参数的第一个值赋值于config
-// 		config = path[0]
-// 		fmt.Printf("您正在使用func Viper()传递的值,config的路径为%s\n", config)
-// 	}
+// bindings 列出所有支持的配置项，环境变量名与之前版本保持一致
+var bindings = []binding{
+	{"system.port", "PORT", 3000},
+	{"system.log-dir", "", "./logs"},
+	{"system.session-secret", "SESSION_SECRET", nil},
+	{"system.node-type", "NODE_TYPE", "master"},
+	{"system.sync-frequency", "SYNC_FREQUENCY", nil}, // 未设置时 Redis 不启用，见 initialize.InitRedisClient
+	{"system.memory-cache-enabled", "MEMORY_CACHE_ENABLED", false},
+	{"system.debug", "DEBUG", false},
+	{"system.debug-sql", "DEBUG_SQL", false},
+	{"system.frontend-base-url", "FRONTEND_BASE_URL", nil},
+	{"system.cors-allowed-origins", "CORS_ALLOWED_ORIGINS", nil}, // 逗号分隔，为空时只允许 FRONTEND_BASE_URL 的来源
+	{"system.log-level", "LOG_LEVEL", "info"},
+	{"system.log-format", "LOG_FORMAT", "text"},
+	{"system.log-max-size", "LOG_MAX_SIZE", 100},
+	{"system.log-max-age", "LOG_MAX_AGE", 7},
+	{"system.log-max-backups", "LOG_MAX_BACKUPS", 0},
+	{"system.log-compress", "LOG_COMPRESS", true},
+	{"system.watch-config", "", false}, // 配置文件修改后自动重新加载
+	{"system.shutdown-timeout", "SHUTDOWN_TIMEOUT", 30},
+	{"system.shutdown-delay", "SHUTDOWN_DELAY", 0},
+	{"system.theme", "THEME", "default"},
+	{"system.only-one-log-file", "ONLY_ONE_LOG_FILE", false},
+	{"system.relay-proxy", "RELAY_PROXY", nil},
+	{"system.relay-timeout", "RELAY_TIMEOUT", 0},
+	{"system.user-content-request-proxy", "USER_CONTENT_REQUEST_PROXY", nil},
+	{"system.user-content-request-timeout", "USER_CONTENT_REQUEST_TIMEOUT", 30},
 
-// 	v := viper.New()
-// 	v.SetConfigFile(config)
-// 	v.SetConfigType("yaml")
-// 	err := v.ReadInConfig()
-// 	if err != nil {
-// 		panic(fmt.Errorf("Fatal error config file: %s \n", err))
-// 	}
-// 	v.WatchConfig()
+	{"database.dsn", "SQL_DSN", nil},
+	{"database.sqlite-path", "SQLITE_PATH", "one-api.db"},
+	{"database.sqlite-busy-timeout", "SQLITE_BUSY_TIMEOUT", 3000},
+	{"database.max-idle-conns", "SQL_MAX_IDLE_CONNS", 100},
+	{"database.max-open-conns", "SQL_MAX_OPEN_CONNS", 1000},
+	{"database.max-lifetime", "SQL_MAX_LIFETIME", 60},
 
-// 	v.OnConfigChange(func(e fsnotify.Event) {
-// 		fmt.Println("config file changed:", e.Name)
-// 		if err = v.Unmarshal(&global.GVA_CONFIG); err != nil {
-// 			fmt.Println(err)
-// 		}
-// 	})
-// 	if err = v.Unmarshal(&global.GVA_CONFIG); err != nil {
-// 		panic(err)
-// 	}
+	{"log-database.dsn", "LOG_SQL_DSN", nil},
+	{"log-database.max-idle-conns", "SQL_MAX_IDLE_CONNS", 100},
+	{"log-database.max-open-conns", "SQL_MAX_OPEN_CONNS", 1000},
+	{"log-database.max-lifetime", "SQL_MAX_LIFETIME", 60},
 
-// 	// root 适配性 根据root位置去找到对应迁移位置,保证root路径有效
-// 	global.GVA_CONFIG.AutoCode.Root, _ = filepath.Abs("..")
-// 	return v
-// }
+	{"redis.conn-string", "REDIS_CONN_STRING", nil},
+	{"redis.password", "REDIS_PASSWORD", nil},
+	{"redis.master-name", "REDIS_MASTER_NAME", nil},
+
+	{"rate-limit.global-api-num", "GLOBAL_API_RATE_LIMIT", 480},
+	{"rate-limit.global-api-duration", "", 3 * 60},
+	{"rate-limit.global-web-num", "GLOBAL_WEB_RATE_LIMIT", 240},
+	{"rate-limit.global-web-duration", "", 3 * 60},
+	{"rate-limit.upload-num", "", 10},
+	{"rate-limit.upload-duration", "", 60},
+	{"rate-limit.download-num", "", 10},
+	{"rate-limit.download-duration", "", 60},
+	{"rate-limit.critical-num", "", 20},
+	{"rate-limit.critical-duration", "", 20 * 60},
+
+	{"smtp.server", "SMTP_SERVER", nil},
+	{"smtp.port", "SMTP_PORT", 587},
+	{"smtp.account", "SMTP_ACCOUNT", nil},
+	{"smtp.from", "SMTP_FROM", nil},
+	{"smtp.token", "SMTP_TOKEN", nil},
+	{"smtp.security", "SMTP_SECURITY", "auto"},
+	{"smtp.insecure-skip-verify", "SMTP_INSECURE_SKIP_VERIFY", false},
+	{"smtp.dkim-domain", "SMTP_DKIM_DOMAIN", nil},
+	{"smtp.dkim-selector", "SMTP_DKIM_SELECTOR", nil},
+	{"smtp.dkim-private-key-file", "SMTP_DKIM_PRIVATE_KEY_FILE", nil},
+
+	{"tracing.enabled", "TRACING_ENABLED", false},
+	{"tracing.exporter", "TRACING_EXPORTER", "file"},
+	{"tracing.endpoint", "TRACING_ENDPOINT", nil},
+	{"tracing.file-path", "TRACING_FILE_PATH", nil},
+	{"tracing.sample-ratio", "TRACING_SAMPLE_RATIO", 1.0},
+	{"tracing.service-name", "OTEL_SERVICE_NAME", "one-api"},
+
+	{"backup.dir", "BACKUP_DIR", "./backups"},
+	{"backup.interval", "BACKUP_INTERVAL", 0},
+	{"backup.retention", "BACKUP_RETENTION", 7},
+
+	{"mail-queue.poll-interval", "MAIL_QUEUE_POLL_INTERVAL", 5},
+	{"mail-queue.max-attempts", "MAIL_QUEUE_MAX_ATTEMPTS", 5},
+	{"mail-queue.retry-backoff", "MAIL_QUEUE_RETRY_BACKOFF", 30},
+	{"mail-queue.recipient-limit", "MAIL_QUEUE_RECIPIENT_LIMIT", 10},
+	{"mail-queue.recipient-window", "MAIL_QUEUE_RECIPIENT_WINDOW", 3600},
+	{"mail-queue.retention", "MAIL_QUEUE_RETENTION", 7},
+
+	{"webhook.poll-interval", "WEBHOOK_POLL_INTERVAL", 5},
+	{"webhook.max-attempts", "WEBHOOK_MAX_ATTEMPTS", 8},
+	{"webhook.retry-backoff", "WEBHOOK_RETRY_BACKOFF", 30},
+	{"webhook.timeout", "WEBHOOK_TIMEOUT", 10},
+	{"webhook.retention", "WEBHOOK_RETENTION", 7},
+}
+
+// Viper 读取配置文件并与环境变量、默认值合并
+// 优先级: 命令行 > 环境变量 > 配置文件 > 默认值，命令行参数由调用方覆盖
+// path 为空时只使用环境变量和默认值
+func Viper(path string) (*viper.Viper, *config.Config, error) {
+	v := viper.New()
+	for _, b := range bindings {
+		if b.defaultValue != nil {
+			v.SetDefault(b.key, b.defaultValue)
+		}
+		if b.env != "" {
+			if err := v.BindEnv(b.key, b.env); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if path != "" {
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+	}
+	var cfg config.Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return v, &cfg, nil
+}
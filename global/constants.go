@@ -1,6 +1,12 @@
 package global
 
-import "time"
+import (
+	"sync/atomic"
+	"time"
+)
 
 var StartTime = time.Now().Unix() // unit: second
-var Version = "v0.0.0"            // this hard coding will be replaced automatically when building, no need to manually change
+
+// ShuttingDown is set once a shutdown signal is received, the node reports "not ready" from then on
+var ShuttingDown atomic.Bool
+var Version = "v0.0.0" // this hard coding will be replaced automatically when building, no need to manually change
@@ -3,14 +3,30 @@ package global
 import (
 	"os"
 	"strconv"
-	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/9688101/hx-admin/config"
 	"github.com/9688101/hx-admin/utils/env" // 环境变量处理工具
 	"github.com/google/uuid"                // UUID生成工具
 )
 
+// 启动配置（配置文件、环境变量与命令行参数合并后的结果），由 core.Viper 加载
+// 下方与之对应的变量由 source.Init 根据它赋值，收到 SIGHUP 时由 source.Reload 整体替换，
+// 请求中可能同时读取，因此通过原子指针发布
+var currentConfig atomic.Pointer[config.Config]
+
+// GetConfig 返回当前的配置，返回的配置不能修改
+func GetConfig() *config.Config {
+	return currentConfig.Load()
+}
+
+// SetConfig 替换当前的配置
+func SetConfig(cfg *config.Config) {
+	currentConfig.Store(cfg)
+}
+
 // 系统基础配置
 var SystemName = "One API"                  // 系统名称
 var ServerAddress = "http://localhost:3000" // 服务器基础地址
@@ -23,8 +39,8 @@ var DisplayInCurrencyEnabled = true         // 是否显示货币单位
 var DisplayTokenStatEnabled = true          // 是否显示token统计
 
 // 主题配置
-var Theme = env.String("THEME", "default") // 当前系统主题
-var ValidThemes = map[string]bool{         // 有效主题列表
+var Theme = "default"              // 当前系统主题
+var ValidThemes = map[string]bool{ // 有效主题列表
 	"default": true, "berry": true, "air": true,
 }
 
@@ -52,9 +68,10 @@ var TurnstileCheckEnabled = false    // Turnstile验证开关
 var RegisterEnabled = true           // 注册功能总开关
 
 // 调试相关配置
-var DebugEnabled = strings.ToLower(os.Getenv("DEBUG")) == "true"                      // 调试模式开关
-var DebugSQLEnabled = strings.ToLower(os.Getenv("DEBUG_SQL")) == "true"               // SQL调试开关
-var MemoryCacheEnabled = strings.ToLower(os.Getenv("MEMORY_CACHE_ENABLED")) == "true" // 内存缓存开关
+var DebugEnabled atomic.Bool      // 调试模式开关，配置热加载时修改
+var DebugSQLEnabled = false       // SQL调试开关
+var MemoryCacheEnabled = false    // 内存缓存开关
+var DebugEndpointsEnabled = false // 诊断接口（pprof、运行时状态）开关，仅超级管理员可访问
 
 // 日志配置
 var LogConsumeEnabled = true // 日志记录开关
@@ -75,43 +92,25 @@ var RetryTimes = 0                         // 请求重试次数
 var RootUserEmail = "" // 根用户邮箱
 
 // 节点类型配置
-var IsMasterNode = os.Getenv("NODE_TYPE") != "slave" // 是否为主节点
+var IsMasterNode = true // 是否为主节点
 
 // 请求间隔配置
 var requestInterval, _ = strconv.Atoi(os.Getenv("POLLING_INTERVAL"))
 var RequestInterval = time.Duration(requestInterval) * time.Second // 轮询间隔时间
 
 // 数据同步配置
-var SyncFrequency = env.Int("SYNC_FREQUENCY", 10*60) // 数据同步频率（秒）
+var SyncFrequency = 10 * 60 // 数据同步频率（秒）
 
 // 批量更新配置
 var BatchUpdateEnabled = false                                // 批量更新开关
 var BatchUpdateInterval = env.Int("BATCH_UPDATE_INTERVAL", 5) // 批量更新间隔（秒）
 
 // 请求超时配置
-var RelayTimeout = env.Int("RELAY_TIMEOUT", 0) // 转发超时时间（秒）
+var RelayTimeout = 0 // 转发超时时间（秒）
 
 // Gemini安全配置
 var GeminiSafetySetting = env.String("GEMINI_SAFETY_SETTING", "BLOCK_NONE") // 安全设置级别
 
-// 速率限制配置（单位：秒）
-var (
-	GlobalApiRateLimitNum            = env.Int("GLOBAL_API_RATE_LIMIT", 480) // API全局请求限制数
-	GlobalApiRateLimitDuration int64 = 3 * 60                                // API限制时间窗口（180秒）
-
-	GlobalWebRateLimitNum            = env.Int("GLOBAL_WEB_RATE_LIMIT", 240) // Web请求限制数
-	GlobalWebRateLimitDuration int64 = 3 * 60                                // Web限制时间窗口
-
-	UploadRateLimitNum            = 10 // 上传操作限制数
-	UploadRateLimitDuration int64 = 60 // 上传限制时间窗口
-
-	DownloadRateLimitNum            = 10 // 下载操作限制数
-	DownloadRateLimitDuration int64 = 60 // 下载限制时间窗口
-
-	CriticalRateLimitNum            = 20      // 关键操作限制数
-	CriticalRateLimitDuration int64 = 20 * 60 // 关键操作限制时间窗口
-)
-
 var RateLimitKeyExpirationDuration = 20 * time.Minute // 限速key过期时间
 
 // 监控指标配置
@@ -120,6 +119,8 @@ var MetricQueueSize = env.Int("METRIC_QUEUE_SIZE", 10)
 var MetricSuccessRateThreshold = env.Float64("METRIC_SUCCESS_RATE_THRESHOLD", 0.8) // 成功率阈值
 var MetricSuccessChanSize = env.Int("METRIC_SUCCESS_CHAN_SIZE", 1024)              // 成功指标通道大小
 var MetricFailChanSize = env.Int("METRIC_FAIL_CHAN_SIZE", 128)                     // 失败指标通道大小
+var MetricToken = os.Getenv("METRIC_TOKEN")                                        // 访问 /metrics 所需的令牌
+var MetricAllowedIPs = os.Getenv("METRIC_ALLOWED_IPS")                             // 允许访问 /metrics 的网段，逗号分隔
 
 // 初始化令牌配置
 var InitialRootToken = os.Getenv("INITIAL_ROOT_TOKEN")              // 根用户初始令牌
@@ -129,12 +130,12 @@ var InitialRootAccessToken = os.Getenv("INITIAL_ROOT_ACCESS_TOKEN") // 根用户
 var GeminiVersion = env.String("GEMINI_VERSION", "v1") // Gemini接口版本
 
 // 日志文件配置
-var OnlyOneLogFile = env.Bool("ONLY_ONE_LOG_FILE", false) // 单日志文件模式
+var OnlyOneLogFile = false // 单日志文件模式
 
 // 代理配置
-var RelayProxy = env.String("RELAY_PROXY", "")                              // 请求转发代理
-var UserContentRequestProxy = env.String("USER_CONTENT_REQUEST_PROXY", "")  // 用户内容请求代理
-var UserContentRequestTimeout = env.Int("USER_CONTENT_REQUEST_TIMEOUT", 30) // 用户内容请求超时（秒）
+var RelayProxy = ""                // 请求转发代理
+var UserContentRequestProxy = ""   // 用户内容请求代理
+var UserContentRequestTimeout = 30 // 用户内容请求超时（秒）
 
 // 特殊功能配置
 var EnforceIncludeUsage = env.Bool("ENFORCE_INCLUDE_USAGE", false)                                          // 强制包含用量信息
@@ -7,6 +7,9 @@ var SMTPAccount = "" // SMTP账号
 var SMTPFrom = ""    // 发件人邮箱
 var SMTPToken = ""   // SMTP授权令牌
 
+var SMTPSecurity = "auto"          // SMTP连接的加密方式：auto、tls、starttls、none
+var SMTPInsecureSkipVerify = false // 是否跳过SMTP服务器证书的校验
+
 // 第三方服务配置
 var GitHubClientId = ""     // GitHub OAuth客户端ID
 var GitHubClientSecret = "" // GitHub OAuth客户端密钥
@@ -0,0 +1,251 @@
+package initialize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/model"
+)
+
+const (
+	backupFilePrefix = "one-api-"
+	backupFileSuffix = ".db"
+)
+
+var ErrNotSQLite = errors.New("备份与恢复仅支持 SQLite 数据库")
+
+// BackupFile 备份目录中的一个备份文件
+type BackupFile struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// BackupSQLite 使用 VACUUM INTO 在 path 生成数据库的一致性快照，备份期间不阻塞写入，path 不能已存在
+func BackupSQLite(db *gorm.DB, path string) error {
+	if db.Dialector.Name() != "sqlite" {
+		return ErrNotSQLite
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	return db.Exec("VACUUM INTO ?", path).Error
+}
+
+// CreateBackup 在 dir 中生成以当前时间命名的备份文件，并只保留最新的 retention 个，retention 为 0 表示不清理
+func CreateBackup(db *gorm.DB, dir string, retention int) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := backupFilePrefix + time.Now().Format("20060102-150405") + backupFileSuffix
+	path := filepath.Join(dir, name)
+	if err := BackupSQLite(db, path); err != nil {
+		return "", err
+	}
+	if retention > 0 {
+		if err := pruneBackups(dir, retention); err != nil {
+			logger.SysError("failed to remove old backups: " + err.Error())
+		}
+	}
+	return path, nil
+}
+
+// ListBackups 按时间从新到旧列出 dir 中的备份文件
+func ListBackups(dir string) ([]*BackupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var files []*BackupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupFilePrefix) || !strings.HasSuffix(name, backupFileSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, &BackupFile{
+			Name:      name,
+			Size:      info.Size(),
+			CreatedAt: info.ModTime().Unix(),
+		})
+	}
+	// 文件名中的时间可以直接按字符串排序
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name > files[j].Name
+	})
+	return files, nil
+}
+
+func pruneBackups(dir string, retention int) error {
+	files, err := ListBackups(dir)
+	if err != nil {
+		return err
+	}
+	for i := retention; i < len(files); i++ {
+		if err = os.Remove(filepath.Join(dir, files[i].Name)); err != nil {
+			return err
+		}
+		logger.SysLogf("removed old backup %s", files[i].Name)
+	}
+	return nil
+}
+
+// ValidateBackup 检查备份文件是否完整，以及其中的迁移版本是否能被当前程序使用。
+// 比当前程序旧的备份可以恢复，启动时会自动执行剩余的迁移；包含未知迁移的备份来自更新的版本，不能恢复。
+func ValidateBackup(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := gorm.Open(sqlite.Open("file:"+path+"?mode=ro"), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		return err
+	}
+	defer closeDB(db)
+
+	var result string
+	if err = db.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil {
+		return fmt.Errorf("%s is not a valid SQLite database: %w", path, err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check of %s failed: %s", path, result)
+	}
+	if !db.Migrator().HasTable(&model.SchemaMigration{}) {
+		return fmt.Errorf("%s has no schema_migrations table, it was not created by this program or is too old", path)
+	}
+	var records []*model.SchemaMigration
+	if err = db.Find(&records).Error; err != nil {
+		return err
+	}
+	for _, record := range records {
+		if findMigration(Migrations, record.Version) == nil {
+			return fmt.Errorf("%s has migration %d_%s applied which is unknown to this version of the program", path, record.Version, record.Name)
+		}
+	}
+	return nil
+}
+
+// sqliteSidecarSuffixes SQLite 在数据库文件旁边创建的 WAL、共享内存与回滚日志文件
+var sqliteSidecarSuffixes = []string{"-wal", "-shm", "-journal"}
+
+// checkSQLiteNotInUse 检查 path 是否可能仍被服务打开：存在 WAL、共享内存或回滚日志文件，或者无法立即取得排他锁。
+// 空闲的连接在回滚日志模式下不持有锁，因此只能发现正在读写的服务，调用前仍然必须停止服务
+func checkSQLiteNotInUse(path string) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	for _, suffix := range sqliteSidecarSuffixes {
+		if _, err := os.Stat(path + suffix); err == nil {
+			return fmt.Errorf("%s exists, the database may still be open by the service or was not closed cleanly", path+suffix)
+		}
+	}
+	err := lockSQLite(path)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked) {
+		return fmt.Errorf("%s is locked, the database is still in use: %w", path, err)
+	}
+	// 损坏或不是数据库的文件不影响恢复
+	return nil
+}
+
+// lockSQLite 尝试不等待地取得 path 的排他锁后立即释放
+func lockSQLite(path string) error {
+	db, err := gorm.Open(sqlite.Open(path+"?_busy_timeout=0"), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		return err
+	}
+	defer closeDB(db)
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err = conn.ExecContext(ctx, "BEGIN EXCLUSIVE"); err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, "ROLLBACK")
+	return err
+}
+
+// RestoreSQLite 校验备份后用它替换 target，原文件与它的 WAL 等文件重命名为 target.<时间>.bak 保留。
+// 调用前必须停止服务，发现数据库仍在使用时拒绝恢复，force 时只给出警告
+func RestoreSQLite(backup string, target string, force bool) (string, error) {
+	if err := ValidateBackup(backup); err != nil {
+		if !force {
+			return "", err
+		}
+		logger.SysWarnf("ignoring failed validation: %s", err.Error())
+	}
+	if err := checkSQLiteNotInUse(target); err != nil {
+		if !force {
+			return "", fmt.Errorf("stop the service before restoring: %w", err)
+		}
+		logger.SysWarnf("RESTORING WHILE THE DATABASE MAY BE IN USE, the service must be restarted afterwards: %s", err.Error())
+	}
+	// 先复制到同一目录下的临时文件，保证最后的替换是原子的
+	tmp := target + ".restoring"
+	if err := copyFile(backup, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	bak := target + "." + time.Now().Format("20060102-150405") + ".bak"
+	var old string
+	if _, err := os.Stat(target); err == nil {
+		old = bak
+		if err = os.Rename(target, old); err != nil {
+			_ = os.Remove(tmp)
+			return "", err
+		}
+	}
+	// 旧数据库的 WAL 与日志文件不能应用到恢复后的数据库上，与原文件一起保留，需要时可以一起还原
+	for _, suffix := range sqliteSidecarSuffixes {
+		if err := os.Rename(target+suffix, bak+suffix); err != nil && !os.IsNotExist(err) {
+			return old, err
+		}
+	}
+	return old, os.Rename(tmp, target)
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
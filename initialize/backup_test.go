@@ -0,0 +1,87 @@
+package initialize
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/9688101/hx-admin/model"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	Convey("TestBackupAndRestore", t, func() {
+		db := openTestDB(t)
+		_, err := MigrateUp(db, Migrations, 0)
+		So(err, ShouldBeNil)
+		So(db.Create(&model.Option{Key: "SystemName", Value: "backup"}).Error, ShouldBeNil)
+
+		dir := t.TempDir()
+		path := filepath.Join(dir, "snapshot.db")
+		So(BackupSQLite(db, path), ShouldBeNil)
+		So(BackupSQLite(db, path), ShouldNotBeNil) // 不覆盖已有文件
+		So(ValidateBackup(path), ShouldBeNil)
+
+		target := filepath.Join(dir, "one-api.db")
+		So(os.WriteFile(target, []byte("old"), 0644), ShouldBeNil)
+		old, err := RestoreSQLite(path, target, false)
+		So(err, ShouldBeNil)
+		data, err := os.ReadFile(old)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "old")
+
+		restored, err := gorm.Open(sqlite.Open(target), &gorm.Config{Logger: gormlogger.Discard})
+		So(err, ShouldBeNil)
+		var option model.Option
+		So(restored.First(&option, "key = ?", "SystemName").Error, ShouldBeNil)
+		So(option.Value, ShouldEqual, "backup")
+
+		// 数据库仍被打开时拒绝恢复，force 时原数据库的 WAL 等文件与原文件一起保留
+		locked, err := gorm.Open(sqlite.Open(target), &gorm.Config{Logger: gormlogger.Discard})
+		So(err, ShouldBeNil)
+		So(locked.Exec("PRAGMA locking_mode = EXCLUSIVE").Error, ShouldBeNil)
+		So(locked.First(&option).Error, ShouldBeNil)
+		_, err = RestoreSQLite(path, target, false)
+		So(err, ShouldNotBeNil)
+		So(closeDB(locked), ShouldBeNil)
+		So(closeDB(restored), ShouldBeNil)
+		So(os.WriteFile(target+"-wal", []byte("wal"), 0644), ShouldBeNil)
+		_, err = RestoreSQLite(path, target, false)
+		So(err, ShouldNotBeNil)
+		old, err = RestoreSQLite(path, target, true)
+		So(err, ShouldBeNil)
+		data, err = os.ReadFile(old + "-wal")
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "wal")
+		_, err = os.Stat(target + "-wal")
+		So(os.IsNotExist(err), ShouldBeTrue)
+
+		// 来自更新版本的备份不能恢复
+		So(db.Create(&model.SchemaMigration{Version: 9999, Name: "future"}).Error, ShouldBeNil)
+		future := filepath.Join(dir, "future.db")
+		So(BackupSQLite(db, future), ShouldBeNil)
+		So(ValidateBackup(future), ShouldNotBeNil)
+		_, err = RestoreSQLite(future, target, false)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestBackupRetention(t *testing.T) {
+	Convey("TestBackupRetention", t, func() {
+		dir := t.TempDir()
+		for _, name := range []string{"one-api-20240101-000000.db", "one-api-20240102-000000.db", "one-api-20240103-000000.db", "other.db"} {
+			So(os.WriteFile(filepath.Join(dir, name), nil, 0644), ShouldBeNil)
+		}
+		So(pruneBackups(dir, 2), ShouldBeNil)
+		files, err := ListBackups(dir)
+		So(err, ShouldBeNil)
+		So(files, ShouldHaveLength, 2)
+		So(files[0].Name, ShouldEqual, "one-api-20240103-000000.db")
+		_, err = os.Stat(filepath.Join(dir, "other.db"))
+		So(err, ShouldBeNil)
+	})
+}
@@ -0,0 +1,229 @@
+package initialize
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/9688101/hx-admin/config"
+	"github.com/9688101/hx-admin/core/logger"
+)
+
+// copySkipTables 不复制的表，目标数据库的迁移记录由迁移本身写入
+var copySkipTables = []string{"schema_migrations", "schema_migration_locks"}
+
+// OpenDSN 根据连接字符串打开数据库，但不修改 UsingMySQL 等全局变量，供命令行子命令打开第二个数据库使用
+// postgres:// 开头为 PostgreSQL，sqlite:// 开头或以 .db 结尾为 SQLite，其余为 MySQL
+func OpenDSN(dsn string) (*gorm.DB, error) {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://"):
+		return gorm.Open(postgres.New(postgres.Config{
+			DSN:                  dsn,
+			PreferSimpleProtocol: true,
+		}), &gorm.Config{})
+	case strings.HasPrefix(dsn, "sqlite://") || strings.HasSuffix(dsn, ".db"):
+		path := strings.TrimPrefix(dsn, "sqlite://")
+		return gorm.Open(sqlite.Open((&config.Sqlite{Path: path, BusyTimeout: SQLiteBusyTimeout}).Dsn()), &gorm.Config{})
+	case dsn != "":
+		return gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	}
+	return nil, fmt.Errorf("empty dsn")
+}
+
+// CopyOptions 复制数据库的参数
+type CopyOptions struct {
+	BatchSize int
+	Tables    []string // 为空时复制所有表
+	Resume    bool     // 目标表非空时从已复制的最大主键之后继续，否则报错
+}
+
+// CopyResult 一张表的复制结果
+type CopyResult struct {
+	Table     string
+	Copied    int64
+	SourceRow int64
+	TargetRow int64
+}
+
+// CopyDatabase 将 src 中的数据按主键顺序分批复制到 dst，dst 的表结构需要事先通过迁移建立。
+// 保留原有主键，复制完成后重置 PostgreSQL 的自增序列并校验两边的行数。
+func CopyDatabase(src *gorm.DB, dst *gorm.DB, opts CopyOptions) ([]*CopyResult, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	tables := opts.Tables
+	if len(tables) == 0 {
+		var err error
+		if tables, err = src.Migrator().GetTables(); err != nil {
+			return nil, err
+		}
+	}
+	var results []*CopyResult
+	for _, table := range tables {
+		// sqlite_ 开头的是 SQLite 的内部表
+		if slices.Contains(copySkipTables, table) || strings.HasPrefix(table, "sqlite_") {
+			continue
+		}
+		if !dst.Migrator().HasTable(table) {
+			logger.SysWarnf("table %s does not exist in the target database, skipped", table)
+			continue
+		}
+		result, err := copyTable(src, dst, table, opts)
+		if err != nil {
+			return results, fmt.Errorf("failed to copy table %s: %w", table, err)
+		}
+		results = append(results, result)
+	}
+	for _, result := range results {
+		if result.SourceRow != result.TargetRow {
+			return results, fmt.Errorf("row count of table %s does not match: source %d, target %d", result.Table, result.SourceRow, result.TargetRow)
+		}
+	}
+	return results, nil
+}
+
+func copyTable(src *gorm.DB, dst *gorm.DB, table string, opts CopyOptions) (*CopyResult, error) {
+	result := &CopyResult{Table: table}
+	columnTypes, err := dst.Migrator().ColumnTypes(table)
+	if err != nil {
+		return nil, err
+	}
+	srcColumnTypes, err := src.Migrator().ColumnTypes(table)
+	if err != nil {
+		return nil, err
+	}
+	srcColumns := make(map[string]bool, len(srcColumnTypes))
+	for _, ct := range srcColumnTypes {
+		srcColumns[ct.Name()] = true
+	}
+	// 只复制两边都存在的列，目标中多出的列使用默认值
+	var columns []string
+	var pk string
+	types := make(map[string]string)
+	for _, ct := range columnTypes {
+		if !srcColumns[ct.Name()] {
+			continue
+		}
+		columns = append(columns, ct.Name())
+		types[ct.Name()] = strings.ToLower(ct.DatabaseTypeName())
+		if isPrimaryKey, ok := ct.PrimaryKey(); ok && isPrimaryKey && pk == "" {
+			pk = ct.Name()
+		}
+	}
+	for name := range srcColumns {
+		if !slices.Contains(columns, name) {
+			logger.SysWarnf("column %s.%s does not exist in the target database, skipped", table, name)
+		}
+	}
+	if pk == "" {
+		return nil, fmt.Errorf("table %s has no primary key", table)
+	}
+	pkColumn := clause.Column{Name: pk}
+	// 列名可能是保留字（例如 users.group），需要由方言加上引号
+	selectColumns := make([]clause.Column, len(columns))
+	for i, name := range columns {
+		selectColumns[i] = clause.Column{Name: name}
+	}
+
+	var last any
+	if err = dst.Table(table).Count(&result.TargetRow).Error; err != nil {
+		return nil, err
+	}
+	if result.TargetRow > 0 {
+		if !opts.Resume {
+			return nil, fmt.Errorf("table %s in the target database is not empty, use -resume to continue an interrupted copy", table)
+		}
+		var row map[string]any
+		err = dst.Table(table).Select(pk).Order(clause.OrderByColumn{Column: pkColumn, Desc: true}).Limit(1).Take(&row).Error
+		if err != nil {
+			return nil, err
+		}
+		last = row[pk]
+		logger.SysLogf("resuming table %s after %s = %v", table, pk, last)
+	}
+
+	for {
+		query := src.Table(table).Clauses(clause.Select{Columns: selectColumns}).Order(clause.OrderByColumn{Column: pkColumn}).Limit(opts.BatchSize)
+		if last != nil {
+			query = query.Where(clause.Gt{Column: pkColumn, Value: last})
+		}
+		var rows []map[string]any
+		if err = query.Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+		for _, row := range rows {
+			for column, value := range row {
+				row[column] = convertValue(value, types[column])
+			}
+		}
+		// 主键冲突的行说明已经复制过，直接跳过
+		err = dst.Table(table).Clauses(ignoreConflict(dst, pkColumn)).Create(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		result.Copied += int64(len(rows))
+		last = rows[len(rows)-1][pk]
+		logger.SysLogf("table %s: %d rows copied", table, result.Copied)
+		if len(rows) < opts.BatchSize {
+			break
+		}
+	}
+
+	if dst.Dialector.Name() == "postgres" && strings.Contains(types[pk], "int") {
+		err = dst.Exec(fmt.Sprintf(
+			"SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE(MAX(%s), 1), MAX(%s) IS NOT NULL) FROM %s",
+			quoteIdentifier(dst, table), pk, quoteIdentifier(dst, pk), quoteIdentifier(dst, pk), quoteIdentifier(dst, table),
+		)).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to reset sequence: %w", err)
+		}
+	}
+
+	if err = src.Table(table).Count(&result.SourceRow).Error; err != nil {
+		return nil, err
+	}
+	if err = dst.Table(table).Count(&result.TargetRow).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// convertValue 处理不同数据库驱动返回的类型差异，例如 SQLite 与 MySQL 中的布尔值是整数
+func convertValue(value any, columnType string) any {
+	if b, ok := value.([]byte); ok && !strings.Contains(columnType, "blob") && !strings.Contains(columnType, "bytea") && !strings.Contains(columnType, "binary") {
+		value = string(b)
+	}
+	if !strings.Contains(columnType, "bool") {
+		return value
+	}
+	switch v := value.(type) {
+	case int64:
+		return v != 0
+	case string:
+		return v == "1" || strings.EqualFold(v, "true") || v == "t"
+	}
+	return value
+}
+
+func ignoreConflict(db *gorm.DB, pk clause.Column) clause.Expression {
+	if db.Dialector.Name() == "mysql" {
+		// MySQL 不支持 ON CONFLICT DO NOTHING，使用等价的 ON DUPLICATE KEY UPDATE pk = pk
+		return clause.OnConflict{DoUpdates: clause.Set{{Column: pk, Value: pk}}}
+	}
+	return clause.OnConflict{Columns: []clause.Column{pk}, DoNothing: true}
+}
+
+func quoteIdentifier(db *gorm.DB, name string) string {
+	var b strings.Builder
+	db.Dialector.QuoteTo(&b, name)
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package initialize
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/9688101/hx-admin/model"
+)
+
+func TestCopyDatabase(t *testing.T) {
+	Convey("TestCopyDatabase", t, func() {
+		src := openTestDB(t)
+		dst := openTestDB(t)
+		_, err := MigrateUp(src, Migrations, 0)
+		So(err, ShouldBeNil)
+		_, err = MigrateUp(dst, Migrations, 0)
+		So(err, ShouldBeNil)
+		for i := 1; i <= 25; i++ {
+			user := &model.User{
+				Id:          i * 2, // 主键不连续
+				Username:    "user" + string(rune('a'+i)),
+				Password:    "x",
+				AccessToken: string(rune('a' + i)),
+				AffCode:     string(rune('a' + i)),
+			}
+			So(src.Create(user).Error, ShouldBeNil)
+		}
+		So(src.Create(&model.Option{Key: "SystemName", Value: "One API"}).Error, ShouldBeNil)
+		So(src.Create(&model.OptionRevision{Key: "SMTPToken", Redacted: true}).Error, ShouldBeNil)
+
+		// 模拟中断：只复制了前 10 个用户
+		var users []*model.User
+		So(src.Order("id").Limit(10).Find(&users).Error, ShouldBeNil)
+		So(dst.Create(&users).Error, ShouldBeNil)
+
+		_, err = CopyDatabase(src, dst, CopyOptions{BatchSize: 7})
+		So(err, ShouldNotBeNil)
+
+		results, err := CopyDatabase(src, dst, CopyOptions{BatchSize: 7, Resume: true})
+		So(err, ShouldBeNil)
+		copied := make(map[string]*CopyResult)
+		for _, r := range results {
+			copied[r.Table] = r
+		}
+		So(copied["users"].Copied, ShouldEqual, 15)
+		So(copied["users"].TargetRow, ShouldEqual, 25)
+		So(copied["options"].TargetRow, ShouldEqual, 1)
+		So(copied, ShouldNotContainKey, "schema_migrations")
+
+		var user model.User
+		So(dst.First(&user, "id = ?", 50).Error, ShouldBeNil)
+		So(user.Username, ShouldEqual, "user"+string(rune('a'+25)))
+		var revision model.OptionRevision
+		So(dst.First(&revision).Error, ShouldBeNil)
+		So(revision.Redacted, ShouldBeTrue)
+	})
+}
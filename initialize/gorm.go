@@ -2,22 +2,32 @@ package initialize
 
 import (
 	"database/sql"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/9688101/hx-admin/config"
 	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/core/metrics"
+	"github.com/9688101/hx-admin/core/tracing"
 	"github.com/9688101/hx-admin/global"
-	"github.com/9688101/hx-admin/model"
-	"github.com/9688101/hx-admin/utils/env"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 var LOG_DB *gorm.DB
 
-func chooseDB(envName string) (*gorm.DB, error) {
-	dsn := os.Getenv(envName)
+// 数据库迁移状态，供就绪检查使用
+const (
+	MigrationPending   = "pending"
+	MigrationCompleted = "completed"
+	MigrationSkipped   = "skipped" // 从节点不执行迁移
+)
+
+var MigrationState = MigrationPending
+var LogMigrationState = MigrationPending
+
+func chooseDB(cfg config.DB) (*gorm.DB, error) {
+	dsn := cfg.Dsn()
 
 	switch {
 	case strings.HasPrefix(dsn, "postgres://"):
@@ -32,99 +42,88 @@ func chooseDB(envName string) (*gorm.DB, error) {
 	}
 }
 
+// openDB 连接数据库，并设置连接池、监控指标与链路追踪
+func openDB(cfg config.DB, name string) (*gorm.DB, error) {
+	db, err := chooseDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	metrics.RegisterDB(name, setDBConns(db, cfg))
+	if tracing.Enabled() {
+		if err = tracing.RegisterGORMCallbacks(db, name); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// ConnectDB 只连接主数据库与日志数据库而不执行迁移，供命令行子命令使用
+func ConnectDB() error {
+	var err error
+	DB, err = openDB(global.GetConfig().Database, "db")
+	if err != nil {
+		return err
+	}
+	if global.GetConfig().LogDatabase.Dsn() == "" {
+		LOG_DB = DB
+		return nil
+	}
+	LOG_DB, err = openDB(global.GetConfig().LogDatabase, "log_db")
+	return err
+}
+
 func InitDB() {
 	var err error
-	DB, err = chooseDB("SQL_DSN")
+	DB, err = openDB(global.GetConfig().Database, "db")
 	if err != nil {
 		logger.FatalLog("failed to initialize database: " + err.Error())
 		return
 	}
 
-	sqlDB := setDBConns(DB)
-
 	if !global.IsMasterNode {
+		MigrationState = MigrationSkipped
 		return
 	}
 
-	if UsingMySQL {
-		_, _ = sqlDB.Exec("DROP INDEX idx_channels_key ON channels;") // TODO: delete this line when most users have upgraded
-	}
-
 	logger.SysLog("database migration started")
-	if err = migrateDB(); err != nil {
+	if _, err = MigrateUp(DB, Migrations, 0); err != nil {
 		logger.FatalLog("failed to migrate database: " + err.Error())
 		return
 	}
+	MigrationState = MigrationCompleted
 	logger.SysLog("database migrated")
 }
 
-func migrateDB() error {
-	var err error
-	// if err = DB.AutoMigrate(&Channel{}); err != nil {
-	// 	return err
-	// }
-	if err = DB.AutoMigrate(&model.Token{}); err != nil {
-		return err
-	}
-	if err = DB.AutoMigrate(&model.User{}); err != nil {
-		return err
-	}
-	if err = DB.AutoMigrate(&model.Option{}); err != nil {
-		return err
-	}
-	// if err = DB.AutoMigrate(&Redemption{}); err != nil {
-	// 	return err
-	// }
-	// if err = DB.AutoMigrate(&Ability{}); err != nil {
-	// 	return err
-	// }
-	// if err = DB.AutoMigrate(&Log{}); err != nil {
-	// 	return err
-	// }
-	// if err = DB.AutoMigrate(&Channel{}); err != nil {
-	// 	return err
-	// }
-	return nil
-}
-
 func InitLogDB() {
-	if os.Getenv("LOG_SQL_DSN") == "" {
+	if global.GetConfig().LogDatabase.Dsn() == "" {
 		LOG_DB = DB
+		LogMigrationState = MigrationState
 		return
 	}
 
 	logger.SysLog("using secondary database for table logs")
 	var err error
-	LOG_DB, err = chooseDB("LOG_SQL_DSN")
+	LOG_DB, err = openDB(global.GetConfig().LogDatabase, "log_db")
 	if err != nil {
 		logger.FatalLog("failed to initialize secondary database: " + err.Error())
 		return
 	}
 
-	setDBConns(LOG_DB)
-
 	if !global.IsMasterNode {
+		LogMigrationState = MigrationSkipped
 		return
 	}
 
 	logger.SysLog("secondary database migration started")
-	err = migrateLOGDB()
-	if err != nil {
+	if _, err = MigrateUp(LOG_DB, LogMigrations, 0); err != nil {
 		logger.FatalLog("failed to migrate secondary database: " + err.Error())
 		return
 	}
+	LogMigrationState = MigrationCompleted
 	logger.SysLog("secondary database migrated")
 }
 
-func migrateLOGDB() error {
-	var err error
-	if err = LOG_DB.AutoMigrate(&model.Log{}); err != nil {
-		return err
-	}
-	return nil
-}
-
-func setDBConns(db *gorm.DB) *sql.DB {
+func setDBConns(db *gorm.DB, cfg config.DB) *sql.DB {
 	if global.DebugSQLEnabled {
 		db = db.Debug()
 	}
@@ -135,9 +134,9 @@ func setDBConns(db *gorm.DB) *sql.DB {
 		return nil
 	}
 
-	sqlDB.SetMaxIdleConns(env.Int("SQL_MAX_IDLE_CONNS", 100))
-	sqlDB.SetMaxOpenConns(env.Int("SQL_MAX_OPEN_CONNS", 1000))
-	sqlDB.SetConnMaxLifetime(time.Second * time.Duration(env.Int("SQL_MAX_LIFETIME", 60)))
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(time.Second * time.Duration(cfg.MaxLifetime))
 	return sqlDB
 }
 
@@ -159,3 +158,13 @@ func CloseDB() error {
 	}
 	return closeDB(DB)
 }
+
+// ResizeDBConns 按当前配置调整连接池大小，配置热加载时调用
+func ResizeDBConns() {
+	if DB != nil {
+		setDBConns(DB, global.GetConfig().Database)
+	}
+	if LOG_DB != nil && LOG_DB != DB {
+		setDBConns(LOG_DB, global.GetConfig().LogDatabase)
+	}
+}
@@ -0,0 +1,250 @@
+package initialize
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils"
+)
+
+// Migration 是一次带版本号的数据库变更，已执行的版本记录在 schema_migrations 表中
+// Up 必须是幂等的：对于从旧版本（仅使用 AutoMigrate）升级上来的数据库，表可能已经存在
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // 为 nil 表示不支持回滚
+}
+
+const (
+	migrationLockId         = 1
+	migrationLockStaleAfter = 30 * time.Minute // 超过该时间的锁视为持有者已崩溃
+	migrationLockRetry      = time.Second
+)
+
+var migrationLockTimeout = 5 * time.Minute // 等待其他节点完成迁移的最长时间
+
+// MigrateUp 执行版本号不超过 target 的所有未执行的迁移，target 为 0 表示执行全部，返回执行的数量
+func MigrateUp(db *gorm.DB, migrations []Migration, target int) (int, error) {
+	unlock, err := lockMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	for version := range applied {
+		if findMigration(migrations, version) == nil {
+			logger.SysWarnf("database has migration %d applied which is unknown to this version of the program", version)
+		}
+	}
+	count := 0
+	for _, m := range sortedMigrations(migrations) {
+		if target > 0 && m.Version > target {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		logger.SysLogf("applying migration %d_%s", m.Version, m.Name)
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&model.SchemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				AppliedAt: utils.GetTimestamp(),
+			}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown 按版本号从大到小回滚最近执行的 steps 个迁移，返回回滚的数量
+func MigrateDown(db *gorm.DB, migrations []Migration, steps int) (int, error) {
+	unlock, err := lockMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return 0, err
+	}
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+	count := 0
+	for _, version := range versions {
+		if count >= steps {
+			break
+		}
+		m := findMigration(migrations, version)
+		if m == nil {
+			return count, fmt.Errorf("migration %d is unknown to this version of the program", version)
+		}
+		if m.Down == nil {
+			return count, fmt.Errorf("migration %d_%s can not be rolled back", m.Version, m.Name)
+		}
+		logger.SysLogf("rolling back migration %d_%s", m.Version, m.Name)
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&model.SchemaMigration{Version: m.Version}).Error
+		})
+		if err != nil {
+			return count, fmt.Errorf("rollback of migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// GetMigrationStatus 返回每个迁移是否已执行，包括数据库中存在但当前程序不认识的迁移
+func GetMigrationStatus(db *gorm.DB, migrations []Migration) ([]*model.MigrationStatus, error) {
+	if err := createMigrationTables(db); err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	var status []*model.MigrationStatus
+	for _, m := range sortedMigrations(migrations) {
+		s := &model.MigrationStatus{
+			Version: m.Version,
+			Name:    m.Name,
+		}
+		if record, ok := applied[m.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = record.AppliedAt
+		}
+		status = append(status, s)
+	}
+	for version, record := range applied {
+		if findMigration(migrations, version) == nil {
+			status = append(status, &model.MigrationStatus{
+				Version:   version,
+				Name:      record.Name,
+				Applied:   true,
+				AppliedAt: record.AppliedAt,
+				Unknown:   true,
+			})
+		}
+	}
+	sort.Slice(status, func(i, j int) bool {
+		return status[i].Version < status[j].Version
+	})
+	return status, nil
+}
+
+func createMigrationTables(db *gorm.DB) error {
+	var err error
+	// 多个节点同时启动时建表可能冲突，稍后重试即可
+	for i := 0; i < 3; i++ {
+		if err = db.AutoMigrate(&model.SchemaMigration{}, &model.SchemaMigrationLock{}); err == nil {
+			return nil
+		}
+		time.Sleep(migrationLockRetry)
+	}
+	return err
+}
+
+// lockMigrations 通过向 schema_migration_locks 插入同一主键的记录实现跨节点的互斥，
+// 不依赖 Redis，三种数据库均可使用
+func lockMigrations(db *gorm.DB) (unlock func(), err error) {
+	if err = createMigrationTables(db); err != nil {
+		return nil, err
+	}
+	owner := utils.GetUUID()
+	// 主键冲突是预期内的，不需要 GORM 打印错误日志
+	quiet := db.Session(&gorm.Session{Logger: db.Logger.LogMode(gormlogger.Silent)})
+	deadline := time.Now().Add(migrationLockTimeout)
+	waiting := false
+	for {
+		lock := &model.SchemaMigrationLock{
+			Id:       migrationLockId,
+			Owner:    owner,
+			LockedAt: utils.GetTimestamp(),
+		}
+		createErr := quiet.Create(lock).Error
+		if createErr == nil {
+			break
+		}
+		var current model.SchemaMigrationLock
+		err = quiet.First(&current, "id = ?", migrationLockId).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// 锁刚好被释放，或者插入失败的原因不是主键冲突
+			if time.Now().After(deadline) {
+				return nil, createErr
+			}
+		case err != nil:
+			return nil, err
+		case utils.GetTimestamp()-current.LockedAt > int64(migrationLockStaleAfter.Seconds()):
+			logger.SysWarnf("removing stale migration lock held by %s", current.Owner)
+			db.Where("id = ? AND owner = ?", migrationLockId, current.Owner).Delete(&model.SchemaMigrationLock{})
+			continue
+		case time.Now().After(deadline):
+			return nil, fmt.Errorf("timed out waiting for the migration lock held by %s", current.Owner)
+		case !waiting:
+			logger.SysLog("another node is migrating the database, waiting")
+			waiting = true
+		}
+		time.Sleep(migrationLockRetry)
+	}
+	return func() {
+		err := db.Where("id = ? AND owner = ?", migrationLockId, owner).Delete(&model.SchemaMigrationLock{}).Error
+		if err != nil {
+			logger.SysError("failed to release migration lock: " + err.Error())
+		}
+	}, nil
+}
+
+func appliedMigrations(db *gorm.DB) (map[int]*model.SchemaMigration, error) {
+	var records []*model.SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]*model.SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+func sortedMigrations(migrations []Migration) []Migration {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	return sorted
+}
+
+func findMigration(migrations []Migration, version int) *Migration {
+	for i := range migrations {
+		if migrations[i].Version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
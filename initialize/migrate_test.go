@@ -0,0 +1,157 @@
+package initialize
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/9688101/hx-admin/model"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestMigrations(t *testing.T) {
+	for name, migrations := range map[string][]Migration{"main": Migrations, "log": LogMigrations} {
+		Convey("TestMigrations "+name, t, func() {
+			db := openTestDB(t)
+
+			count, err := MigrateUp(db, migrations, 0)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, len(migrations))
+			status, err := GetMigrationStatus(db, migrations)
+			So(err, ShouldBeNil)
+			So(status, ShouldHaveLength, len(migrations))
+			for _, s := range status {
+				So(s.Applied, ShouldBeTrue)
+			}
+
+			// 执行全部迁移后的表结构包含 model 中的所有字段
+			for _, value := range []any{&model.Token{}, &model.User{}, &model.Option{}, &model.OptionRevision{}, &model.UserBan{},
+				&model.Mail{}, &model.Webhook{}, &model.WebhookEvent{}, &model.WebhookDelivery{}, &model.UserNotification{},
+				&model.Announcement{}, &model.AnnouncementReceipt{}, &model.Log{}} {
+				if !db.Migrator().HasTable(value) {
+					continue
+				}
+				stmt := &gorm.Statement{DB: db}
+				So(stmt.Parse(value), ShouldBeNil)
+				for _, field := range stmt.Schema.Fields {
+					if field.DBName != "" {
+						So(db.Migrator().HasColumn(value, field.DBName), ShouldBeTrue)
+					}
+				}
+			}
+
+			// 已执行的迁移不会重复执行
+			count, err = MigrateUp(db, migrations, 0)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 0)
+
+			// 每个迁移都必须是幂等的
+			for _, m := range migrations {
+				So(m.Up(db), ShouldBeNil)
+			}
+
+			count, err = MigrateDown(db, migrations, len(migrations))
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, len(migrations))
+			var applied int64
+			So(db.Model(&model.SchemaMigration{}).Count(&applied).Error, ShouldBeNil)
+			So(applied, ShouldEqual, 0)
+
+			// 回滚后可以重新执行
+			count, err = MigrateUp(db, migrations, 0)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, len(migrations))
+		})
+	}
+}
+
+func TestMigrateTarget(t *testing.T) {
+	Convey("TestMigrateTarget", t, func() {
+		db := openTestDB(t)
+		count, err := MigrateUp(db, Migrations, 2)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+		So(db.Migrator().HasTable(&model.User{}), ShouldBeTrue)
+		So(db.Migrator().HasTable(&model.Option{}), ShouldBeFalse)
+		// 早期的迁移使用建表时的结构，不包含之后的迁移添加的字段
+		So(db.Migrator().HasColumn(&model.User{}, "Group"), ShouldBeFalse)
+		_, err = MigrateUp(db, Migrations, 8)
+		So(err, ShouldBeNil)
+		So(db.Migrator().HasColumn(&model.Mail{}, "TextContent"), ShouldBeFalse)
+		_, err = MigrateUp(db, Migrations, 9)
+		So(err, ShouldBeNil)
+		So(db.Migrator().HasColumn(&model.Mail{}, "TextContent"), ShouldBeTrue)
+		count, err = MigrateDown(db, Migrations, 7)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 7)
+
+		count, err = MigrateDown(db, Migrations, 1)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		So(db.Migrator().HasTable(&model.User{}), ShouldBeFalse)
+		So(db.Migrator().HasTable(&model.Token{}), ShouldBeTrue)
+	})
+}
+
+func TestBackfillAffCodes(t *testing.T) {
+	Convey("TestBackfillAffCodes", t, func() {
+		db := openTestDB(t)
+		_, err := MigrateUp(db, Migrations, 5)
+		So(err, ShouldBeNil)
+		So(db.Create(&userV2{Username: "root", Password: "x", AccessToken: "a"}).Error, ShouldBeNil)
+		So(db.Create(&userV2{Username: "u1", Password: "x", AccessToken: "b", AffCode: "aaaa"}).Error, ShouldBeNil)
+
+		// 生成的邀请码已被使用时重新生成
+		oldNewAffCode := newAffCode
+		codes := []string{"aaaa", "aaaa", "bbbb"}
+		newAffCode = func() string {
+			code := codes[0]
+			codes = codes[1:]
+			return code
+		}
+		defer func() { newAffCode = oldNewAffCode }()
+		_, err = MigrateUp(db, Migrations, 0)
+		So(err, ShouldBeNil)
+		var users []model.User
+		So(db.Order("id").Find(&users).Error, ShouldBeNil)
+		So([]string{users[0].AffCode, users[1].AffCode}, ShouldResemble, []string{"bbbb", "aaaa"})
+	})
+}
+
+func TestMigrationLock(t *testing.T) {
+	Convey("TestMigrationLock", t, func() {
+		db := openTestDB(t)
+		timeout := migrationLockTimeout
+		migrationLockTimeout = 0
+		defer func() { migrationLockTimeout = timeout }()
+
+		unlock, err := lockMigrations(db)
+		So(err, ShouldBeNil)
+		_, err = MigrateUp(db, Migrations, 0)
+		So(err, ShouldNotBeNil)
+		unlock()
+
+		// 持有者崩溃后锁会过期
+		stale := &model.SchemaMigrationLock{
+			Id:       migrationLockId,
+			Owner:    "crashed",
+			LockedAt: time.Now().Add(-2 * migrationLockStaleAfter).Unix(),
+		}
+		So(db.Create(stale).Error, ShouldBeNil)
+		_, err = MigrateUp(db, Migrations, 0)
+		So(err, ShouldBeNil)
+	})
+}
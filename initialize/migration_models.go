@@ -0,0 +1,262 @@
+package initialize
+
+// 已发布的迁移使用的表结构快照，与迁移发布时的 model 保持一致，不随 model 修改。
+// 修改 model 时应追加新的迁移，而不是修改这里的结构。
+
+// tokenV1 迁移 1 create_tokens 时的 model.Token
+type tokenV1 struct {
+	Id           int
+	UserId       int
+	Key          string  `gorm:"type:char(48);uniqueIndex"`
+	Status       int     `gorm:"default:1"`
+	Name         string  `gorm:"index"`
+	CreatedTime  int64   `gorm:"bigint"`
+	AccessedTime int64   `gorm:"bigint"`
+	ExpiredTime  int64   `gorm:"bigint;default:-1"`
+	UsedQuota    int64   `gorm:"bigint;default:0"`
+	Subnet       *string `gorm:"default:''"`
+}
+
+func (tokenV1) TableName() string {
+	return "tokens"
+}
+
+// userV2 迁移 2 create_users 时的 model.User，分组由迁移 11 add_users_group 添加
+type userV2 struct {
+	Id          int
+	Username    string `gorm:"unique;index"`
+	Password    string `gorm:"not null;"`
+	DisplayName string `gorm:"index"`
+	Role        int    `gorm:"type:int;default:1"`
+	Status      int    `gorm:"type:int;default:1"`
+	Email       string `gorm:"index"`
+	GitHubId    string `gorm:"column:github_id;index"`
+	WeChatId    string `gorm:"column:wechat_id;index"`
+	LarkId      string `gorm:"column:lark_id;index"`
+	OidcId      string `gorm:"column:oidc_id;index"`
+	AccessToken string `gorm:"type:char(32);column:access_token;uniqueIndex"`
+	UsedQuota   int64  `gorm:"bigint;default:0;column:used_quota"`
+	AffCode     string `gorm:"type:varchar(32);column:aff_code;uniqueIndex"`
+	InviterId   int    `gorm:"type:int;column:inviter_id;index"`
+}
+
+func (userV2) TableName() string {
+	return "users"
+}
+
+// optionV3 迁移 3 create_options 时的 model.Option
+type optionV3 struct {
+	Key   string `gorm:"primaryKey"`
+	Value string
+}
+
+func (optionV3) TableName() string {
+	return "options"
+}
+
+// optionRevisionV4 迁移 4 create_option_revisions 时的 model.OptionRevision
+type optionRevisionV4 struct {
+	Id         int
+	Key        string `gorm:"type:varchar(64);index"`
+	OldValue   string `gorm:"type:text"`
+	NewValue   string `gorm:"type:text"`
+	Redacted   bool   `gorm:"default:false"`
+	UserId     int    `gorm:"index"`
+	Username   string `gorm:"default:''"`
+	RequestId  string `gorm:"default:''"`
+	RollbackOf int    `gorm:"default:0"`
+	CreatedAt  int64  `gorm:"bigint;index"`
+}
+
+func (optionRevisionV4) TableName() string {
+	return "option_revisions"
+}
+
+// announcementV14 迁移 14 resanitize_announcements 使用的 model.Announcement 中的字段
+type announcementV14 struct {
+	Id      int
+	Title   string
+	Content string
+}
+
+func (announcementV14) TableName() string {
+	return "announcements"
+}
+
+// userBanV7 迁移 7 create_user_bans 时的 model.UserBan
+type userBanV7 struct {
+	Id         int
+	UserId     int    `gorm:"uniqueIndex"`
+	Reason     string `gorm:"type:varchar(255);default:''"`
+	ExpiresAt  int64  `gorm:"bigint;index"`
+	OperatorId int    `gorm:"default:0"`
+	CreatedAt  int64  `gorm:"bigint"`
+}
+
+func (userBanV7) TableName() string {
+	return "user_bans"
+}
+
+// mailV8 迁移 8 create_mails 时的 model.Mail，纯文本内容由迁移 9 add_mails_text_content 添加
+type mailV8 struct {
+	Id            int
+	Receiver      string `gorm:"type:varchar(255);index"`
+	Subject       string `gorm:"type:varchar(255)"`
+	Content       string `gorm:"type:text"`
+	Status        int    `gorm:"type:int;default:1;index:idx_mails_status_next_attempt,priority:1"`
+	Attempts      int    `gorm:"default:0"`
+	NextAttemptAt int64  `gorm:"bigint;index:idx_mails_status_next_attempt,priority:2"`
+	LockedBy      string `gorm:"type:varchar(64);default:''"`
+	LockedUntil   int64  `gorm:"bigint;default:0"`
+	LastError     string `gorm:"type:text"`
+	CreatedAt     int64  `gorm:"bigint;index"`
+	SentAt        int64  `gorm:"bigint;default:0"`
+}
+
+func (mailV8) TableName() string {
+	return "mails"
+}
+
+// mailV9 迁移 9 add_mails_text_content 添加的字段
+type mailV9 struct {
+	Id          int
+	TextContent string `gorm:"type:text"`
+}
+
+func (mailV9) TableName() string {
+	return "mails"
+}
+
+// webhookV10、webhookEventV10 与 webhookDeliveryV10 迁移 10 create_webhooks 时的 model.Webhook、
+// model.WebhookEvent 与 model.WebhookDelivery
+type webhookV10 struct {
+	Id          int
+	Name        string `gorm:"type:varchar(64)"`
+	URL         string `gorm:"type:varchar(1024)"`
+	Secret      string `gorm:"type:varchar(128)"`
+	Events      string `gorm:"type:varchar(1024)"`
+	Status      int    `gorm:"type:int;default:1"`
+	Description string `gorm:"type:varchar(255);default:''"`
+	CreatedAt   int64  `gorm:"bigint"`
+	UpdatedAt   int64  `gorm:"bigint"`
+}
+
+func (webhookV10) TableName() string {
+	return "webhooks"
+}
+
+type webhookEventV10 struct {
+	Id           int
+	Event        string `gorm:"type:varchar(64);index"`
+	Payload      string `gorm:"type:text"`
+	CreatedAt    int64  `gorm:"bigint;index"`
+	DispatchedAt int64  `gorm:"bigint;default:0;index"`
+}
+
+func (webhookEventV10) TableName() string {
+	return "webhook_events"
+}
+
+type webhookDeliveryV10 struct {
+	Id             int
+	WebhookId      int    `gorm:"index"`
+	EventId        int    `gorm:"index"`
+	Event          string `gorm:"type:varchar(64)"`
+	RedeliveryOf   int    `gorm:"default:0"`
+	Status         int    `gorm:"type:int;default:1;index:idx_webhook_deliveries_status_next_attempt,priority:1"`
+	Attempts       int    `gorm:"default:0"`
+	NextAttemptAt  int64  `gorm:"bigint;index:idx_webhook_deliveries_status_next_attempt,priority:2"`
+	LockedBy       string `gorm:"type:varchar(64);default:''"`
+	LockedUntil    int64  `gorm:"bigint;default:0"`
+	ResponseStatus int    `gorm:"default:0"`
+	ResponseBody   string `gorm:"type:text"`
+	Duration       int64  `gorm:"bigint;default:0"`
+	LastError      string `gorm:"type:text"`
+	CreatedAt      int64  `gorm:"bigint;index"`
+	DeliveredAt    int64  `gorm:"bigint;default:0"`
+}
+
+func (webhookDeliveryV10) TableName() string {
+	return "webhook_deliveries"
+}
+
+// userV11 迁移 11 add_users_group 添加的字段
+type userV11 struct {
+	Id    int
+	Group string `gorm:"type:varchar(32);default:'default'"`
+}
+
+func (userV11) TableName() string {
+	return "users"
+}
+
+// userNotificationV12 迁移 12 create_user_notifications 时的 model.UserNotification
+type userNotificationV12 struct {
+	Id        int
+	UserId    int    `gorm:"index:idx_user_notifications_user_read,priority:1"`
+	Title     string `gorm:"type:varchar(255)"`
+	Content   string `gorm:"type:text"`
+	Level     string `gorm:"type:varchar(16);default:'info'"`
+	SenderId  int    `gorm:"default:0"`
+	ReadAt    int64  `gorm:"bigint;default:0;index:idx_user_notifications_user_read,priority:2"`
+	CreatedAt int64  `gorm:"bigint"`
+}
+
+func (userNotificationV12) TableName() string {
+	return "user_notifications"
+}
+
+// announcementV13 与 announcementReceiptV13 迁移 13 create_announcements 时的 model.Announcement 与 model.AnnouncementReceipt
+type announcementV13 struct {
+	Id        int
+	Title     string `gorm:"type:varchar(255)"`
+	Content   string `gorm:"type:text"`
+	Severity  string `gorm:"type:varchar(16);default:'info'"`
+	StartTime int64  `gorm:"bigint;default:0;index"`
+	EndTime   int64  `gorm:"bigint;default:0"`
+	Roles     string `gorm:"type:varchar(64);default:''"`
+	Groups    string `gorm:"type:varchar(255);default:''"`
+	CreatorId int    `gorm:"default:0"`
+	CreatedAt int64  `gorm:"bigint"`
+	UpdatedAt int64  `gorm:"bigint"`
+}
+
+func (announcementV13) TableName() string {
+	return "announcements"
+}
+
+type announcementReceiptV13 struct {
+	Id             int
+	AnnouncementId int   `gorm:"uniqueIndex:idx_announcement_receipts_user,priority:1"`
+	UserId         int   `gorm:"uniqueIndex:idx_announcement_receipts_user,priority:2"`
+	ReadAt         int64 `gorm:"bigint;default:0"`
+	DismissedAt    int64 `gorm:"bigint;default:0"`
+}
+
+func (announcementReceiptV13) TableName() string {
+	return "announcement_receipts"
+}
+
+// logV1 日志数据库迁移 1 create_logs 时的 model.Log
+type logV1 struct {
+	Id                int
+	UserId            int   `gorm:"index"`
+	CreatedAt         int64 `gorm:"bigint;index:idx_created_at_type"`
+	Type              int   `gorm:"index:idx_created_at_type"`
+	Content           string
+	Username          string `gorm:"index:index_username_model_name,priority:2;default:''"`
+	TokenName         string `gorm:"index;default:''"`
+	ModelName         string `gorm:"index;index:index_username_model_name,priority:1;default:''"`
+	Quota             int    `gorm:"default:0"`
+	PromptTokens      int    `gorm:"default:0"`
+	CompletionTokens  int    `gorm:"default:0"`
+	ChannelId         int    `gorm:"index"`
+	RequestId         string `gorm:"default:''"`
+	ElapsedTime       int64  `gorm:"default:0"`
+	IsStream          bool   `gorm:"default:false"`
+	SystemPromptReset bool   `gorm:"default:false"`
+}
+
+func (logV1) TableName() string {
+	return "logs"
+}
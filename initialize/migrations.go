@@ -0,0 +1,204 @@
+package initialize
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/9688101/hx-admin/utils"
+)
+
+// Migrations 主数据库的迁移，只能在末尾追加，已发布的迁移不要修改
+// 前几个迁移建立的是引入版本化迁移之前由 AutoMigrate 维护的表。
+// 迁移使用 migration_models.go 中迁移发布时的表结构快照，不使用会随版本修改的 model
+var Migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_tokens",
+		Up:      autoMigrate(&tokenV1{}),
+		Down:    dropTable(&tokenV1{}),
+	},
+	{
+		Version: 2,
+		Name:    "create_users",
+		Up:      autoMigrate(&userV2{}),
+		Down:    dropTable(&userV2{}),
+	},
+	{
+		Version: 3,
+		Name:    "create_options",
+		Up:      autoMigrate(&optionV3{}),
+		Down:    dropTable(&optionV3{}),
+	},
+	{
+		Version: 4,
+		Name:    "create_option_revisions",
+		Up:      autoMigrate(&optionRevisionV4{}),
+		Down:    dropTable(&optionRevisionV4{}),
+	},
+	{
+		// 旧版本在 MySQL 上每次启动都会尝试删除该索引
+		Version: 5,
+		Name:    "drop_idx_channels_key",
+		Up: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() != "mysql" || !tx.Migrator().HasIndex("channels", "idx_channels_key") {
+				return nil
+			}
+			return tx.Migrator().DropIndex("channels", "idx_channels_key")
+		},
+		Down: noop,
+	},
+	{
+		// 自动创建的 root 用户没有邀请码
+		Version: 6,
+		Name:    "backfill_user_aff_codes",
+		Up: func(tx *gorm.DB) error {
+			var users []*userV2
+			if err := tx.Select("id").Where("aff_code = ? OR aff_code IS NULL", "").Find(&users).Error; err != nil {
+				return err
+			}
+			for _, user := range users {
+				affCode, err := newUnusedAffCode(tx)
+				if err != nil {
+					return err
+				}
+				if err := tx.Model(user).Update("aff_code", affCode).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: noop,
+	},
+	{
+		Version: 7,
+		Name:    "create_user_bans",
+		Up:      autoMigrate(&userBanV7{}),
+		Down:    dropTable(&userBanV7{}),
+	},
+	{
+		Version: 8,
+		Name:    "create_mails",
+		Up:      autoMigrate(&mailV8{}),
+		Down:    dropTable(&mailV8{}),
+	},
+	{
+		Version: 9,
+		Name:    "add_mails_text_content",
+		Up:      addColumn(&mailV9{}, "TextContent"),
+		Down:    dropColumn(&mailV9{}, "TextContent"),
+	},
+	{
+		Version: 10,
+		Name:    "create_webhooks",
+		Up:      autoMigrate(&webhookV10{}, &webhookEventV10{}, &webhookDeliveryV10{}),
+		Down:    dropTable(&webhookV10{}, &webhookEventV10{}, &webhookDeliveryV10{}),
+	},
+	{
+		Version: 11,
+		Name:    "add_users_group",
+		Up:      addColumn(&userV11{}, "Group"),
+		Down:    dropColumn(&userV11{}, "Group"),
+	},
+	{
+		Version: 12,
+		Name:    "create_user_notifications",
+		Up:      autoMigrate(&userNotificationV12{}),
+		Down:    dropTable(&userNotificationV12{}),
+	},
+	{
+		Version: 13,
+		Name:    "create_announcements",
+		Up:      autoMigrate(&announcementV13{}, &announcementReceiptV13{}),
+		Down:    dropTable(&announcementV13{}, &announcementReceiptV13{}),
+	},
+	{
+		// 旧的清理方式会保留代码块中的 HTML，并且不清理标题，按新的方式重新清理已有的公告
+		Version: 14,
+		Name:    "resanitize_announcements",
+		Up: func(tx *gorm.DB) error {
+			var announcements []*announcementV14
+			if err := tx.Select("id", "title", "content").Find(&announcements).Error; err != nil {
+				return err
+			}
+			for _, announcement := range announcements {
+				err := tx.Model(announcement).Updates(map[string]any{
+					"title":   utils.SanitizeMarkdown(announcement.Title),
+					"content": utils.SanitizeMarkdown(announcement.Content),
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: noop,
+	},
+}
+
+// LogMigrations 日志数据库的迁移，仅在单独配置了 LOG_SQL_DSN 时执行
+var LogMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "create_logs",
+		Up:      autoMigrate(&logV1{}),
+		Down:    dropTable(&logV1{}),
+	},
+}
+
+const affCodeMaxAttempts = 100
+
+// newAffCode 生成邀请码，测试中替换以模拟冲突
+var newAffCode = func() string {
+	return utils.GetRandomString(4)
+}
+
+// newUnusedAffCode 生成一个未被使用的邀请码，与已有的邀请码冲突时重新生成。
+// 先查询再更新，避免违反唯一索引导致 PostgreSQL 的事务中止
+func newUnusedAffCode(tx *gorm.DB) (string, error) {
+	for i := 0; i < affCodeMaxAttempts; i++ {
+		affCode := newAffCode()
+		var count int64
+		if err := tx.Model(&userV2{}).Where("aff_code = ?", affCode).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return affCode, nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate an unused aff code after %d attempts", affCodeMaxAttempts)
+}
+
+func autoMigrate(values ...any) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.AutoMigrate(values...)
+	}
+}
+
+func dropTable(values ...any) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		return tx.Migrator().DropTable(values...)
+	}
+}
+
+func addColumn(value any, field string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if tx.Migrator().HasColumn(value, field) {
+			return nil
+		}
+		return tx.Migrator().AddColumn(value, field)
+	}
+}
+
+func dropColumn(value any, field string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if !tx.Migrator().HasColumn(value, field) {
+			return nil
+		}
+		return tx.Migrator().DropColumn(value, field)
+	}
+}
+
+func noop(tx *gorm.DB) error {
+	return nil
+}
@@ -2,55 +2,68 @@ package initialize
 
 import (
 	"context"
-	"os"
+	"errors"
 	"strings"
 	"time"
 
 	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/core/metrics"
+	"github.com/9688101/hx-admin/core/tracing"
+	"github.com/9688101/hx-admin/global"
 	"github.com/go-redis/redis/v8"
 )
 
-type Redis struct {
-	Name         string   `mapstructure:"name" json:"name" yaml:"name"`                         // 代表当前实例的名字
-	Addr         string   `mapstructure:"addr" json:"addr" yaml:"addr"`                         // 服务器地址:端口
-	Password     string   `mapstructure:"password" json:"password" yaml:"password"`             // 密码
-	DB           int      `mapstructure:"db" json:"db" yaml:"db"`                               // 单实例模式下redis的哪个数据库
-	UseCluster   bool     `mapstructure:"useCluster" json:"useCluster" yaml:"useCluster"`       // 是否使用集群模式
-	ClusterAddrs []string `mapstructure:"clusterAddrs" json:"clusterAddrs" yaml:"clusterAddrs"` // 集群模式下的节点地址列表
-}
-
-var RDB redis.Cmdable
+var RDB redis.UniversalClient
 var RedisEnabled = true
 
 // InitRedisClient This function is called after init()
 func InitRedisClient() (err error) {
-	if os.Getenv("REDIS_CONN_STRING") == "" {
+	cfg := global.GetConfig().Redis
+	if !cfg.Enabled() {
 		RedisEnabled = false
 		logger.SysLog("REDIS_CONN_STRING not set, Redis is not enabled")
 		return nil
 	}
-	if os.Getenv("SYNC_FREQUENCY") == "" {
+	if global.GetConfig().System.SyncFrequency == 0 {
 		RedisEnabled = false
 		logger.SysLog("SYNC_FREQUENCY not set, Redis is disabled")
 		return nil
 	}
-	redisConnString := os.Getenv("REDIS_CONN_STRING")
-	if os.Getenv("REDIS_MASTER_NAME") == "" {
+	switch {
+	case cfg.ConnString != "" && cfg.MasterName == "":
 		logger.SysLog("Redis is enabled")
-		opt, err := redis.ParseURL(redisConnString)
+		opt, err := redis.ParseURL(cfg.ConnString)
 		if err != nil {
 			logger.FatalLog("failed to parse Redis connection string: " + err.Error())
 		}
 		RDB = redis.NewClient(opt)
-	} else {
-		// cluster mode
+	case cfg.ConnString != "":
+		// sentinel or cluster mode
+		logger.SysLog("Redis cluster mode enabled")
+		RDB = redis.NewUniversalClient(&redis.UniversalOptions{
+			Addrs:      strings.Split(cfg.ConnString, ","),
+			Password:   cfg.Password,
+			MasterName: cfg.MasterName,
+		})
+	case cfg.UseCluster && len(cfg.ClusterAddrs) > 0:
 		logger.SysLog("Redis cluster mode enabled")
 		RDB = redis.NewUniversalClient(&redis.UniversalOptions{
-			Addrs:      strings.Split(redisConnString, ","),
-			Password:   os.Getenv("REDIS_PASSWORD"),
-			MasterName: os.Getenv("REDIS_MASTER_NAME"),
+			Addrs:      cfg.ClusterAddrs,
+			Password:   cfg.Password,
+			MasterName: cfg.MasterName,
+		})
+	default:
+		logger.SysLog("Redis is enabled")
+		RDB = redis.NewClient(&redis.Options{
+			Addr:     cfg.Addr,
+			Password: cfg.Password,
+			DB:       cfg.DB,
 		})
 	}
+	RDB.AddHook(metrics.RedisHook{})
+	if tracing.Enabled() {
+		RDB.AddHook(tracing.RedisHook{})
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -61,30 +74,64 @@ func InitRedisClient() (err error) {
 	return err
 }
 
-func ParseRedisOption() *redis.Options {
-	opt, err := redis.ParseURL(os.Getenv("REDIS_CONN_STRING"))
-	if err != nil {
-		logger.FatalLog("failed to parse Redis connection string: " + err.Error())
-	}
-	return opt
-}
-
-func RedisSet(key string, value string, expiration time.Duration) error {
-	ctx := context.Background()
+func RedisSet(ctx context.Context, key string, value string, expiration time.Duration) error {
 	return RDB.Set(ctx, key, value, expiration).Err()
 }
 
-func RedisGet(key string) (string, error) {
-	ctx := context.Background()
+func RedisGet(ctx context.Context, key string) (string, error) {
 	return RDB.Get(ctx, key).Result()
 }
 
-func RedisDel(key string) error {
-	ctx := context.Background()
+func RedisDel(ctx context.Context, key string) error {
 	return RDB.Del(ctx, key).Err()
 }
 
-func RedisDecrease(key string, value int64) error {
-	ctx := context.Background()
+func RedisDecrease(ctx context.Context, key string, value int64) error {
 	return RDB.DecrBy(ctx, key, value).Err()
 }
+
+func RedisPublish(ctx context.Context, channel string, message string) error {
+	return RDB.Publish(ctx, channel, message).Err()
+}
+
+// RedisSubscribe the returned PubSub reconnects automatically, the caller should close it when done.
+func RedisSubscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return RDB.Subscribe(ctx, channels...)
+}
+
+// ErrRedisScanStop RedisScan 的回调返回该错误时停止遍历，RedisScan 返回 nil
+var ErrRedisScanStop = errors.New("stop scanning")
+
+// RedisScan 遍历匹配 match 的 key。集群模式下 SCAN 只遍历一个节点，因此在每个主节点上分别遍历，
+// 此时 f 会被并发调用
+func RedisScan(ctx context.Context, match string, count int64, f func(key string) error) error {
+	scan := func(ctx context.Context, client redis.Cmdable) error {
+		iter := client.Scan(ctx, 0, match, count).Iterator()
+		for iter.Next(ctx) {
+			if err := f(iter.Val()); err != nil {
+				return err
+			}
+		}
+		return iter.Err()
+	}
+	var err error
+	if cluster, ok := RDB.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return scan(ctx, client)
+		})
+	} else {
+		err = scan(ctx, RDB)
+	}
+	if errors.Is(err, ErrRedisScanStop) {
+		return nil
+	}
+	return err
+}
+
+// CloseRedis 关闭 Redis 连接，退出时在后台任务停止之后调用
+func CloseRedis() error {
+	if !RedisEnabled || RDB == nil {
+		return nil
+	}
+	return RDB.Close()
+}
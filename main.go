@@ -1,19 +1,31 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"errors"
+	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/9688101/hx-admin/cmd"
 	"github.com/9688101/hx-admin/core/i18n"
 	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/core/tracing"
 	"github.com/9688101/hx-admin/global"
 	"github.com/9688101/hx-admin/initialize"
 	"github.com/9688101/hx-admin/middleware"
 	"github.com/9688101/hx-admin/router"
 	"github.com/9688101/hx-admin/server"
 	"github.com/9688101/hx-admin/source"
+	"github.com/9688101/hx-admin/source/client"
+	"github.com/9688101/hx-admin/utils"
+	"github.com/9688101/hx-admin/utils/message"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
@@ -28,17 +40,37 @@ import (
 //go:embed web/build/*
 var buildFS embed.FS
 
+// workers 后台任务，退出时在 HTTP 服务停止后统一停止
+var workers = utils.NewWorkerGroup()
+
 // 主程序入口
 func main() {
-	source.Init()                                        // 初始化通用配置
-	logger.SetupLogger()                                 // 初始化日志系统
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	source.Init() // 初始化通用配置
+
+	// 指定了子命令时执行后退出，不启动 HTTP 服务
+	if args := flag.Args(); len(args) > 0 {
+		logger.SetupCommandLogger()
+		if err := cmd.Run(args); err != nil {
+			logger.FatalLog(err.Error())
+		}
+		return
+	}
+
+	logger.SetupLogger() // 初始化日志系统
+
+	if err := tracing.Init(global.GetConfig().Tracing, logger.LogDir); err != nil {
+		logger.FatalLog("failed to initialize tracing: " + err.Error())
+	}
 	logger.SysLogf("One API %s started", global.Version) // 记录启动日志
 
 	// 设置Gin运行模式
 	if os.Getenv("GIN_MODE") != gin.DebugMode {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	if global.DebugEnabled {
+	if global.DebugEnabled.Load() {
 		logger.SysLog("running in debug mode") // 调试模式日志
 	}
 
@@ -52,13 +84,6 @@ func main() {
 	if err != nil {
 		logger.FatalLog("database init error: " + err.Error())
 	}
-	defer func() { // 确保程序退出时关闭数据库连接
-		err := initialize.CloseDB()
-		if err != nil {
-			logger.FatalLog("failed to close database: " + err.Error())
-		}
-	}()
-
 	// 初始化Redis客户端
 	err = initialize.InitRedisClient()
 	if err != nil {
@@ -69,6 +94,11 @@ func main() {
 	server.InitOptionMap()
 	logger.SysLog(fmt.Sprintf("using theme %s", global.Theme)) // 记录主题信息
 
+	// 从数据库加载封禁列表
+	if err := server.LoadBannedUsers(signalCtx); err != nil {
+		logger.FatalLog("failed to load banned users: " + err.Error())
+	}
+
 	// 配置缓存设置
 	if initialize.RedisEnabled {
 		global.MemoryCacheEnabled = true // Redis启用时强制开启内存缓存
@@ -76,10 +106,34 @@ func main() {
 	if global.MemoryCacheEnabled {
 		logger.SysLog("memory cache enabled")
 		logger.SysLog(fmt.Sprintf("sync frequency: %d seconds", global.SyncFrequency))
+		workers.Go(func(ctx context.Context) { server.SyncOptions(ctx, global.SyncFrequency) }) // 定期全量同步配置，作为 Redis 推送的兜底
+	}
+	workers.Go(func(ctx context.Context) { server.SyncBannedUsers(ctx, global.SyncFrequency) }) // 定期同步封禁列表，作为 Redis 推送的兜底
+	if initialize.RedisEnabled {
+		workers.Go(server.SubscribeOptionChanges) // 订阅其他节点的配置变更
+		workers.Go(server.SubscribeUserBans)      // 订阅其他节点的封禁变更
+		workers.Go(server.SubscribeUserEvents)    // 订阅其他节点推送给用户的事件
+	}
+
+	// 加载 DKIM 签名私钥
+	smtpConfig := global.GetConfig().SMTP
+	if err := message.InitDKIM(smtpConfig.DKIMDomain, smtpConfig.DKIMSelector, smtpConfig.DKIMPrivateKeyFile); err != nil {
+		logger.FatalLog("failed to load DKIM private key: " + err.Error())
+	}
+	workers.Go(server.RunMailQueue)    // 发送发件队列中的邮件
+	workers.Go(server.RunWebhookQueue) // 投递领域事件 webhook
+	if !initialize.RedisEnabled || global.IsMasterNode {
+		workers.Go(server.RunAnnouncementScheduler) // 定时公告生效时通知在线的用户，启用 Redis 时只由主节点发布
+	}
+
+	if interval := global.GetConfig().Backup.Interval; interval > 0 && initialize.UsingSQLite && global.IsMasterNode {
+		logger.SysLogf("scheduled backup enabled, interval: %d minutes", interval)
+		workers.Go(func(ctx context.Context) { server.RunScheduledBackups(ctx, time.Duration(interval)*time.Minute) }) // 定时备份 SQLite 数据库
 	}
 
 	// 初始化API客户端
-	source.Init()
+	client.Init()
+	workers.Go(source.WatchReload) // 收到 SIGHUP 时重新加载配置
 
 	// 初始化国际化支持
 	if err := i18n.Init(); err != nil {
@@ -88,10 +142,14 @@ func main() {
 
 	// 创建Gin引擎实例
 	server := gin.New()
-	server.Use(gin.Recovery())         // 添加崩溃恢复中间件
+	server.Use(middleware.Recovery())  // 添加崩溃恢复中间件
 	server.Use(middleware.RequestId()) // 添加请求ID中间件
-	server.Use(middleware.Language())  // 添加语言中间件
-	middleware.SetUpLogger(server)     // 设置日志中间件
+	server.Use(middleware.Tracing())   // 添加链路追踪中间件
+	if global.EnableMetric {
+		server.Use(middleware.Metrics()) // 添加监控指标中间件
+	}
+	server.Use(middleware.Language()) // 添加语言中间件
+	middleware.SetUpLogger(server)    // 设置日志中间件
 
 	// 配置会话存储
 	store := cookie.NewStore([]byte(global.SessionSecret))
@@ -100,15 +158,56 @@ func main() {
 	router.SetRouter(server, buildFS) // 设置路由并传入前端构建文件
 
 	// 获取并设置服务端口
-	var port = os.Getenv("PORT")
-	if port == "" {
-		port = strconv.Itoa(*source.Port) // 使用默认端口
+	port := strconv.Itoa(global.GetConfig().System.Port)
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: server,
 	}
-	logger.SysLogf("server started on http://localhost:%s", port)
 
 	// 启动HTTP服务器
-	err = server.Run(":" + port)
-	if err != nil {
-		logger.FatalLog("failed to start HTTP server: " + err.Error())
+	go func() {
+		logger.SysLogf("server started on http://localhost:%s", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.FatalLog("failed to start HTTP server: " + err.Error())
+		}
+	}()
+
+	// 等待退出信号，再次收到信号时立即退出
+	<-signalCtx.Done()
+	stop()
+	shutdown(srv)
+}
+
+// shutdown 依次标记为未就绪、停止接收请求并等待处理中的请求结束、停止后台任务，最后关闭 Redis 和数据库
+func shutdown(srv *http.Server) {
+	logger.SysLog("shutting down")
+	global.ShuttingDown.Store(true)
+	if delay := global.GetConfig().System.ShutdownDelay; delay > 0 {
+		logger.SysLogf("waiting %d seconds for load balancers to notice", delay)
+		time.Sleep(time.Duration(delay) * time.Second)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(global.GetConfig().System.ShutdownTimeout)*time.Second)
+	defer cancel()
+	// SSE 长连接不会自己结束，先关闭，否则需要等到超时
+	server.CloseUserEventStreams()
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.SysError("failed to drain HTTP connections: " + err.Error())
+	}
+	if err := workers.Stop(ctx); err != nil {
+		logger.SysError("failed to stop background workers: " + err.Error())
+	}
+	if err := server.WaitNotifications(ctx); err != nil {
+		logger.SysError("failed to send pending notifications: " + err.Error())
+	}
+	if err := tracing.Shutdown(ctx); err != nil {
+		logger.SysError("failed to flush traces: " + err.Error())
+	}
+	if err := initialize.CloseRedis(); err != nil {
+		logger.SysError("failed to close Redis: " + err.Error())
+	}
+	if err := initialize.CloseDB(); err != nil {
+		logger.SysError("failed to close database: " + err.Error())
 	}
+	logger.SysLog("server exited")
 }
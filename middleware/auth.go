@@ -27,7 +27,7 @@ func authHelper(c *gin.Context, minRole int) {
 			c.Abort()
 			return
 		}
-		user := server.ValidateAccessToken(accessToken)
+		user := server.ValidateAccessToken(c.Request.Context(), accessToken)
 		if user != nil && user.Username != "" {
 			// Token is valid
 			username = user.Username
@@ -65,6 +65,7 @@ func authHelper(c *gin.Context, minRole int) {
 	c.Set("username", username)
 	c.Set("role", role)
 	c.Set("id", id)
+	c.Request = c.Request.WithContext(utils.SetUserID(c.Request.Context(), id.(int)))
 	c.Next()
 }
 
@@ -1,13 +1,28 @@
 package middleware
 
 import (
+	"net/url"
+	"slices"
+	"strings"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/global"
 )
 
+// CORS 允许配置的来源与 FRONTEND_BASE_URL 的来源携带凭据跨域访问，每次请求时读取配置，使配置热加载后立即生效。
+// 未配置时不允许跨域访问，内置的前端与 API 同源，不受影响
 func CORS() gin.HandlerFunc {
 	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true
+	config.AllowOriginFunc = func(origin string) bool {
+		cfg := global.GetConfig()
+		if slices.Contains(cfg.System.CorsAllowedOrigins, origin) {
+			return true
+		}
+		frontend, err := url.Parse(strings.TrimSuffix(cfg.System.FrontendBaseUrl, "/"))
+		return err == nil && frontend.Host != "" && origin == frontend.Scheme+"://"+frontend.Host
+	}
 	config.AllowCredentials = true
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
 	config.AllowHeaders = []string{"*"}
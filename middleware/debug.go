@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/global"
+)
+
+// DebugEndpoints 诊断接口默认关闭，需要在系统设置中开启 DebugEndpointsEnabled
+// 关闭时返回 404，不暴露接口是否存在；需要在 RootAuth 之后使用，避免未登录的请求探测开关状态
+func DebugEndpoints() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if !global.DebugEndpointsEnabled {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "诊断接口未开启",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
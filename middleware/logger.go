@@ -1,26 +1,19 @@
 package middleware
 
 import (
-	"fmt"
+	"time"
 
-	"github.com/9688101/hx-admin/utils"
 	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/core/logger"
 )
 
+// SetUpLogger 记录访问日志，与系统日志使用相同的格式，并带上请求 ID 与用户 ID
 func SetUpLogger(server *gin.Engine) {
-	server.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		var requestID string
-		if param.Keys != nil {
-			requestID = param.Keys[utils.RequestIdKey].(string)
-		}
-		return fmt.Sprintf("[GIN] %s | %s | %3d | %13v | %15s | %7s %s\n",
-			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
-			requestID,
-			param.StatusCode,
-			param.Latency,
-			param.ClientIP,
-			param.Method,
-			param.Path,
-		)
-	}))
+	server.Use(func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		c.Next()
+		logger.Access(c.Request.Context(), c.Writer.Status(), time.Since(start), c.ClientIP(), c.Request.Method, path)
+	})
 }
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/core/metrics"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/utils/ctxkey"
+	"github.com/9688101/hx-admin/utils/network"
+)
+
+// Metrics 统计请求数与耗时，按路由模板而不是实际路径分类，避免标签过多
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.ObserveHTTPRequest(c.Request.Method, route, strconv.Itoa(c.Writer.Status()), time.Since(start).Seconds())
+	}
+}
+
+// LoginMetrics 统计登录结果，登录成功由 controller.SetupLogin 标记
+func LoginMetrics(method string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 已登录用户访问 OAuth 回调是在绑定账号，不计入登录
+		if method != "password" && sessions.Default(c).Get("username") != nil {
+			c.Next()
+			return
+		}
+		c.Next()
+		metrics.ObserveLogin(method, c.GetBool(ctxkey.LoginSucceeded))
+	}
+}
+
+// MetricsAuth 校验 /metrics 的访问权限：
+// 在 Authorization 请求头中提供了正确的 METRIC_TOKEN，或者来源 IP 在 METRIC_ALLOWED_IPS 中；两者都未设置时只允许本机访问。
+// 来源 IP 取 TCP 连接的对端地址，不信任可以伪造的 X-Forwarded-For 等请求头
+func MetricsAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if global.MetricToken != "" {
+			token := strings.TrimPrefix(c.Request.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(global.MetricToken)) == 1 {
+				c.Next()
+				return
+			}
+		}
+		allowedIPs := global.MetricAllowedIPs
+		if allowedIPs == "" && global.MetricToken == "" {
+			allowedIPs = "127.0.0.1/32,::1/128"
+		}
+		if allowedIPs != "" && network.IsIpInSubnets(c.Request.Context(), c.RemoteIP(), allowedIPs) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatus(http.StatusForbidden)
+	}
+}
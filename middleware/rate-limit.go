@@ -2,111 +2,239 @@ package middleware
 
 import (
 	"context"
-	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/core/metrics"
 	"github.com/9688101/hx-admin/global"
 	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/server"
 	"github.com/9688101/hx-admin/utils"
+	"github.com/9688101/hx-admin/utils/ctxkey"
 )
 
-var timeFormat = "2006-01-02T15:04:05.000Z"
-
 var inMemoryRateLimiter utils.InMemoryRateLimiter
 
-func redisRateLimiter(c *gin.Context, maxRequestNum int, duration int64, mark string) {
-	ctx := context.Background()
-	rdb := initialize.RDB
-	key := "rateLimit:" + mark + c.ClientIP()
-	listLength, err := rdb.LLen(ctx, key).Result()
+// tokenBucketScript 在 Redis 中原子地完成令牌桶的补充与扣减，多个节点共享同一个桶。
+// 使用 Redis 的时间，避免各节点时钟不一致，replicate_commands 使 Redis 5 之前的版本允许在 TIME 之后写入。
+// 桶恢复满之后 key 自动过期，过期等价于满桶。
+// KEYS[1] 桶的 key；ARGV[1] 容量；ARGV[2] 每毫秒恢复的令牌数；ARGV[3] 过期时间（毫秒）
+// 返回 {是否允许, 剩余令牌数}，剩余令牌数为字符串以保留小数
+var tokenBucketScript = redis.NewScript(`
+redis.replicate_commands()
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local ttl = tonumber(ARGV[3])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate)
+	ts = now
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', ts)
+redis.call('PEXPIRE', KEYS[1], ttl)
+return {allowed, tostring(tokens)}
+`)
+
+func redisRateLimiter(ctx context.Context, key string, maxRequestNum int, duration int64) (utils.RateLimitResult, error) {
+	rate := utils.TokenRefillRate(maxRequestNum, duration)
+	values, err := tokenBucketScript.Run(ctx, initialize.RDB, []string{"rateLimit:" + key}, maxRequestNum, strconv.FormatFloat(rate, 'f', -1, 64), duration*1000).Slice()
 	if err != nil {
-		fmt.Println(err.Error())
-		c.Status(http.StatusInternalServerError)
-		c.Abort()
-		return
+		return utils.RateLimitResult{}, err
 	}
-	if listLength < int64(maxRequestNum) {
-		rdb.LPush(ctx, key, time.Now().Format(timeFormat))
-		rdb.Expire(ctx, key, global.RateLimitKeyExpirationDuration)
-	} else {
-		oldTimeStr, _ := rdb.LIndex(ctx, key, -1).Result()
-		oldTime, err := time.Parse(timeFormat, oldTimeStr)
-		if err != nil {
-			fmt.Println(err)
-			c.Status(http.StatusInternalServerError)
-			c.Abort()
-			return
-		}
-		nowTimeStr := time.Now().Format(timeFormat)
-		nowTime, err := time.Parse(timeFormat, nowTimeStr)
+	allowed, _ := values[0].(int64)
+	tokens, _ := strconv.ParseFloat(values[1].(string), 64)
+	return utils.NewRateLimitResult(allowed == 1, tokens, maxRequestNum, rate), nil
+}
+
+// rateLimit 从 key 对应的令牌桶中取一个令牌，被拒绝时返回 429 并中止请求，返回是否放行
+func rateLimit(c *gin.Context, key string, maxRequestNum int, duration int64, mark string) bool {
+	var result utils.RateLimitResult
+	if initialize.RedisEnabled {
+		var err error
+		result, err = redisRateLimiter(c.Request.Context(), key, maxRequestNum, duration)
 		if err != nil {
-			fmt.Println(err)
+			logger.Error(c.Request.Context(), "rate limiter failed: "+err.Error())
 			c.Status(http.StatusInternalServerError)
 			c.Abort()
-			return
-		}
-		// time.Since will return negative number!
-		// See: https://stackoverflow.com/questions/50970900/why-is-time-since-returning-negative-durations-on-windows
-		if int64(nowTime.Sub(oldTime).Seconds()) < duration {
-			rdb.Expire(ctx, key, global.RateLimitKeyExpirationDuration)
-			c.Status(http.StatusTooManyRequests)
-			c.Abort()
-			return
-		} else {
-			rdb.LPush(ctx, key, time.Now().Format(timeFormat))
-			rdb.LTrim(ctx, key, 0, int64(maxRequestNum-1))
-			rdb.Expire(ctx, key, global.RateLimitKeyExpirationDuration)
+			return false
 		}
+	} else {
+		result = inMemoryRateLimiter.Request(key, maxRequestNum, duration)
 	}
-}
-
-func memoryRateLimiter(c *gin.Context, maxRequestNum int, duration int64, mark string) {
-	key := mark + c.ClientIP()
-	if !inMemoryRateLimiter.Request(key, maxRequestNum, duration) {
+	setRateLimitHeaders(c, result)
+	if !result.Allowed {
+		metrics.RateLimitRejected(mark)
+		c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
 		c.Status(http.StatusTooManyRequests)
 		c.Abort()
-		return
+		return false
 	}
+	return true
 }
 
-func rateLimitFactory(maxRequestNum int, duration int64, mark string) func(c *gin.Context) {
-	if maxRequestNum == 0 || global.DebugEnabled {
-		return func(c *gin.Context) {
-			c.Next()
+// setRateLimitHeaders 一个请求经过多个限流器时，响应头保留剩余次数最少的那个
+func setRateLimitHeaders(c *gin.Context, result utils.RateLimitResult) {
+	header := c.Writer.Header()
+	if current := header.Get("X-RateLimit-Remaining"); current != "" {
+		if remaining, err := strconv.Atoi(current); err == nil && remaining < result.Remaining {
+			return
 		}
 	}
-	if initialize.RedisEnabled {
-		return func(c *gin.Context) {
-			redisRateLimiter(c, maxRequestNum, duration, mark)
-		}
-	} else {
-		// It's safe to call multi times.
+	header.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+func initRateLimiter() {
+	if !initialize.RedisEnabled {
+		// 可以多次调用
 		inMemoryRateLimiter.Init(global.RateLimitKeyExpirationDuration)
-		return func(c *gin.Context) {
-			memoryRateLimiter(c, maxRequestNum, duration, mark)
+	}
+}
+
+// rateLimitFactory 在每次请求时通过 limit 读取限流配置，使配置热加载后立即生效
+func rateLimitFactory(limit func() (int, int64), mark string) func(c *gin.Context) {
+	initRateLimiter()
+	return func(c *gin.Context) {
+		maxRequestNum, duration := limit()
+		if maxRequestNum == 0 || global.DebugEnabled.Load() {
+			c.Next()
+			return
 		}
+		rateLimit(c, mark+":"+c.ClientIP(), maxRequestNum, duration, mark)
 	}
 }
 
 func GlobalWebRateLimit() func(c *gin.Context) {
-	return rateLimitFactory(global.GlobalWebRateLimitNum, global.GlobalWebRateLimitDuration, "GW")
+	return rateLimitFactory(func() (int, int64) {
+		cfg := global.GetConfig().RateLimit
+		return cfg.GlobalWebNum, cfg.GlobalWebDuration
+	}, "GW")
 }
 
 func GlobalAPIRateLimit() func(c *gin.Context) {
-	return rateLimitFactory(global.GlobalApiRateLimitNum, global.GlobalApiRateLimitDuration, "GA")
+	return rateLimitFactory(func() (int, int64) {
+		cfg := global.GetConfig().RateLimit
+		return cfg.GlobalApiNum, cfg.GlobalApiDuration
+	}, "GA")
 }
 
 func CriticalRateLimit() func(c *gin.Context) {
-	return rateLimitFactory(global.CriticalRateLimitNum, global.CriticalRateLimitDuration, "CT")
+	return rateLimitFactory(func() (int, int64) {
+		cfg := global.GetConfig().RateLimit
+		return cfg.CriticalNum, cfg.CriticalDuration
+	}, "CT")
 }
 
 func DownloadRateLimit() func(c *gin.Context) {
-	return rateLimitFactory(global.DownloadRateLimitNum, global.DownloadRateLimitDuration, "DW")
+	return rateLimitFactory(func() (int, int64) {
+		cfg := global.GetConfig().RateLimit
+		return cfg.DownloadNum, cfg.DownloadDuration
+	}, "DW")
 }
 
 func UploadRateLimit() func(c *gin.Context) {
-	return rateLimitFactory(global.UploadRateLimitNum, global.UploadRateLimitDuration, "UP")
+	return rateLimitFactory(func() (int, int64) {
+		cfg := global.GetConfig().RateLimit
+		return cfg.UploadNum, cfg.UploadDuration
+	}, "UP")
+}
+
+// RouteRateLimit 按系统设置中的 RateLimitPolicies 对匹配的路由限流，需要在路由组上使用以便取得路由路径
+func RouteRateLimit() func(c *gin.Context) {
+	initRateLimiter()
+	return func(c *gin.Context) {
+		if global.DebugEnabled.Load() {
+			c.Next()
+			return
+		}
+		for _, policy := range server.GetRateLimitPolicies(c.Request.Method, c.FullPath()) {
+			key := "RP:" + policy.Method + ":" + policy.Path + ":" + policy.Key + ":" + rateLimitIdentity(c, policy.Key)
+			if !rateLimit(c, key, policy.Num, policy.Duration, "RP:"+policy.Path) {
+				return
+			}
+		}
+	}
+}
+
+// rateLimitIdentity 返回限流维度对应的标识，只使用已经验证过的用户，否则退回到客户端 IP。
+// 限流在鉴权之前执行，因此需要自行验证 session 或 access token；未经验证的 Authorization 请求头可以随意更换，不能作为标识。
+func rateLimitIdentity(c *gin.Context, key string) string {
+	if key == model.RateLimitKeyUser {
+		if id := c.GetInt(ctxkey.Id); id != 0 {
+			return "u" + strconv.Itoa(id)
+		}
+		if id, ok := sessions.Default(c).Get("id").(int); ok && id != 0 {
+			return "u" + strconv.Itoa(id)
+		}
+		if user := server.ValidateAccessToken(c.Request.Context(), c.Request.Header.Get("Authorization")); user != nil && user.Id != 0 {
+			return "u" + strconv.Itoa(user.Id)
+		}
+	}
+	return "i" + c.ClientIP()
+}
+
+// RateLimitState 返回每个限流 key 对应令牌桶中剩余的令牌数，仅用于诊断
+// 使用 Redis 时最多返回 maxKeys 个 key
+func RateLimitState(ctx context.Context, maxKeys int) (map[string]utils.RateLimitBucket, error) {
+	if !initialize.RedisEnabled {
+		return inMemoryRateLimiter.Snapshot(), nil
+	}
+	state := make(map[string]utils.RateLimitBucket)
+	var mu sync.Mutex
+	err := initialize.RedisScan(ctx, "rateLimit:*", 100, func(key string) error {
+		values, err := initialize.RDB.HMGet(ctx, key, "tokens", "ts").Result()
+		if err != nil {
+			// 旧版本的限流 key 是列表，升级后会自然过期
+			if strings.Contains(err.Error(), "WRONGTYPE") {
+				return nil
+			}
+			return err
+		}
+		var bucket utils.RateLimitBucket
+		if s, ok := values[0].(string); ok {
+			bucket.Tokens, _ = strconv.ParseFloat(s, 64)
+		}
+		if s, ok := values[1].(string); ok {
+			bucket.UpdatedAt, _ = strconv.ParseInt(s, 10, 64)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(state) >= maxKeys {
+			return initialize.ErrRedisScanStop
+		}
+		state[strings.TrimPrefix(key, "rateLimit:")] = bucket
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
 }
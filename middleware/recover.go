@@ -6,10 +6,21 @@ import (
 	"runtime/debug"
 
 	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/server"
 	"github.com/9688101/hx-admin/utils"
 	"github.com/gin-gonic/gin"
 )
 
+// Recovery 与 gin.Recovery 相同，同时发送 system_error 通知
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecovery(func(c *gin.Context, err any) {
+		server.Notify(c.Request.Context(), model.NotificationEventSystemError, "服务发生 panic",
+			fmt.Sprintf("%s %s：%v", c.Request.Method, c.Request.URL.Path, err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+	})
+}
+
 func RelayPanicRecover() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		defer func() {
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/9688101/hx-admin/core/tracing"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/server"
+)
+
+func TestTracingDBSpanParent(t *testing.T) {
+	Convey("TestTracingDBSpanParent", t, func() {
+		recorder := tracetest.NewSpanRecorder()
+		oldProvider := otel.GetTracerProvider()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: gormlogger.Discard})
+		So(err, ShouldBeNil)
+		_, err = initialize.MigrateUp(db, initialize.Migrations, 0)
+		So(err, ShouldBeNil)
+		So(tracing.RegisterGORMCallbacks(db, "main"), ShouldBeNil)
+		oldDB := initialize.DB
+		t.Cleanup(func() {
+			otel.SetTracerProvider(oldProvider)
+			initialize.DB = oldDB
+		})
+		initialize.DB = db
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(Tracing())
+		router.GET("/api/user/:id", func(c *gin.Context) {
+			server.IsUsernameAlreadyTaken(c.Request.Context(), c.Param("id"))
+			c.Status(http.StatusOK)
+		})
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/user/root", nil))
+
+		// 请求中的 SQL 挂在请求的 span 之下
+		spans := recorder.Ended()
+		var requestSpan, dbSpan sdktrace.ReadOnlySpan
+		for _, span := range spans {
+			switch span.Name() {
+			case "GET /api/user/:id":
+				requestSpan = span
+			case "gorm.query":
+				dbSpan = span
+			}
+		}
+		So(requestSpan, ShouldNotBeNil)
+		So(dbSpan, ShouldNotBeNil)
+		So(dbSpan.Parent().SpanID(), ShouldEqual, requestSpan.SpanContext().SpanID())
+		So(dbSpan.SpanContext().TraceID(), ShouldEqual, requestSpan.SpanContext().TraceID())
+	})
+}
@@ -0,0 +1,53 @@
+package model
+
+// Severities of announcements
+const (
+	AnnouncementSeverityInfo     = "info"
+	AnnouncementSeverityWarning  = "warning"
+	AnnouncementSeverityCritical = "critical"
+)
+
+// Announcement is shown to the targeted users between StartTime and EndTime
+type Announcement struct {
+	Id        int    `json:"id"`
+	Title     string `json:"title" gorm:"type:varchar(255)"` // Markdown, sanitized when saved
+	Content   string `json:"content" gorm:"type:text"`       // Markdown, sanitized when saved
+	Severity  string `json:"severity" gorm:"type:varchar(16);default:'info'"`
+	StartTime int64  `json:"start_time" gorm:"bigint;default:0;index"`
+	EndTime   int64  `json:"end_time" gorm:"bigint;default:0"`           // 0 for no end
+	Roles     string `json:"roles" gorm:"type:varchar(64);default:''"`   // comma separated roles, empty for all roles
+	Groups    string `json:"groups" gorm:"type:varchar(255);default:''"` // comma separated user groups, empty for all groups
+	CreatorId int    `json:"creator_id" gorm:"default:0"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func NewAnnouncement() *Announcement {
+	return &Announcement{}
+}
+
+// AnnouncementReceipt records when a user read or dismissed an announcement
+type AnnouncementReceipt struct {
+	Id             int   `json:"id"`
+	AnnouncementId int   `json:"announcement_id" gorm:"uniqueIndex:idx_announcement_receipts_user,priority:1"`
+	UserId         int   `json:"user_id" gorm:"uniqueIndex:idx_announcement_receipts_user,priority:2"`
+	ReadAt         int64 `json:"read_at" gorm:"bigint;default:0"`
+	DismissedAt    int64 `json:"dismissed_at" gorm:"bigint;default:0"`
+}
+
+func NewAnnouncementReceipt() *AnnouncementReceipt {
+	return &AnnouncementReceipt{}
+}
+
+// UserAnnouncement is an announcement with the receipt of the current user
+type UserAnnouncement struct {
+	*Announcement
+	ReadAt      int64 `json:"read_at"`
+	DismissedAt int64 `json:"dismissed_at"`
+}
+
+// AnnouncementStats counts the receipts of an announcement
+type AnnouncementStats struct {
+	Read      int64 `json:"read"`
+	Dismissed int64 `json:"dismissed"`
+}
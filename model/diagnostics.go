@@ -0,0 +1,36 @@
+package model
+
+// RuntimeStats is returned by the root-only diagnostics endpoint
+type RuntimeStats struct {
+	StartTime    int64                   `json:"start_time"`
+	Uptime       int64                   `json:"uptime"` // seconds
+	GoVersion    string                  `json:"go_version"`
+	NumCPU       int                     `json:"num_cpu"`
+	NumGoroutine int                     `json:"num_goroutine"`
+	Memory       MemoryStats             `json:"memory"`
+	DB           map[string]*DBPoolStats `json:"db"`
+	Caches       map[string]int          `json:"caches"` // number of entries kept in memory by each cache
+}
+
+type MemoryStats struct {
+	Alloc        uint64 `json:"alloc"`
+	Sys          uint64 `json:"sys"`
+	HeapAlloc    uint64 `json:"heap_alloc"`
+	HeapInuse    uint64 `json:"heap_inuse"`
+	HeapIdle     uint64 `json:"heap_idle"`
+	HeapObjects  uint64 `json:"heap_objects"`
+	NumGC        uint32 `json:"num_gc"`
+	LastGC       int64  `json:"last_gc"` // unix milliseconds, 0 if GC has never run
+	PauseTotalMs int64  `json:"pause_total_ms"`
+}
+
+type DBPoolStats struct {
+	MaxOpenConnections int   `json:"max_open_connections"`
+	OpenConnections    int   `json:"open_connections"`
+	InUse              int   `json:"in_use"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"wait_count"`
+	WaitDurationMs     int64 `json:"wait_duration_ms"`
+	MaxIdleClosed      int64 `json:"max_idle_closed"`
+	MaxLifetimeClosed  int64 `json:"max_lifetime_closed"`
+}
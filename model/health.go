@@ -0,0 +1,14 @@
+package model
+
+// ComponentStatus is the result of checking one dependency in the readiness probe
+type ComponentStatus struct {
+	Status    string `json:"status"` // up, down or disabled
+	LatencyMs int64  `json:"latency_ms"`
+	Migration string `json:"migration,omitempty"` // migration state of the database, see initialize.MigrationState
+}
+
+const (
+	ComponentStatusUp       = "up"
+	ComponentStatusDown     = "down"
+	ComponentStatusDisabled = "disabled"
+)
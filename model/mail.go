@@ -0,0 +1,44 @@
+package model
+
+// Mail is an email in the outbound queue, it is sent by server.RunMailQueue and retried on failure
+type Mail struct {
+	Id            int    `json:"id"`
+	Receiver      string `json:"receiver" gorm:"type:varchar(255);index"`
+	Subject       string `json:"subject" gorm:"type:varchar(255)"`
+	Content       string `json:"content,omitempty" gorm:"type:text"`
+	TextContent   string `json:"text_content,omitempty" gorm:"type:text"`                                         // plain text alternative of Content
+	Status        int    `json:"status" gorm:"type:int;default:1;index:idx_mails_status_next_attempt,priority:1"` // queued, sending, sent or failed
+	Attempts      int    `json:"attempts" gorm:"default:0"`
+	NextAttemptAt int64  `json:"next_attempt_at" gorm:"bigint;index:idx_mails_status_next_attempt,priority:2"`
+	LockedBy      string `json:"-" gorm:"type:varchar(64);default:''"` // the node sending the mail
+	LockedUntil   int64  `json:"-" gorm:"bigint;default:0"`
+	LastError     string `json:"last_error" gorm:"type:text"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint;index"`
+	SentAt        int64  `json:"sent_at" gorm:"bigint;default:0"`
+}
+
+func NewMail() *Mail {
+	return &Mail{}
+}
+
+func NewMailById(id int) *Mail {
+	return &Mail{Id: id}
+}
+
+// EmailTemplate is the template of an email event in one language
+type EmailTemplate struct {
+	Event      string         `json:"event"`
+	Language   string         `json:"language"`
+	Key        string         `json:"key"` // the option saving the customized template
+	Content    string         `json:"content"`
+	Customized bool           `json:"customized"`
+	SampleData map[string]any `json:"sample_data"`
+}
+
+// EmailTemplatePreviewRequest renders Content, or the current template when it is empty, with Data merged into the sample data
+type EmailTemplatePreviewRequest struct {
+	Event    string         `json:"event"`
+	Language string         `json:"language"`
+	Content  string         `json:"content"`
+	Data     map[string]any `json:"data"`
+}
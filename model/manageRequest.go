@@ -3,6 +3,8 @@ package model
 type ManageRequest struct {
 	Username string `json:"username"`
 	Action   string `json:"action"`
+	Reason   string `json:"reason"`   // only for the ban action
+	Duration int64  `json:"duration"` // only for the ban action, in seconds, 0 means permanent
 }
 
 func NewManageRequest() *ManageRequest {
@@ -0,0 +1,39 @@
+package model
+
+// Notification events, a channel receives the events listed in its Events
+const (
+	NotificationEventUserRegister = "user_register" // a user is created
+	NotificationEventAdminAction  = "admin_action"  // an admin manages a user or updates options
+	NotificationEventSystemError  = "system_error"  // panics, mails failed after all attempts, failed scheduled backups
+	NotificationEventLoginAlert   = "login_alert"   // an admin logs in
+	NotificationEventTest         = "test"          // test sending, no subscription needed
+)
+
+// Notification channel types
+const (
+	NotificationTypeWebhook       = "webhook"
+	NotificationTypeFeishu        = "feishu"
+	NotificationTypeDingTalk      = "dingtalk"
+	NotificationTypeWeCom         = "wecom"
+	NotificationTypeSlack         = "slack"
+	NotificationTypeEmail         = "email"
+	NotificationTypeMessagePusher = "message_pusher"
+)
+
+// NotificationChannel is a notification target configured in the NotificationChannels option
+type NotificationChannel struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	URL       string   `json:"url,omitempty"`       // webhook address, or the Message Pusher address, which defaults to MessagePusherAddress
+	Secret    string   `json:"secret,omitempty"`    // HMAC key of webhook, signing secret of Feishu and DingTalk bots
+	Token     string   `json:"token,omitempty"`     // Message Pusher token, defaults to MessagePusherToken
+	Receivers []string `json:"receivers,omitempty"` // email addresses
+	Events    []string `json:"events"`
+	Disabled  bool     `json:"disabled,omitempty"`
+}
+
+// NotificationTestRequest tests the saved channel Name, or Channel when it is given
+type NotificationTestRequest struct {
+	Name    string               `json:"name"`
+	Channel *NotificationChannel `json:"channel"`
+}
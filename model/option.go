@@ -4,3 +4,11 @@ type Option struct {
 	Key   string `json:"key" gorm:"primaryKey"`
 	Value string `json:"value"`
 }
+
+// OptionChange describes how an option would change when a set of options is imported.
+type OptionChange struct {
+	Key      string `json:"key"`
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+	Created  bool   `json:"created"` // the option does not exist yet
+}
@@ -0,0 +1,24 @@
+package model
+
+// OptionRevision records one change of an Option, so that it can be audited and rolled back.
+// Values of secret options (keys ending with Token/Secret) are redacted before saving.
+type OptionRevision struct {
+	Id         int    `json:"id"`
+	Key        string `json:"key" gorm:"type:varchar(64);index"`
+	OldValue   string `json:"old_value" gorm:"type:text"`
+	NewValue   string `json:"new_value" gorm:"type:text"`
+	Redacted   bool   `json:"redacted" gorm:"default:false"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	Username   string `json:"username" gorm:"default:''"`
+	RequestId  string `json:"request_id" gorm:"default:''"`
+	RollbackOf int    `json:"rollback_of" gorm:"default:0"` // id of the revision this change rolled back, 0 if none
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func NewOptionRevision() *OptionRevision {
+	return &OptionRevision{}
+}
+
+func NewOptionRevisionById(id int) *OptionRevision {
+	return &OptionRevision{Id: id}
+}
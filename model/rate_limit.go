@@ -0,0 +1,16 @@
+package model
+
+// RateLimitPolicy is a rate limit applied to one route, configured by the RateLimitPolicies option.
+// Num requests are allowed in Duration seconds, counted per client IP or user depending on Key.
+type RateLimitPolicy struct {
+	Method   string `json:"method"` // empty or * matches every method
+	Path     string `json:"path"`   // gin route path, e.g. /api/user/login or /api/user/:id
+	Key      string `json:"key"`    // ip or user, defaults to ip
+	Num      int    `json:"num"`
+	Duration int64  `json:"duration"`
+}
+
+const (
+	RateLimitKeyIP   = "ip"
+	RateLimitKeyUser = "user"
+)
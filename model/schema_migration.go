@@ -0,0 +1,32 @@
+package model
+
+// SchemaMigration records a migration applied to the database, see initialize.Migrations
+type SchemaMigration struct {
+	Version   int    `json:"version" gorm:"primaryKey;autoIncrement:false"`
+	Name      string `json:"name" gorm:"type:varchar(128)"`
+	AppliedAt int64  `json:"applied_at" gorm:"bigint"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// SchemaMigrationLock has at most one row, the node that inserted it is allowed to run migrations
+type SchemaMigrationLock struct {
+	Id       int    `gorm:"primaryKey;autoIncrement:false"`
+	Owner    string `gorm:"type:varchar(64)"`
+	LockedAt int64  `gorm:"bigint"`
+}
+
+func (SchemaMigrationLock) TableName() string {
+	return "schema_migration_locks"
+}
+
+// MigrationStatus is one line of the output of `migrate status`
+type MigrationStatus struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	AppliedAt int64  `json:"applied_at"`
+	Unknown   bool   `json:"unknown"` // applied by a newer version of the program, not known to this one
+}
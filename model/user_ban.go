@@ -0,0 +1,25 @@
+package model
+
+// UserBan is a ban of a user, in addition to disabling the user. A user is banned while the ban has not expired.
+type UserBan struct {
+	Id         int    `json:"id"`
+	UserId     int    `json:"user_id" gorm:"uniqueIndex"`
+	Reason     string `json:"reason" gorm:"type:varchar(255);default:''"`
+	ExpiresAt  int64  `json:"expires_at" gorm:"bigint;index"` // 0 means the ban never expires
+	OperatorId int    `json:"operator_id" gorm:"default:0"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint"`
+}
+
+func NewUserBan() *UserBan {
+	return &UserBan{}
+}
+
+// BannedUser is a user that is currently banned, either disabled, deleted or with a UserBan that has not expired
+type BannedUser struct {
+	UserId    int    `json:"user_id"`
+	Username  string `json:"username"`
+	Status    int    `json:"status"`
+	Reason    string `json:"reason"`
+	ExpiresAt int64  `json:"expires_at"`
+	CreatedAt int64  `json:"created_at"`
+}
@@ -0,0 +1,47 @@
+package model
+
+// Levels of user notifications
+const (
+	UserNotificationLevelInfo    = "info"
+	UserNotificationLevelWarning = "warning"
+	UserNotificationLevelError   = "error"
+)
+
+// UserNotification is a message in a user's inbox
+type UserNotification struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"index:idx_user_notifications_user_read,priority:1"`
+	Title     string `json:"title" gorm:"type:varchar(255)"`
+	Content   string `json:"content" gorm:"type:text"`
+	Level     string `json:"level" gorm:"type:varchar(16);default:'info'"`
+	SenderId  int    `json:"sender_id" gorm:"default:0"` // the admin who sent the message, 0 for the system
+	ReadAt    int64  `json:"read_at" gorm:"bigint;default:0;index:idx_user_notifications_user_read,priority:2"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint"`
+}
+
+func NewUserNotification() *UserNotification {
+	return &UserNotification{}
+}
+
+// BroadcastRequest sends a notification to every enabled user, or to the users in Group when it is not empty
+type BroadcastRequest struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Level   string `json:"level"`
+	Group   string `json:"group"`
+}
+
+// Events pushed to users through the /api/user/events stream
+const (
+	UserEventUnread       = "unread"       // sent when the stream is opened, data is the number of unread notifications
+	UserEventNotification = "notification" // data is the new notification, its id is 0 for broadcasts
+	UserEventLogout       = "logout"       // the session should be dropped, the stream is closed after it
+	UserEventPing         = "ping"         // keeps proxies from closing an idle stream
+	UserEventAnnouncement = "announcement" // data is the id of an announcement that took effect, clients should refetch
+)
+
+// UserEvent is an event pushed to a user
+type UserEvent struct {
+	Event string `json:"event"`
+	Data  any    `json:"data"`
+}
@@ -0,0 +1,97 @@
+package model
+
+// Webhook events, the payload data of each event is documented in docs/API.md
+const (
+	WebhookEventUserRegistered  = "user.registered"
+	WebhookEventUserEnabled     = "user.enabled"
+	WebhookEventUserDisabled    = "user.disabled"
+	WebhookEventUserBanned      = "user.banned"
+	WebhookEventUserUnbanned    = "user.unbanned"
+	WebhookEventUserDeleted     = "user.deleted"
+	WebhookEventUserRoleChanged = "user.role_changed"
+	WebhookEventOptionUpdated   = "option.updated"
+	WebhookEventPing            = "ping" // sent to a single webhook on request, no subscription needed
+)
+
+var WebhookEvents = []string{
+	WebhookEventUserRegistered,
+	WebhookEventUserEnabled,
+	WebhookEventUserDisabled,
+	WebhookEventUserBanned,
+	WebhookEventUserUnbanned,
+	WebhookEventUserDeleted,
+	WebhookEventUserRoleChanged,
+	WebhookEventOptionUpdated,
+}
+
+// Webhook is an endpoint registered by an admin to receive domain events
+type Webhook struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64)"`
+	URL         string `json:"url" gorm:"type:varchar(1024)"`
+	Secret      string `json:"secret" gorm:"type:varchar(128)"`  // HMAC key of the X-Webhook-Signature header
+	Events      string `json:"events" gorm:"type:varchar(1024)"` // comma separated event names, "*" for all events
+	Status      int    `json:"status" gorm:"type:int;default:1"` // enabled or disabled
+	Description string `json:"description" gorm:"type:varchar(255);default:''"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+	UpdatedAt   int64  `json:"updated_at" gorm:"bigint"`
+}
+
+func NewWebhook() *Webhook {
+	return &Webhook{}
+}
+
+func NewWebhookById(id int) *Webhook {
+	return &Webhook{Id: id}
+}
+
+// WebhookEvent is a domain event in the transactional outbox, it is written in the same
+// transaction as the change it describes and fanned out to deliveries by server.RunWebhookQueue
+type WebhookEvent struct {
+	Id           int    `json:"id"`
+	Event        string `json:"event" gorm:"type:varchar(64);index"`
+	Payload      string `json:"payload" gorm:"type:text"` // JSON encoded data of the event
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+	DispatchedAt int64  `json:"dispatched_at" gorm:"bigint;default:0;index"` // 0 until deliveries are created
+}
+
+func NewWebhookEvent() *WebhookEvent {
+	return &WebhookEvent{}
+}
+
+// WebhookDelivery is an attempt to send an event to a webhook, it is retried on failure
+// and kept as the delivery log
+type WebhookDelivery struct {
+	Id             int    `json:"id"`
+	WebhookId      int    `json:"webhook_id" gorm:"index"`
+	EventId        int    `json:"event_id" gorm:"index"`
+	Event          string `json:"event" gorm:"type:varchar(64)"`
+	RedeliveryOf   int    `json:"redelivery_of" gorm:"default:0"`                                                               // id of the delivery this one resends, 0 if none
+	Status         int    `json:"status" gorm:"type:int;default:1;index:idx_webhook_deliveries_status_next_attempt,priority:1"` // queued, sending, delivered or failed
+	Attempts       int    `json:"attempts" gorm:"default:0"`
+	NextAttemptAt  int64  `json:"next_attempt_at" gorm:"bigint;index:idx_webhook_deliveries_status_next_attempt,priority:2"`
+	LockedBy       string `json:"-" gorm:"type:varchar(64);default:''"` // the node sending the delivery
+	LockedUntil    int64  `json:"-" gorm:"bigint;default:0"`
+	ResponseStatus int    `json:"response_status" gorm:"default:0"`
+	ResponseBody   string `json:"response_body" gorm:"type:text"`   // truncated
+	Duration       int64  `json:"duration" gorm:"bigint;default:0"` // milliseconds of the last attempt
+	LastError      string `json:"last_error" gorm:"type:text"`
+	CreatedAt      int64  `json:"created_at" gorm:"bigint;index"`
+	DeliveredAt    int64  `json:"delivered_at" gorm:"bigint;default:0"`
+}
+
+func NewWebhookDelivery() *WebhookDelivery {
+	return &WebhookDelivery{}
+}
+
+func NewWebhookDeliveryById(id int) *WebhookDelivery {
+	return &WebhookDelivery{Id: id}
+}
+
+// WebhookPayload is the body POSTed to webhooks
+type WebhookPayload struct {
+	Id        int    `json:"id"` // id of the event, the same for redeliveries, receivers can use it to drop duplicates
+	Event     string `json:"event"`
+	CreatedAt int64  `json:"created_at"`
+	Data      any    `json:"data"`
+}
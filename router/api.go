@@ -20,11 +20,14 @@ func SetApiRouter(router *gin.Engine) {
 	// 启用全局 API 速率限制，防止接口滥用
 	apiRouter.Use(middleware.GlobalAPIRateLimit())
 
+	// 按系统设置中的 RateLimitPolicies 对指定路由限流
+	apiRouter.Use(middleware.RouteRateLimit())
+
 	{
 		// 获取 API 状态
 		apiRouter.GET("/status", controller.GetStatus)
 
-		// 获取公告信息
+		// 获取公告信息，包括系统设置中的公告与生效中的公开公告
 		apiRouter.GET("/notice", controller.GetNotice)
 
 		// 获取关于我们页面的信息
@@ -43,19 +46,19 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.POST("/user/reset", middleware.CriticalRateLimit(), controller.ResetPassword)
 
 		// GitHub OAuth 登录
-		apiRouter.GET("/oauth/github", middleware.CriticalRateLimit(), auth.GitHubOAuth)
+		apiRouter.GET("/oauth/github", middleware.CriticalRateLimit(), middleware.LoginMetrics("github"), auth.GitHubOAuth)
 
 		// OIDC（OpenID Connect）认证
-		apiRouter.GET("/oauth/oidc", middleware.CriticalRateLimit(), auth.OidcAuth)
+		apiRouter.GET("/oauth/oidc", middleware.CriticalRateLimit(), middleware.LoginMetrics("oidc"), auth.OidcAuth)
 
 		// Lark OAuth 登录
-		apiRouter.GET("/oauth/lark", middleware.CriticalRateLimit(), auth.LarkOAuth)
+		apiRouter.GET("/oauth/lark", middleware.CriticalRateLimit(), middleware.LoginMetrics("lark"), auth.LarkOAuth)
 
 		// 生成 OAuth 状态码
 		apiRouter.GET("/oauth/state", middleware.CriticalRateLimit(), auth.GenerateOAuthCode)
 
 		// 微信 OAuth 登录
-		apiRouter.GET("/oauth/wechat", middleware.CriticalRateLimit(), auth.WeChatAuth)
+		apiRouter.GET("/oauth/wechat", middleware.CriticalRateLimit(), middleware.LoginMetrics("wechat"), auth.WeChatAuth)
 
 		// 绑定微信账号，需用户身份验证
 		apiRouter.GET("/oauth/wechat/bind", middleware.CriticalRateLimit(), middleware.UserAuth(), auth.WeChatBind)
@@ -73,7 +76,7 @@ func SetApiRouter(router *gin.Engine) {
 			userRoute.POST("/register", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.Register)
 
 			// 用户登录，受限流保护
-			userRoute.POST("/login", middleware.CriticalRateLimit(), controller.Login)
+			userRoute.POST("/login", middleware.CriticalRateLimit(), middleware.LoginMetrics("password"), controller.Login)
 
 			// 用户登出
 			userRoute.GET("/logout", controller.Logout)
@@ -100,6 +103,33 @@ func SetApiRouter(router *gin.Engine) {
 				// 获取推广码
 				selfRoute.GET("/aff", controller.GetAffCode)
 
+				// 获取通知，可只获取未读的通知
+				selfRoute.GET("/notifications", controller.GetUserNotifications)
+
+				// 获取未读的通知数
+				selfRoute.GET("/notifications/unread_count", controller.GetUnreadUserNotificationCount)
+
+				// 将通知标记为已读
+				selfRoute.POST("/notifications/:id/read", controller.ReadUserNotification)
+
+				// 将所有通知标记为已读
+				selfRoute.POST("/notifications/read_all", controller.ReadAllUserNotifications)
+
+				// 删除通知
+				selfRoute.DELETE("/notifications/:id", controller.DeleteUserNotification)
+
+				// 通过 SSE 接收新通知与强制退出登录事件
+				selfRoute.GET("/events", controller.UserEvents)
+
+				// 获取面向当前用户的公告
+				selfRoute.GET("/announcements", controller.GetUserAnnouncements)
+
+				// 将公告标记为已读
+				selfRoute.POST("/announcements/:id/read", controller.ReadAnnouncement)
+
+				// 关闭公告
+				selfRoute.POST("/announcements/:id/dismiss", controller.DismissAnnouncement)
+
 				// 充值功能（普通用户），当前被注释掉
 				// selfRoute.POST("/topup", controller.TopUp)
 
@@ -117,6 +147,12 @@ func SetApiRouter(router *gin.Engine) {
 				// 搜索用户
 				adminRoute.GET("/search", controller.SearchUsers)
 
+				// 获取当前被封禁的用户
+				adminRoute.GET("/bans", controller.GetUserBans)
+
+				// 向所有用户或某个分组中的用户发送通知
+				adminRoute.POST("/notifications/broadcast", controller.BroadcastUserNotification)
+
 				// 根据 ID 获取用户信息
 				adminRoute.GET("/:id", controller.GetUser)
 
@@ -143,6 +179,130 @@ func SetApiRouter(router *gin.Engine) {
 
 			// 更新系统配置
 			optionRoute.PUT("/", controller.UpdateOption)
+
+			// 在同一事务中批量更新系统配置
+			optionRoute.PUT("/batch", controller.UpdateOptions)
+
+			// 导出系统配置（JSON 或 YAML）
+			optionRoute.GET("/export", controller.ExportOptions)
+
+			// 导入系统配置，dry_run=true 时仅返回差异
+			optionRoute.POST("/import", controller.ImportOptions)
+
+			// 获取系统配置的修改历史
+			optionRoute.GET("/history", controller.GetOptionHistory)
+
+			// 将系统配置回滚到指定修改之前的值
+			optionRoute.POST("/history/:id/rollback", controller.RollbackOption)
+		}
+
+		// 数据库备份，仅超级管理员可访问，仅支持 SQLite
+		backupRoute := apiRouter.Group("/admin")
+		backupRoute.Use(middleware.RootAuth())
+		{
+			// 下载数据库的一致性快照
+			backupRoute.GET("/backup", controller.DownloadBackup)
+
+			// 在服务器的备份目录中生成备份
+			backupRoute.POST("/backup", controller.CreateBackup)
+
+			// 列出服务器上的备份文件
+			backupRoute.GET("/backups", controller.GetBackups)
+		}
+
+		// 发件队列，仅超级管理员可访问
+		mailRoute := apiRouter.Group("/mail")
+		mailRoute.Use(middleware.RootAuth())
+		{
+			// 获取队列中的邮件，可按状态筛选
+			mailRoute.GET("/", controller.GetMails)
+
+			// 重新发送邮件
+			mailRoute.POST("/:id/resend", controller.ResendMail)
+
+			// 获取邮件模板
+			mailRoute.GET("/templates", controller.GetEmailTemplates)
+
+			// 使用示例数据预览邮件模板
+			mailRoute.POST("/templates/preview", controller.PreviewEmailTemplate)
+		}
+
+		// 通知渠道，仅超级管理员可访问
+		notificationRoute := apiRouter.Group("/notification")
+		notificationRoute.Use(middleware.RootAuth())
+		{
+			// 获取已配置的通知渠道
+			notificationRoute.GET("/channels", controller.GetNotificationChannels)
+
+			// 发送测试通知
+			notificationRoute.POST("/test", controller.TestNotificationChannel)
+		}
+
+		// 公告管理，仅管理员可访问
+		announcementRoute := apiRouter.Group("/announcement")
+		announcementRoute.Use(middleware.AdminAuth())
+		{
+			// 获取所有公告
+			announcementRoute.GET("/", controller.GetAnnouncements)
+
+			// 获取公告的已读与已关闭人数
+			announcementRoute.GET("/:id/stats", controller.GetAnnouncementStats)
+
+			// 添加公告
+			announcementRoute.POST("/", controller.AddAnnouncement)
+
+			// 修改公告
+			announcementRoute.PUT("/", controller.UpdateAnnouncement)
+
+			// 删除公告
+			announcementRoute.DELETE("/:id", controller.DeleteAnnouncement)
+		}
+
+		// 领域事件 webhook，仅超级管理员可访问
+		webhookRoute := apiRouter.Group("/webhook")
+		webhookRoute.Use(middleware.RootAuth())
+		{
+			// 获取所有 webhook
+			webhookRoute.GET("/", controller.GetWebhooks)
+
+			// 获取可以订阅的事件
+			webhookRoute.GET("/events", controller.GetWebhookEvents)
+
+			// 添加 webhook
+			webhookRoute.POST("/", controller.AddWebhook)
+
+			// 修改 webhook
+			webhookRoute.PUT("/", controller.UpdateWebhook)
+
+			// 删除 webhook
+			webhookRoute.DELETE("/:id", controller.DeleteWebhook)
+
+			// 投递 ping 事件
+			webhookRoute.POST("/:id/ping", controller.PingWebhook)
+
+			// 获取投递记录，可按状态筛选
+			webhookRoute.GET("/:id/deliveries", controller.GetWebhookDeliveries)
+
+			// 重新投递
+			webhookRoute.POST("/deliveries/:id/redeliver", controller.RedeliverWebhook)
+		}
+
+		// 诊断接口，仅超级管理员可访问，需在系统设置中开启 DebugEndpointsEnabled
+		debugRoute := apiRouter.Group("/debug")
+		debugRoute.Use(middleware.RootAuth(), middleware.DebugEndpoints())
+		{
+			// 获取运行时状态
+			debugRoute.GET("/stats", controller.GetRuntimeStats)
+
+			// 获取限流器状态
+			debugRoute.GET("/rate-limit", controller.GetRateLimitState)
+
+			// 获取封禁用户列表
+			debugRoute.GET("/banned-users", controller.GetBannedUsers)
+
+			// pprof 性能分析
+			debugRoute.GET("/pprof/*name", controller.Pprof)
+			debugRoute.POST("/pprof/*name", controller.Pprof)
 		}
 
 		// 支付渠道管理路由，当前被注释掉
@@ -0,0 +1,15 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/controller"
+)
+
+// SetHealthRouter 配置健康检查路由，不经过限流，供 Kubernetes 与负载均衡器使用
+func SetHealthRouter(router *gin.Engine) {
+	// 存活检查
+	router.GET("/healthz", controller.Healthz)
+	// 就绪检查
+	router.GET("/readyz", controller.Readyz)
+}
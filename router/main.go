@@ -4,16 +4,25 @@ import (
 	"embed"    // 用于嵌入静态资源文件
 	"fmt"      // 用于字符串格式化
 	"net/http" // 提供 HTTP 相关功能
-	"os"       // 处理系统环境变量
 	"strings"  // 处理字符串操作
 
 	"github.com/9688101/hx-admin/core/logger" // 引入日志模块
 	"github.com/9688101/hx-admin/global"      // 引入配置模块
+	"github.com/9688101/hx-admin/middleware"  // 引入中间件
 	"github.com/gin-gonic/gin"                // 引入 Gin 框架，用于处理 HTTP 请求
 )
 
 // SetRouter 配置整个 Web 服务器的路由
 func SetRouter(router *gin.Engine, buildFS embed.FS) {
+	// 跨域访问，需要在所有路由之前注册，使预检请求也能得到响应
+	router.Use(middleware.CORS())
+
+	// 设置健康检查路由
+	SetHealthRouter(router)
+
+	// 设置监控指标路由
+	SetMetricsRouter(router)
+
 	// 设置 API 相关的路由
 	SetApiRouter(router)
 
@@ -24,7 +33,7 @@ func SetRouter(router *gin.Engine, buildFS embed.FS) {
 	// SetRelayRouter(router)
 
 	// 获取前端的基本 URL（通常用于前后端分离架构）
-	frontendBaseUrl := os.Getenv("FRONTEND_BASE_URL")
+	frontendBaseUrl := global.GetConfig().System.FrontendBaseUrl
 
 	// 如果当前节点是主节点（Master Node），则忽略前端 URL 并记录日志
 	if global.IsMasterNode && frontendBaseUrl != "" {
@@ -0,0 +1,18 @@
+package router
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/core/metrics"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/middleware"
+)
+
+// SetMetricsRouter 配置 Prometheus 指标路由，仅在 ENABLE_METRIC 开启时生效
+func SetMetricsRouter(router *gin.Engine) {
+	if !global.EnableMetric {
+		return
+	}
+	// Prometheus 指标
+	router.GET("/metrics", middleware.MetricsAuth(), gin.WrapH(metrics.Handler()))
+}
@@ -0,0 +1,352 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils"
+)
+
+const (
+	announcementTitleMaxLength = 255
+	announcementGroupMaxLength = 32
+	// announcementScheduleInterval 检查定时公告是否已经生效的间隔
+	announcementScheduleInterval = 10 * time.Second
+)
+
+var announcementRoles = []int{RoleCommonUser, RoleAdminUser, RoleRootUser}
+
+func validateAnnouncement(announcement *model.Announcement) error {
+	// 标题与内容一样在保存前清理，标题会作为 Markdown 出现在 /api/notice 与前端的公告中
+	announcement.Title = utils.SanitizeMarkdown(strings.Join(strings.Fields(announcement.Title), " "))
+	if announcement.Title == "" {
+		return errors.New("公告标题不能为空")
+	}
+	if utf8.RuneCountInString(announcement.Title) > announcementTitleMaxLength {
+		return errors.New("公告标题过长")
+	}
+	switch announcement.Severity {
+	case "":
+		announcement.Severity = model.AnnouncementSeverityInfo
+	case model.AnnouncementSeverityInfo, model.AnnouncementSeverityWarning, model.AnnouncementSeverityCritical:
+	default:
+		return errors.New("公告级别无效")
+	}
+	if announcement.StartTime < 0 || announcement.EndTime < 0 {
+		return errors.New("公告的生效时间无效")
+	}
+	if announcement.EndTime != 0 && announcement.EndTime <= announcement.StartTime {
+		return errors.New("公告的结束时间必须晚于开始时间")
+	}
+	var roles []string
+	for _, role := range strings.Split(announcement.Roles, ",") {
+		role = strings.TrimSpace(role)
+		if role == "" {
+			continue
+		}
+		r, err := strconv.Atoi(role)
+		if err != nil || !slices.Contains(announcementRoles, r) {
+			return fmt.Errorf("角色 %s 无效", role)
+		}
+		if !slices.Contains(roles, strconv.Itoa(r)) {
+			roles = append(roles, strconv.Itoa(r))
+		}
+	}
+	announcement.Roles = strings.Join(roles, ",")
+	var groups []string
+	for _, group := range strings.Split(announcement.Groups, ",") {
+		group = strings.TrimSpace(group)
+		if group == "" {
+			continue
+		}
+		if utf8.RuneCountInString(group) > announcementGroupMaxLength {
+			return fmt.Errorf("分组 %s 过长", group)
+		}
+		if !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	announcement.Groups = strings.Join(groups, ",")
+	if len(announcement.Groups) > 255 {
+		return errors.New("公告的目标分组过多")
+	}
+	announcement.Content = utils.SanitizeMarkdown(announcement.Content)
+	return nil
+}
+
+// announcementTargets 判断公告是否面向该角色与分组的用户，Roles 与 Groups 为空时不限制
+func announcementTargets(announcement *model.Announcement, role int, group string) bool {
+	if announcement.Roles != "" && !slices.Contains(strings.Split(announcement.Roles, ","), strconv.Itoa(role)) {
+		return false
+	}
+	if announcement.Groups != "" && !slices.Contains(strings.Split(announcement.Groups, ","), group) {
+		return false
+	}
+	return true
+}
+
+// isPublicAnnouncement 没有限制角色与分组的公告，未登录的用户也可以看到
+func isPublicAnnouncement(announcement *model.Announcement) bool {
+	return announcement.Roles == "" && announcement.Groups == ""
+}
+
+// getActiveAnnouncements 返回当前时间在生效时间内的公告，按开始时间从新到旧排序
+func getActiveAnnouncements(ctx context.Context) ([]*model.Announcement, error) {
+	now := utils.GetTimestamp()
+	announcements := make([]*model.Announcement, 0)
+	err := initialize.DB.WithContext(ctx).
+		Where("start_time <= ? AND (end_time = ? OR end_time > ?)", now, 0, now).
+		Order("start_time desc, id desc").
+		Find(&announcements).Error
+	return announcements, err
+}
+
+// notifyAnnouncement 公告已经生效时通知在线的用户重新获取公告，目标用户由获取公告的接口筛选
+func notifyAnnouncement(ctx context.Context, announcement *model.Announcement) {
+	now := utils.GetTimestamp()
+	if announcement.StartTime > now || (announcement.EndTime != 0 && announcement.EndTime <= now) {
+		return
+	}
+	publishAnnouncement(ctx, announcement.Id)
+}
+
+func publishAnnouncement(ctx context.Context, id int) {
+	publishUserEvent(ctx, &userEventMessage{
+		All:   true,
+		Event: &model.UserEvent{Event: model.UserEventAnnouncement, Data: id},
+	})
+}
+
+// RunAnnouncementScheduler 定期检查开始时间已到的公告，通知在线的用户重新获取公告，直到 ctx 结束。
+// 添加或修改时已经生效的公告由 notifyAnnouncement 直接通知
+func RunAnnouncementScheduler(ctx context.Context) {
+	ticker := time.NewTicker(announcementScheduleInterval)
+	defer ticker.Stop()
+	lastCheck := utils.GetTimestamp()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := utils.GetTimestamp()
+			if err := notifyStartedAnnouncements(ctx, lastCheck, now); err != nil {
+				logger.SysError("failed to check scheduled announcements: " + err.Error())
+				continue
+			}
+			lastCheck = now
+		}
+	}
+}
+
+// notifyStartedAnnouncements 通知开始时间在 (from, to] 之间并且仍在生效的公告
+func notifyStartedAnnouncements(ctx context.Context, from int64, to int64) error {
+	var announcements []*model.Announcement
+	err := initialize.DB.WithContext(ctx).
+		Where("start_time > ? AND start_time <= ? AND (end_time = ? OR end_time > ?)", from, to, 0, to).
+		Find(&announcements).Error
+	if err != nil {
+		return err
+	}
+	for _, announcement := range announcements {
+		publishAnnouncement(ctx, announcement.Id)
+	}
+	return nil
+}
+
+// GetAnnouncements 按 ID 从新到旧返回所有公告，包括未生效与已结束的公告
+func GetAnnouncements(ctx context.Context, startIdx int, num int) ([]*model.Announcement, error) {
+	announcements := make([]*model.Announcement, 0)
+	err := initialize.DB.WithContext(ctx).Order("id desc").Limit(num).Offset(startIdx).Find(&announcements).Error
+	return announcements, err
+}
+
+func GetAnnouncementStats(ctx context.Context, id int) (*model.AnnouncementStats, error) {
+	var count int64
+	if err := initialize.DB.WithContext(ctx).Model(model.NewAnnouncement()).Where("id = ?", id).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, errors.New("公告不存在")
+	}
+	stats := &model.AnnouncementStats{}
+	query := initialize.DB.WithContext(ctx).Model(model.NewAnnouncementReceipt()).Where("announcement_id = ?", id)
+	if err := query.Session(&gorm.Session{}).Where("read_at > ?", 0).Count(&stats.Read).Error; err != nil {
+		return nil, err
+	}
+	if err := query.Session(&gorm.Session{}).Where("dismissed_at > ?", 0).Count(&stats.Dismissed).Error; err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// CreateAnnouncement 添加公告，公告的标题与内容在保存前会被清理
+func CreateAnnouncement(ctx context.Context, announcement *model.Announcement, creatorId int) error {
+	if err := validateAnnouncement(announcement); err != nil {
+		return err
+	}
+	announcement.Id = 0
+	announcement.CreatorId = creatorId
+	announcement.CreatedAt = utils.GetTimestamp()
+	announcement.UpdatedAt = announcement.CreatedAt
+	if err := initialize.DB.WithContext(ctx).Create(announcement).Error; err != nil {
+		return err
+	}
+	notifyAnnouncement(ctx, announcement)
+	return nil
+}
+
+// UpdateAnnouncement 修改公告，用户的阅读与关闭记录保持不变
+func UpdateAnnouncement(ctx context.Context, announcement *model.Announcement) error {
+	old := model.NewAnnouncement()
+	if err := initialize.DB.WithContext(ctx).First(old, "id = ?", announcement.Id).Error; err != nil {
+		return errors.New("公告不存在")
+	}
+	if err := validateAnnouncement(announcement); err != nil {
+		return err
+	}
+	announcement.UpdatedAt = utils.GetTimestamp()
+	fields := []string{"title", "content", "severity", "start_time", "end_time", "roles", "groups", "updated_at"}
+	if err := initialize.DB.WithContext(ctx).Model(old).Select(fields).Updates(announcement).Error; err != nil {
+		return err
+	}
+	notifyAnnouncement(ctx, announcement)
+	return nil
+}
+
+// DeleteAnnouncement 删除公告及用户的阅读与关闭记录
+func DeleteAnnouncement(ctx context.Context, id int) error {
+	return initialize.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(model.NewAnnouncement(), "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("公告不存在")
+		}
+		return tx.Where("announcement_id = ?", id).Delete(model.NewAnnouncementReceipt()).Error
+	})
+}
+
+// GetUserAnnouncements 返回面向该用户的生效中的公告与用户的阅读记录，includeDismissed 为 false 时不返回已关闭的公告
+func GetUserAnnouncements(ctx context.Context, userId int, includeDismissed bool) ([]*model.UserAnnouncement, error) {
+	user, err := GetUserById(ctx, userId, false)
+	if err != nil {
+		return nil, err
+	}
+	announcements, err := getActiveAnnouncements(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for _, announcement := range announcements {
+		if announcementTargets(announcement, user.Role, user.Group) {
+			ids = append(ids, announcement.Id)
+		}
+	}
+	result := make([]*model.UserAnnouncement, 0, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+	var receipts []*model.AnnouncementReceipt
+	err = initialize.DB.WithContext(ctx).Where("user_id = ? AND announcement_id IN ?", userId, ids).Find(&receipts).Error
+	if err != nil {
+		return nil, err
+	}
+	receiptMap := make(map[int]*model.AnnouncementReceipt, len(receipts))
+	for _, receipt := range receipts {
+		receiptMap[receipt.AnnouncementId] = receipt
+	}
+	for _, announcement := range announcements {
+		if !slices.Contains(ids, announcement.Id) {
+			continue
+		}
+		item := &model.UserAnnouncement{Announcement: announcement}
+		if receipt, ok := receiptMap[announcement.Id]; ok {
+			item.ReadAt, item.DismissedAt = receipt.ReadAt, receipt.DismissedAt
+		}
+		if item.DismissedAt != 0 && !includeDismissed {
+			continue
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// ReadAnnouncement 记录用户阅读了公告，已读的公告保持原来的阅读时间
+func ReadAnnouncement(ctx context.Context, userId int, id int) error {
+	return saveAnnouncementReceipt(ctx, userId, id, false)
+}
+
+// DismissAnnouncement 记录用户关闭了公告，关闭的公告同时视为已读
+func DismissAnnouncement(ctx context.Context, userId int, id int) error {
+	return saveAnnouncementReceipt(ctx, userId, id, true)
+}
+
+func saveAnnouncementReceipt(ctx context.Context, userId int, id int, dismiss bool) error {
+	announcements, err := GetUserAnnouncements(ctx, userId, true)
+	if err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(announcements, func(a *model.UserAnnouncement) bool { return a.Id == id }) {
+		return errors.New("公告不存在")
+	}
+	now := utils.GetTimestamp()
+	return initialize.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		receipt := model.NewAnnouncementReceipt()
+		if err := tx.Where("announcement_id = ? AND user_id = ?", id, userId).Limit(1).Find(receipt).Error; err != nil {
+			return err
+		}
+		if receipt.Id == 0 {
+			receipt.AnnouncementId, receipt.UserId, receipt.ReadAt = id, userId, now
+			if dismiss {
+				receipt.DismissedAt = now
+			}
+			return tx.Create(receipt).Error
+		}
+		updates := map[string]any{}
+		if receipt.ReadAt == 0 {
+			updates["read_at"] = now
+		}
+		if dismiss && receipt.DismissedAt == 0 {
+			updates["dismissed_at"] = now
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		return tx.Model(receipt).Updates(updates).Error
+	})
+}
+
+// GetNotice 返回 /api/notice 的内容：系统设置中的公告，以及生效中的、不限制角色与分组的公告，以分隔线连接
+func GetNotice(ctx context.Context) string {
+	global.OptionMapRWMutex.RLock()
+	notice := global.OptionMap["Notice"]
+	global.OptionMapRWMutex.RUnlock()
+	announcements, err := getActiveAnnouncements(ctx)
+	if err != nil {
+		logger.SysError("failed to get announcements: " + err.Error())
+		return notice
+	}
+	var parts []string
+	if notice != "" {
+		parts = append(parts, notice)
+	}
+	for _, announcement := range announcements {
+		if isPublicAnnouncement(announcement) {
+			parts = append(parts, "### "+announcement.Title+"\n\n"+announcement.Content)
+		}
+	}
+	return strings.Join(parts, "\n\n---\n\n")
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils"
+)
+
+func setupAnnouncementTest(t *testing.T) {
+	setupTestDB(t, nil)
+	global.OptionMapRWMutex.Lock()
+	if global.OptionMap == nil {
+		global.OptionMap = make(map[string]string)
+	}
+	oldNotice := global.OptionMap["Notice"]
+	global.OptionMap["Notice"] = "legacy notice"
+	global.OptionMapRWMutex.Unlock()
+	t.Cleanup(func() {
+		global.OptionMapRWMutex.Lock()
+		global.OptionMap["Notice"] = oldNotice
+		global.OptionMapRWMutex.Unlock()
+	})
+}
+
+func userAnnouncementIds(userId int, includeDismissed bool) []int {
+	announcements, err := GetUserAnnouncements(context.Background(), userId, includeDismissed)
+	So(err, ShouldBeNil)
+	ids := make([]int, len(announcements))
+	for i, announcement := range announcements {
+		ids[i] = announcement.Id
+	}
+	return ids
+}
+
+func TestAnnouncement(t *testing.T) {
+	Convey("TestAnnouncement", t, func() {
+		setupAnnouncementTest(t)
+		ctx := context.Background()
+		alice := &model.User{Username: "alice", Password: "12345678", Group: "vip"}
+		bob := &model.User{Username: "bob", Password: "12345678", Role: RoleAdminUser}
+		for _, user := range []*model.User{alice, bob} {
+			So(InsertUser(ctx, user, 0), ShouldBeNil)
+		}
+		now := utils.GetTimestamp()
+
+		So(CreateAnnouncement(ctx, &model.Announcement{Title: " "}, 1), ShouldNotBeNil)
+		So(CreateAnnouncement(ctx, &model.Announcement{Title: "a", Roles: "2"}, 1), ShouldNotBeNil)
+		So(CreateAnnouncement(ctx, &model.Announcement{Title: "a", StartTime: now, EndTime: now}, 1), ShouldNotBeNil)
+
+		// 保存时清理内容，规范化目标角色与分组
+		public := &model.Announcement{Title: "<b>maintenance</b>", Content: "<script>alert(1)</script> [x](javascript:alert(1))"}
+		So(CreateAnnouncement(ctx, public, 1), ShouldBeNil)
+		So(public.Severity, ShouldEqual, model.AnnouncementSeverityInfo)
+		So(public.Title, ShouldEqual, "&lt;b>maintenance&lt;/b>")
+		So(public.Content, ShouldEqual, "&lt;script>alert(1)&lt;/script> [x](#)")
+		vip := &model.Announcement{Title: "vip", Severity: model.AnnouncementSeverityCritical, Groups: " vip, vip ,"}
+		So(CreateAnnouncement(ctx, vip, 1), ShouldBeNil)
+		So(vip.Groups, ShouldEqual, "vip")
+		admins := &model.Announcement{Title: "admins", Roles: "10,100"}
+		So(CreateAnnouncement(ctx, admins, 1), ShouldBeNil)
+		expired := &model.Announcement{Title: "expired", StartTime: now - 20, EndTime: now - 10}
+		So(CreateAnnouncement(ctx, expired, 1), ShouldBeNil)
+		scheduled := &model.Announcement{Title: "scheduled", StartTime: now + 3600}
+		So(CreateAnnouncement(ctx, scheduled, 1), ShouldBeNil)
+
+		// 只返回生效中的、面向该用户的公告
+		So(userAnnouncementIds(alice.Id, false), ShouldResemble, []int{vip.Id, public.Id})
+		So(userAnnouncementIds(bob.Id, false), ShouldResemble, []int{admins.Id, public.Id})
+
+		// /api/notice 只包含系统设置中的公告与公开的公告
+		So(GetNotice(ctx), ShouldEqual, "legacy notice\n\n---\n\n### "+public.Title+"\n\n"+public.Content)
+
+		// 定时公告到了开始时间才通知在线的用户
+		aliceEvents, cancelAlice := ListenUserEvents(ctx, alice.Id)
+		defer cancelAlice()
+		So(notifyStartedAnnouncements(ctx, now, now+3599), ShouldBeNil)
+		So(receiveUserEvent(aliceEvents), ShouldBeNil)
+		So(notifyStartedAnnouncements(ctx, now+3599, now+3600), ShouldBeNil)
+		So(receiveUserEvent(aliceEvents), ShouldResemble, &model.UserEvent{Event: model.UserEventAnnouncement, Data: scheduled.Id})
+
+		// 关闭的公告视为已读，默认不再返回
+		So(ReadAnnouncement(ctx, alice.Id, admins.Id), ShouldNotBeNil)
+		So(ReadAnnouncement(ctx, alice.Id, scheduled.Id), ShouldNotBeNil)
+		So(ReadAnnouncement(ctx, alice.Id, vip.Id), ShouldBeNil)
+		So(ReadAnnouncement(ctx, alice.Id, vip.Id), ShouldBeNil)
+		So(DismissAnnouncement(ctx, alice.Id, public.Id), ShouldBeNil)
+		So(userAnnouncementIds(alice.Id, false), ShouldResemble, []int{vip.Id})
+		announcements, err := GetUserAnnouncements(ctx, alice.Id, true)
+		So(err, ShouldBeNil)
+		So(announcements, ShouldHaveLength, 2)
+		So(announcements[1].ReadAt, ShouldBeGreaterThan, 0)
+		So(announcements[1].DismissedAt, ShouldBeGreaterThan, 0)
+		So(userAnnouncementIds(bob.Id, false), ShouldResemble, []int{admins.Id, public.Id})
+		stats, err := GetAnnouncementStats(ctx, public.Id)
+		So(err, ShouldBeNil)
+		So(*stats, ShouldResemble, model.AnnouncementStats{Read: 1, Dismissed: 1})
+
+		// 修改目标后按新的目标筛选，删除时同时删除阅读记录
+		vip.Groups = ""
+		vip.Roles = "10"
+		So(UpdateAnnouncement(ctx, vip), ShouldBeNil)
+		So(userAnnouncementIds(alice.Id, true), ShouldResemble, []int{public.Id})
+		So(DeleteAnnouncement(ctx, public.Id), ShouldBeNil)
+		So(DeleteAnnouncement(ctx, public.Id), ShouldNotBeNil)
+		var count int64
+		initialize.DB.Model(model.NewAnnouncementReceipt()).Where("announcement_id = ?", public.Id).Count(&count)
+		So(count, ShouldEqual, 0)
+		So(GetNotice(ctx), ShouldEqual, "legacy notice")
+	})
+}
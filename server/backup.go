@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+)
+
+// CreateBackup 在配置的备份目录中生成备份，并清理超出保留个数的旧备份
+func CreateBackup() (string, error) {
+	cfg := global.GetConfig().Backup
+	return initialize.CreateBackup(initialize.DB, cfg.Dir, cfg.Retention)
+}
+
+// CreateTempBackup 在临时目录中生成备份，调用方使用后负责删除
+func CreateTempBackup() (string, error) {
+	dir, err := os.MkdirTemp("", "one-api-backup-")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "one-api.db")
+	if err = initialize.BackupSQLite(initialize.DB, path); err != nil {
+		_ = os.RemoveAll(dir)
+		return "", err
+	}
+	return path, nil
+}
+
+func ListBackups() ([]*initialize.BackupFile, error) {
+	return initialize.ListBackups(global.GetConfig().Backup.Dir)
+}
+
+// RunScheduledBackups 按配置的间隔定时备份 SQLite 数据库，直到 ctx 结束
+func RunScheduledBackups(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			path, err := CreateBackup()
+			if err != nil {
+				logger.SysError("scheduled backup failed: " + err.Error())
+				Notify(ctx, model.NotificationEventSystemError, "定时备份失败", err.Error())
+				continue
+			}
+			logger.SysLog("database backed up to " + path)
+		}
+	}
+}
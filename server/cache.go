@@ -1,9 +1,11 @@
 package server
 
 import (
+	"context"
 	"fmt"
 
 	// "sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/9688101/hx-admin/core/logger"
@@ -19,17 +21,17 @@ var (
 	GroupModelsCacheSeconds   = global.SyncFrequency
 )
 
-func CacheGetUserGroup(id int) (group string, err error) {
+func CacheGetUserGroup(ctx context.Context, id int) (group string, err error) {
 	if !initialize.RedisEnabled {
-		return GetUserGroup(id)
+		return GetUserGroup(ctx, id)
 	}
-	group, err = initialize.RedisGet(fmt.Sprintf("user_group:%d", id))
+	group, err = initialize.RedisGet(ctx, fmt.Sprintf("user_group:%d", id))
 	if err != nil {
-		group, err = GetUserGroup(id)
+		group, err = GetUserGroup(ctx, id)
 		if err != nil {
 			return "", err
 		}
-		err = initialize.RedisSet(fmt.Sprintf("user_group:%d", id), group, time.Duration(UserId2GroupCacheSeconds)*time.Second)
+		err = initialize.RedisSet(ctx, fmt.Sprintf("user_group:%d", id), group, time.Duration(UserId2GroupCacheSeconds)*time.Second)
 		if err != nil {
 			logger.SysError("Redis set user group error: " + err.Error())
 		}
@@ -37,16 +39,16 @@ func CacheGetUserGroup(id int) (group string, err error) {
 	return group, err
 }
 
-func CacheIsUserEnabled(userId int) (bool, error) {
+func CacheIsUserEnabled(ctx context.Context, userId int) (bool, error) {
 	if !initialize.RedisEnabled {
-		return IsUserEnabled(userId)
+		return IsUserEnabled(ctx, userId)
 	}
-	enabled, err := initialize.RedisGet(fmt.Sprintf("user_enabled:%d", userId))
+	enabled, err := initialize.RedisGet(ctx, fmt.Sprintf("user_enabled:%d", userId))
 	if err == nil {
 		return enabled == "1", nil
 	}
 
-	userEnabled, err := IsUserEnabled(userId)
+	userEnabled, err := IsUserEnabled(ctx, userId)
 	if err != nil {
 		return false, err
 	}
@@ -54,9 +56,48 @@ func CacheIsUserEnabled(userId int) (bool, error) {
 	if userEnabled {
 		enabled = "1"
 	}
-	err = initialize.RedisSet(fmt.Sprintf("user_enabled:%d", userId), enabled, time.Duration(UserId2StatusCacheSeconds)*time.Second)
+	err = initialize.RedisSet(ctx, fmt.Sprintf("user_enabled:%d", userId), enabled, time.Duration(UserId2StatusCacheSeconds)*time.Second)
 	if err != nil {
 		logger.SysError("Redis set user enabled error: " + err.Error())
 	}
 	return userEnabled, err
 }
+
+// cacheKeyPatterns Redis 中由本服务维护的缓存
+var cacheKeyPatterns = []string{"user_group:*", "user_enabled:*", "user_quota:*", "token:*"}
+
+// InvalidateUserCache 删除 Redis 中缓存的用户状态与分组，使各节点重新从数据库读取
+func InvalidateUserCache(ctx context.Context, id int) {
+	if !initialize.RedisEnabled {
+		return
+	}
+	for _, key := range []string{fmt.Sprintf("user_enabled:%d", id), fmt.Sprintf("user_group:%d", id)} {
+		if err := initialize.RedisDel(ctx, key); err != nil {
+			logger.SysError("Redis del error: " + err.Error())
+		}
+	}
+}
+
+// FlushCache 删除 Redis 中的缓存，includeRateLimit 为 true 时同时清空限流记录，返回删除的 key 数量
+func FlushCache(ctx context.Context, includeRateLimit bool) (int64, error) {
+	if !initialize.RedisEnabled {
+		return 0, nil
+	}
+	patterns := append([]string(nil), cacheKeyPatterns...)
+	if includeRateLimit {
+		patterns = append(patterns, "rateLimit:*")
+	}
+	var deleted atomic.Int64
+	for _, pattern := range patterns {
+		// 集群模式下不同的 key 可能位于不同的节点，逐个删除
+		err := initialize.RedisScan(ctx, pattern, 1000, func(key string) error {
+			n, err := initialize.RDB.Del(ctx, key).Result()
+			deleted.Add(n)
+			return err
+		})
+		if err != nil {
+			return deleted.Load(), err
+		}
+	}
+	return deleted.Load(), nil
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"runtime"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils"
+)
+
+// GetRuntimeStats collects the goroutine, memory, connection pool and cache statistics of this node.
+// Reading the memory statistics briefly stops the world, so it should not be polled too often.
+func GetRuntimeStats() *model.RuntimeStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	stats := &model.RuntimeStats{
+		StartTime:    global.StartTime,
+		Uptime:       utils.GetTimestamp() - global.StartTime,
+		GoVersion:    runtime.Version(),
+		NumCPU:       runtime.NumCPU(),
+		NumGoroutine: runtime.NumGoroutine(),
+		Memory: model.MemoryStats{
+			Alloc:        m.Alloc,
+			Sys:          m.Sys,
+			HeapAlloc:    m.HeapAlloc,
+			HeapInuse:    m.HeapInuse,
+			HeapIdle:     m.HeapIdle,
+			HeapObjects:  m.HeapObjects,
+			NumGC:        m.NumGC,
+			PauseTotalMs: time.Duration(m.PauseTotalNs).Milliseconds(),
+		},
+		DB:     make(map[string]*model.DBPoolStats),
+		Caches: make(map[string]int),
+	}
+	if m.LastGC > 0 {
+		stats.Memory.LastGC = time.Unix(0, int64(m.LastGC)).UnixMilli()
+	}
+	if s := dbPoolStats(initialize.DB); s != nil {
+		stats.DB["db"] = s
+	}
+	if initialize.LOG_DB != initialize.DB {
+		if s := dbPoolStats(initialize.LOG_DB); s != nil {
+			stats.DB["log_db"] = s
+		}
+	}
+
+	global.OptionMapRWMutex.RLock()
+	stats.Caches["options"] = len(global.OptionMap)
+	global.OptionMapRWMutex.RUnlock()
+	stats.Caches["verification_codes"] = utils.VerificationCodeCount()
+	stats.Caches["banned_users"] = len(utils.BannedUserIds())
+	return stats
+}
+
+func dbPoolStats(db *gorm.DB) *model.DBPoolStats {
+	if db == nil {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil
+	}
+	s := sqlDB.Stats()
+	return &model.DBPoolStats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDurationMs:     s.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
+	}
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+// CheckDependencies pings the databases and Redis concurrently, each with its own timeout.
+// The node is ready only if none of the enabled components is down. The errors are only logged,
+// since the readiness probe is served to unauthenticated callers.
+func CheckDependencies(ctx context.Context) (ready bool, components map[string]*model.ComponentStatus) {
+	components = make(map[string]*model.ComponentStatus)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	check := func(name string, f func(ctx context.Context) (*model.ComponentStatus, error)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			status, err := f(ctx)
+			if err != nil {
+				logger.SysError(fmt.Sprintf("readiness check of %s failed: %s", name, err.Error()))
+			}
+			mu.Lock()
+			components[name] = status
+			mu.Unlock()
+		}()
+	}
+	check("db", func(ctx context.Context) (*model.ComponentStatus, error) {
+		status, err := pingDB(ctx, initialize.DB)
+		status.Migration = initialize.MigrationState
+		return status, err
+	})
+	check("log_db", func(ctx context.Context) (*model.ComponentStatus, error) {
+		status, err := pingDB(ctx, initialize.LOG_DB)
+		status.Migration = initialize.LogMigrationState
+		return status, err
+	})
+	check("redis", pingRedis)
+	wg.Wait()
+
+	ready = true
+	for _, status := range components {
+		if status.Status == model.ComponentStatusDown {
+			ready = false
+		}
+	}
+	return ready, components
+}
+
+func pingDB(ctx context.Context, db *gorm.DB) (*model.ComponentStatus, error) {
+	if db == nil {
+		return &model.ComponentStatus{Status: model.ComponentStatusDown}, errors.New("database not initialized")
+	}
+	start := time.Now()
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	return newComponentStatus(start, err)
+}
+
+func pingRedis(ctx context.Context) (*model.ComponentStatus, error) {
+	if !initialize.RedisEnabled {
+		return &model.ComponentStatus{Status: model.ComponentStatusDisabled}, nil
+	}
+	start := time.Now()
+	err := initialize.RDB.Ping(ctx).Err()
+	return newComponentStatus(start, err)
+}
+
+func newComponentStatus(start time.Time, err error) (*model.ComponentStatus, error) {
+	status := &model.ComponentStatus{
+		Status:    model.ComponentStatusUp,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		status.Status = model.ComponentStatusDown
+	}
+	return status, err
+}
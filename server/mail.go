@@ -0,0 +1,264 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils"
+	"github.com/9688101/hx-admin/utils/message"
+)
+
+const (
+	MailStatusQueued  = 1
+	MailStatusSending = 2
+	MailStatusSent    = 3
+	MailStatusFailed  = 4 // 达到最大发送次数，不再重试
+)
+
+const (
+	mailBatchSize   = 10
+	mailLockSeconds = 5 * 60 // 发送中的邮件超过该时间未完成，视为节点已崩溃，重新发送
+	maxRetryBackoff = time.Hour
+)
+
+var ErrMailRateLimited = errors.New("发送给该邮箱的邮件过多，请稍后再试")
+
+// mailQueueWakeup 有新邮件时唤醒本节点的发送任务，不必等到下一次轮询
+var mailQueueWakeup = make(chan struct{}, 1)
+
+func wakeMailQueue() {
+	select {
+	case mailQueueWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// QueueEmail 将渲染后的邮件保存到发件队列，由后台任务发送，发送失败时按指数退避重试
+func QueueEmail(ctx context.Context, receiver string, email *message.Email) error {
+	if receiver == "" {
+		return errors.New("收件人为空")
+	}
+	if global.SMTPServer == "" {
+		return errors.New("SMTP 服务器未配置")
+	}
+	now := utils.GetTimestamp()
+	cfg := global.GetConfig().MailQueue
+	if cfg.RecipientLimit > 0 {
+		var count int64
+		err := initialize.DB.WithContext(ctx).Model(model.NewMail()).
+			Where("receiver = ? AND created_at > ?", receiver, now-int64(cfg.RecipientWindow)).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count >= int64(cfg.RecipientLimit) {
+			return ErrMailRateLimited
+		}
+	}
+	mail := &model.Mail{
+		Receiver:      receiver,
+		Subject:       email.Subject,
+		Content:       email.HTML,
+		TextContent:   email.Text,
+		Status:        MailStatusQueued,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if err := initialize.DB.WithContext(ctx).Create(mail).Error; err != nil {
+		return err
+	}
+	logger.Infof(ctx, "mail %d to %s queued", mail.Id, receiver)
+	wakeMailQueue()
+	return nil
+}
+
+// QueueLoginAlertEmail 管理员登录后向其邮箱发送登录提醒，没有绑定邮箱或未配置 SMTP 时不发送。
+// 发送失败只记录日志，不影响登录
+func QueueLoginAlertEmail(ctx context.Context, user *model.User, lang string, ip string, userAgent string) {
+	if user.Email == "" || global.SMTPServer == "" {
+		return
+	}
+	mail, err := message.RenderEmail(message.EmailEventLoginAlert, lang, map[string]any{
+		"Username":  user.Username,
+		"Time":      time.Now().Format("2006-01-02 15:04:05"),
+		"IP":        ip,
+		"UserAgent": userAgent,
+	})
+	if err == nil {
+		err = QueueEmail(ctx, user.Email, mail)
+	}
+	if err != nil {
+		logger.Errorf(ctx, "failed to queue login alert mail to user %d: %s", user.Id, err.Error())
+	}
+}
+
+// RunMailQueue 定期发送队列中到期的邮件，直到 ctx 结束。所有节点都可以运行，每封邮件只会被一个节点取走。
+func RunMailQueue(ctx context.Context) {
+	interval := time.Duration(global.GetConfig().MailQueue.PollInterval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastPurge time.Time
+	for {
+		processMailQueue(ctx)
+		if time.Since(lastPurge) > time.Hour {
+			purgeMails(ctx)
+			lastPurge = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-mailQueueWakeup:
+		}
+	}
+}
+
+func processMailQueue(ctx context.Context) {
+	// 发送中途崩溃的节点留下的邮件重新排队
+	err := initialize.DB.WithContext(ctx).Model(model.NewMail()).
+		Where("status = ? AND locked_until < ?", MailStatusSending, utils.GetTimestamp()).
+		Updates(map[string]any{"status": MailStatusQueued, "locked_by": ""}).Error
+	if err != nil {
+		logger.SysError("failed to requeue stale mails: " + err.Error())
+		return
+	}
+	for ctx.Err() == nil {
+		var mails []*model.Mail
+		err = initialize.DB.WithContext(ctx).Where("status = ? AND next_attempt_at <= ?", MailStatusQueued, utils.GetTimestamp()).
+			Order("next_attempt_at").Limit(mailBatchSize).Find(&mails).Error
+		if err != nil {
+			logger.SysError("failed to load queued mails: " + err.Error())
+			return
+		}
+		if len(mails) == 0 {
+			return
+		}
+		claimed := false
+		for _, mail := range mails {
+			if claimMail(ctx, mail) {
+				claimed = true
+				deliverMail(ctx, mail)
+			}
+		}
+		if !claimed {
+			return
+		}
+	}
+}
+
+// claimMail 将邮件标记为由本节点发送，其他节点已经取走时返回 false
+func claimMail(ctx context.Context, mail *model.Mail) bool {
+	result := initialize.DB.WithContext(ctx).Model(mail).
+		Where("status = ?", MailStatusQueued).
+		Updates(map[string]any{
+			"status":       MailStatusSending,
+			"locked_by":    nodeId,
+			"locked_until": utils.GetTimestamp() + mailLockSeconds,
+		})
+	if result.Error != nil {
+		logger.SysError("failed to claim mail: " + result.Error.Error())
+		return false
+	}
+	return result.RowsAffected == 1
+}
+
+func deliverMail(ctx context.Context, mail *model.Mail) {
+	err := message.SendEmailWithText(mail.Subject, mail.Receiver, mail.Content, mail.TextContent)
+	mail.Attempts++
+	updates := map[string]any{
+		"attempts":     mail.Attempts,
+		"locked_by":    "",
+		"locked_until": 0,
+	}
+	now := utils.GetTimestamp()
+	switch {
+	case err == nil:
+		updates["status"] = MailStatusSent
+		updates["sent_at"] = now
+		updates["last_error"] = ""
+		logger.SysLogf("mail %d to %s sent", mail.Id, mail.Receiver)
+	case mail.Attempts >= global.GetConfig().MailQueue.MaxAttempts:
+		updates["status"] = MailStatusFailed
+		updates["last_error"] = err.Error()
+		logger.SysErrorf("mail %d to %s failed after %d attempts: %s", mail.Id, mail.Receiver, mail.Attempts, err.Error())
+		Notify(ctx, model.NotificationEventSystemError, "邮件发送失败",
+			fmt.Sprintf("发送给 %s 的邮件「%s」在 %d 次尝试后仍然失败：%s", mail.Receiver, mail.Subject, mail.Attempts, err.Error()))
+	default:
+		next := retryBackoff(global.GetConfig().MailQueue.RetryBackoff, mail.Attempts)
+		updates["status"] = MailStatusQueued
+		updates["next_attempt_at"] = now + int64(next.Seconds())
+		updates["last_error"] = err.Error()
+		logger.SysWarnf("mail %d to %s failed, retrying in %s: %s", mail.Id, mail.Receiver, next, err.Error())
+	}
+	// 服务关闭时 ctx 已经结束，发送结果仍然需要保存，否则邮件会被重新发送
+	err = initialize.DB.WithContext(context.WithoutCancel(ctx)).Model(mail).Where("locked_by = ?", nodeId).Updates(updates).Error
+	if err != nil {
+		logger.SysError("failed to update mail: " + err.Error())
+	}
+}
+
+// retryBackoff 第 attempts 次失败后等待的时间，第一次为 base 秒，之后每次翻倍
+func retryBackoff(base int, attempts int) time.Duration {
+	backoff := time.Duration(base) * time.Second
+	for i := 1; i < attempts && backoff < maxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxRetryBackoff)
+}
+
+// purgeMails 删除超过保留期限的已发送与发送失败的邮件，邮件中可能包含验证码与重置链接
+func purgeMails(ctx context.Context) {
+	retention := global.GetConfig().MailQueue.Retention
+	if retention <= 0 {
+		return
+	}
+	before := utils.GetTimestamp() - int64(retention)*24*60*60
+	result := initialize.DB.WithContext(ctx).Where("status IN ? AND created_at < ?", []int{MailStatusSent, MailStatusFailed}, before).Delete(model.NewMail())
+	if result.Error != nil {
+		logger.SysError("failed to purge mails: " + result.Error.Error())
+		return
+	}
+	if result.RowsAffected > 0 {
+		logger.SysLogf("purged %d old mails", result.RowsAffected)
+	}
+}
+
+// GetMails 按时间从新到旧返回队列中的邮件，不包括邮件内容，status 为 0 时返回所有状态的邮件
+func GetMails(ctx context.Context, status int, startIdx int, num int) ([]*model.Mail, error) {
+	mails := make([]*model.Mail, 0)
+	query := initialize.DB.WithContext(ctx).Omit("content", "text_content").Order("id desc").Limit(num).Offset(startIdx)
+	if status != 0 {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&mails).Error
+	return mails, err
+}
+
+// ResendMail 将已发送或发送失败的邮件重新放入队列，重置发送次数
+func ResendMail(ctx context.Context, id int) error {
+	result := initialize.DB.WithContext(ctx).Model(model.NewMailById(id)).
+		Where("status IN ?", []int{MailStatusSent, MailStatusFailed}).
+		Updates(map[string]any{
+			"status":          MailStatusQueued,
+			"attempts":        0,
+			"next_attempt_at": utils.GetTimestamp(),
+			"last_error":      "",
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("邮件不存在或正在发送中")
+	}
+	wakeMailQueue()
+	return nil
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils/message"
+)
+
+// GetEmailTemplates 返回所有事件在所有语言下当前使用的模板
+func GetEmailTemplates() ([]*model.EmailTemplate, error) {
+	templates := make([]*model.EmailTemplate, 0, len(message.EmailEvents)*len(message.EmailLanguages))
+	for _, event := range message.EmailEvents {
+		for _, lang := range message.EmailLanguages {
+			content, customized, err := message.EmailTemplateSource(event.Name, lang)
+			if err != nil {
+				return nil, err
+			}
+			templates = append(templates, &model.EmailTemplate{
+				Event:      event.Name,
+				Language:   lang,
+				Key:        message.EmailTemplateOptionKey(event.Name, lang),
+				Content:    content,
+				Customized: customized,
+				SampleData: event.SampleData,
+			})
+		}
+	}
+	return templates, nil
+}
+
+// PreviewEmailTemplate 使用示例数据渲染模板，content 为空时渲染当前使用的模板，data 中的字段覆盖示例数据
+func PreviewEmailTemplate(event string, lang string, content string, data map[string]any) (*message.Email, error) {
+	emailEvent := message.GetEmailEvent(event)
+	if emailEvent == nil {
+		return nil, fmt.Errorf("未知的邮件事件 %s", event)
+	}
+	if !message.IsEmailLanguage(lang) {
+		return nil, fmt.Errorf("不支持的邮件语言 %s", lang)
+	}
+	if content == "" {
+		var err error
+		if content, _, err = message.EmailTemplateSource(event, lang); err != nil {
+			return nil, err
+		}
+	}
+	values := maps.Clone(emailEvent.SampleData)
+	maps.Copy(values, data)
+	return message.RenderEmailTemplate(content, lang, values)
+}
+
+// validateEmailTemplate 保存模板前使用示例数据渲染一次，值为空表示恢复内置模板
+func validateEmailTemplate(key string, value string) error {
+	event, lang, ok := message.ParseEmailTemplateOptionKey(key)
+	if !ok {
+		return fmt.Errorf("无效的邮件模板配置项 %s", key)
+	}
+	if value == "" {
+		if message.GetEmailEvent(event) == nil || !message.IsEmailLanguage(lang) {
+			return fmt.Errorf("无效的邮件模板配置项 %s", key)
+		}
+		return nil
+	}
+	if _, err := PreviewEmailTemplate(event, lang, value, nil); err != nil {
+		return fmt.Errorf("邮件模板 %s 无效：%w", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/9688101/hx-admin/config"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils/message"
+	"github.com/9688101/hx-admin/utils/message/smtptest"
+)
+
+func setupMailTest(t *testing.T) *smtptest.Server {
+	setupTestDB(t, &config.Config{MailQueue: config.MailQueue{
+		MaxAttempts:     2,
+		RetryBackoff:    30,
+		RecipientLimit:  3,
+		RecipientWindow: 3600,
+	}})
+	smtp, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = smtp.Close() })
+
+	oldServer, oldPort, oldFrom := global.SMTPServer, global.SMTPPort, global.SMTPFrom
+	t.Cleanup(func() {
+		global.SMTPServer, global.SMTPPort, global.SMTPFrom = oldServer, oldPort, oldFrom
+	})
+	global.SMTPServer, global.SMTPPort, global.SMTPFrom = smtp.Host(), smtp.Port(), "noreply@example.com"
+	return smtp
+}
+
+func getMail(receiver string) *model.Mail {
+	mail := model.NewMail()
+	So(initialize.DB.Where("receiver = ?", receiver).Last(mail).Error, ShouldBeNil)
+	return mail
+}
+
+func TestMailQueue(t *testing.T) {
+	Convey("TestMailQueue", t, func() {
+		smtp := setupMailTest(t)
+		ctx := context.Background()
+		email := &message.Email{Subject: "hello", HTML: "<p>hi</p>", Text: "hi"}
+
+		So(QueueEmail(ctx, "a@example.com", email), ShouldBeNil)
+		processMailQueue(ctx)
+		So(smtp.Messages(), ShouldHaveLength, 1)
+		So(smtp.Messages()[0].To, ShouldResemble, []string{"a@example.com"})
+		So(strings.Contains(smtp.Messages()[0].Data, "multipart/alternative"), ShouldBeTrue)
+		So(strings.Contains(smtp.Messages()[0].Data, "<p>hi</p>"), ShouldBeTrue)
+		mail := getMail("a@example.com")
+		So(mail.Status, ShouldEqual, MailStatusSent)
+		So(mail.Attempts, ShouldEqual, 1)
+
+		// 失败后按退避时间重试，达到最大次数后不再重试
+		smtp.FailNext(2)
+		So(QueueEmail(ctx, "b@example.com", email), ShouldBeNil)
+		processMailQueue(ctx)
+		mail = getMail("b@example.com")
+		So(mail.Status, ShouldEqual, MailStatusQueued)
+		So(mail.Attempts, ShouldEqual, 1)
+		So(mail.LastError, ShouldNotBeEmpty)
+		So(mail.NextAttemptAt, ShouldBeGreaterThan, mail.CreatedAt)
+		processMailQueue(ctx)
+		So(getMail("b@example.com").Attempts, ShouldEqual, 1)
+
+		So(initialize.DB.Model(mail).Update("next_attempt_at", 0).Error, ShouldBeNil)
+		processMailQueue(ctx)
+		mail = getMail("b@example.com")
+		So(mail.Status, ShouldEqual, MailStatusFailed)
+		So(mail.Attempts, ShouldEqual, 2)
+
+		So(ResendMail(ctx, mail.Id), ShouldBeNil)
+		processMailQueue(ctx)
+		So(getMail("b@example.com").Status, ShouldEqual, MailStatusSent)
+		So(smtp.Messages(), ShouldHaveLength, 2)
+
+		mails, err := GetMails(ctx, MailStatusSent, 0, 10)
+		So(err, ShouldBeNil)
+		So(mails, ShouldHaveLength, 2)
+		So(mails[0].Content, ShouldBeEmpty)
+
+		// 每个收件人在时间窗口内的邮件数有上限
+		So(QueueEmail(ctx, "a@example.com", email), ShouldBeNil)
+		So(QueueEmail(ctx, "a@example.com", email), ShouldBeNil)
+		So(QueueEmail(ctx, "a@example.com", email), ShouldEqual, ErrMailRateLimited)
+	})
+}
+
+func TestLoginAlertEmail(t *testing.T) {
+	Convey("TestLoginAlertEmail", t, func() {
+		setupMailTest(t)
+		ctx := context.Background()
+
+		// 没有绑定邮箱时不发送
+		QueueLoginAlertEmail(ctx, &model.User{Id: 1, Username: "root"}, "en", "203.0.113.1", "curl")
+		var count int64
+		So(initialize.DB.Model(model.NewMail()).Count(&count).Error, ShouldBeNil)
+		So(count, ShouldEqual, 0)
+
+		QueueLoginAlertEmail(ctx, &model.User{Id: 1, Username: "root", Email: "root@example.com"}, "zh-CN", "203.0.113.1", "curl")
+		mail := getMail("root@example.com")
+		So(mail.Subject, ShouldEndWith, "登录提醒")
+		So(mail.TextContent, ShouldContainSubstring, "203.0.113.1")
+	})
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils"
+	"github.com/9688101/hx-admin/utils/message"
+)
+
+const (
+	notificationMaxAttempts = 3
+	notificationTimeout     = 30 * time.Second
+	// systemErrorCooldown 相同标题的 system_error 通知的最短间隔，避免故障期间刷屏
+	systemErrorCooldown = time.Minute
+)
+
+// notificationRetryDelay 第 n 次发送失败后等待 n 倍的时间
+var notificationRetryDelay = 2 * time.Second
+
+var notificationEvents = []string{
+	model.NotificationEventUserRegister,
+	model.NotificationEventAdminAction,
+	model.NotificationEventSystemError,
+	model.NotificationEventLoginAlert,
+}
+
+var notificationChannels atomic.Pointer[[]*model.NotificationChannel]
+
+// notificationDeliveries 发送中的通知，退出时由 WaitNotifications 等待
+var notificationDeliveries sync.WaitGroup
+
+// systemErrorSentAt 标题到上次发送 system_error 通知的时间
+var systemErrorSentAt sync.Map
+
+// ParseNotificationChannels 解析并校验 NotificationChannels 配置项的值
+func ParseNotificationChannels(value string) ([]*model.NotificationChannel, error) {
+	var channels []*model.NotificationChannel
+	if strings.TrimSpace(value) == "" {
+		return channels, nil
+	}
+	if err := json.Unmarshal([]byte(value), &channels); err != nil {
+		return nil, fmt.Errorf("通知渠道不是有效的 JSON：%w", err)
+	}
+	names := make(map[string]bool, len(channels))
+	for i, channel := range channels {
+		if channel == nil {
+			return nil, fmt.Errorf("第 %d 个通知渠道为空", i+1)
+		}
+		if names[channel.Name] {
+			return nil, fmt.Errorf("通知渠道 %s 重复", channel.Name)
+		}
+		names[channel.Name] = true
+		if err := validateNotificationChannel(channel); err != nil {
+			return nil, err
+		}
+		for _, event := range channel.Events {
+			if !slices.Contains(notificationEvents, event) {
+				return nil, fmt.Errorf("通知渠道 %s 的事件 %s 无效，可选的事件为 %s", channel.Name, event, strings.Join(notificationEvents, "、"))
+			}
+		}
+	}
+	return channels, nil
+}
+
+func validateNotificationChannel(channel *model.NotificationChannel) error {
+	if channel.Name == "" {
+		return errors.New("通知渠道的名称不能为空")
+	}
+	switch channel.Type {
+	case model.NotificationTypeWebhook, model.NotificationTypeFeishu, model.NotificationTypeDingTalk,
+		model.NotificationTypeWeCom, model.NotificationTypeSlack:
+		if channel.URL == "" {
+			return fmt.Errorf("通知渠道 %s 的地址不能为空", channel.Name)
+		}
+	case model.NotificationTypeMessagePusher:
+	case model.NotificationTypeEmail:
+		if len(channel.Receivers) == 0 {
+			return fmt.Errorf("通知渠道 %s 的收件人不能为空", channel.Name)
+		}
+		for _, receiver := range channel.Receivers {
+			if _, err := mail.ParseAddress(receiver); err != nil {
+				return fmt.Errorf("通知渠道 %s 的收件人 %s 无效", channel.Name, receiver)
+			}
+		}
+	default:
+		return fmt.Errorf("通知渠道 %s 的类型 %s 无效", channel.Name, channel.Type)
+	}
+	if channel.URL != "" {
+		u, err := url.Parse(channel.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("通知渠道 %s 的地址无效", channel.Name)
+		}
+	}
+	return nil
+}
+
+// UpdateNotificationChannelsByJSONString 替换当前使用的通知渠道
+func UpdateNotificationChannelsByJSONString(value string) error {
+	channels, err := ParseNotificationChannels(value)
+	if err != nil {
+		return err
+	}
+	notificationChannels.Store(&channels)
+	return nil
+}
+
+func newNotifier(channel *model.NotificationChannel) message.Notifier {
+	switch channel.Type {
+	case model.NotificationTypeWebhook:
+		return &message.WebhookNotifier{URL: channel.URL, Secret: channel.Secret}
+	case model.NotificationTypeFeishu:
+		return &message.FeishuNotifier{URL: channel.URL, Secret: channel.Secret}
+	case model.NotificationTypeDingTalk:
+		return &message.DingTalkNotifier{URL: channel.URL, Secret: channel.Secret}
+	case model.NotificationTypeWeCom:
+		return &message.WeComNotifier{URL: channel.URL}
+	case model.NotificationTypeSlack:
+		return &message.SlackNotifier{URL: channel.URL}
+	case model.NotificationTypeEmail:
+		return &message.EmailNotifier{Receivers: channel.Receivers}
+	case model.NotificationTypeMessagePusher:
+		return &message.MessagePusherNotifier{URL: channel.URL, Token: channel.Token}
+	}
+	return nil
+}
+
+// Notify 在后台向订阅了该事件的所有通知渠道发送通知，失败时重试，不会阻塞调用方
+func Notify(ctx context.Context, event string, title string, content string) {
+	channels := notificationChannels.Load()
+	if channels == nil {
+		return
+	}
+	if event == model.NotificationEventSystemError {
+		now := time.Now()
+		if last, ok := systemErrorSentAt.Load(title); ok && now.Sub(last.(time.Time)) < systemErrorCooldown {
+			return
+		}
+		systemErrorSentAt.Store(title, now)
+	}
+	notification := &message.Notification{
+		Event:   event,
+		Title:   title,
+		Content: content,
+		Time:    utils.GetTimestamp(),
+	}
+	for _, channel := range *channels {
+		if channel.Disabled || !slices.Contains(channel.Events, event) {
+			continue
+		}
+		notificationDeliveries.Add(1)
+		go func() {
+			defer notificationDeliveries.Done()
+			deliverNotification(context.WithoutCancel(ctx), channel, notification)
+		}()
+	}
+}
+
+// WaitNotifications 等待发送中的通知（包括重试）结束，或直到 ctx 结束，退出时调用
+func WaitNotifications(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		notificationDeliveries.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func deliverNotification(ctx context.Context, channel *model.NotificationChannel, notification *message.Notification) {
+	notifier := newNotifier(channel)
+	var err error
+	for attempt := 1; attempt <= notificationMaxAttempts; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, notificationTimeout)
+		err = notifier.Notify(attemptCtx, notification)
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt < notificationMaxAttempts {
+			logger.Warnf(ctx, "failed to send %s notification to channel %s, attempt %d: %s", notification.Event, channel.Name, attempt, err.Error())
+			time.Sleep(time.Duration(attempt) * notificationRetryDelay)
+		}
+	}
+	logger.Errorf(ctx, "failed to send %s notification to channel %s after %d attempts: %s", notification.Event, channel.Name, notificationMaxAttempts, err.Error())
+}
+
+func getNotificationChannel(name string) *model.NotificationChannel {
+	channels := notificationChannels.Load()
+	if channels == nil {
+		return nil
+	}
+	for _, channel := range *channels {
+		if channel.Name == name {
+			return channel
+		}
+	}
+	return nil
+}
+
+// TestNotificationChannel 立即向渠道发送一条测试通知，不重试，channel 为空时使用已保存的名为 name 的渠道
+func TestNotificationChannel(ctx context.Context, name string, channel *model.NotificationChannel) error {
+	if channel == nil {
+		if channel = getNotificationChannel(name); channel == nil {
+			return fmt.Errorf("通知渠道 %s 不存在", name)
+		}
+	} else if err := validateNotificationChannel(channel); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, notificationTimeout)
+	defer cancel()
+	return newNotifier(channel).Notify(ctx, &message.Notification{
+		Event:   model.NotificationEventTest,
+		Title:   "测试通知",
+		Content: fmt.Sprintf("这是一条来自通知渠道 %s 的测试通知。", channel.Name),
+		Time:    utils.GetTimestamp(),
+	})
+}
+
+// GetNotificationChannels 返回已配置的通知渠道，隐藏密钥以及地址中可能包含密钥的路径与参数
+func GetNotificationChannels() []*model.NotificationChannel {
+	result := make([]*model.NotificationChannel, 0)
+	channels := notificationChannels.Load()
+	if channels == nil {
+		return result
+	}
+	for _, channel := range *channels {
+		redacted := *channel
+		if redacted.Secret != "" {
+			redacted.Secret = redactedOptionValue
+		}
+		if redacted.Token != "" {
+			redacted.Token = redactedOptionValue
+		}
+		if u, err := url.Parse(redacted.URL); err == nil && u.Host != "" {
+			redacted.URL = u.Scheme + "://" + u.Host + "/" + redactedOptionValue
+		}
+		result = append(result, &redacted)
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils/message"
+)
+
+func TestNotification(t *testing.T) {
+	Convey("TestNotification", t, func() {
+		_, err := ParseNotificationChannels(`[{"name":"a","type":"webhook","events":["user_register"]}]`)
+		So(err, ShouldNotBeNil)
+		_, err = ParseNotificationChannels(`[{"name":"a","type":"webhook","url":"https://example.com","events":["nope"]}]`)
+		So(err, ShouldNotBeNil)
+		_, err = ParseNotificationChannels(`[{"name":"a","type":"email","receivers":["x"],"events":[]}]`)
+		So(err, ShouldNotBeNil)
+
+		var requests atomic.Int32
+		received := make(chan *message.Notification, 1)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 第一次请求失败，验证重试
+			if requests.Add(1) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			timestamp, _ := strconv.ParseInt(r.Header.Get("X-Webhook-Timestamp"), 10, 64)
+			if r.Header.Get("X-Webhook-Signature") != message.SignWebhook("key", timestamp, body) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var notification message.Notification
+			_ = json.Unmarshal(body, &notification)
+			received <- &notification
+		}))
+		defer srv.Close()
+
+		oldDelay := notificationRetryDelay
+		notificationRetryDelay = 10 * time.Millisecond
+		defer func() { notificationRetryDelay = oldDelay }()
+		defer notificationChannels.Store(nil)
+		channels, _ := json.Marshal([]*model.NotificationChannel{
+			{Name: "hook", Type: model.NotificationTypeWebhook, URL: srv.URL + "/hook?key=1", Secret: "key", Events: []string{model.NotificationEventUserRegister}},
+		})
+		So(UpdateNotificationChannelsByJSONString(string(channels)), ShouldBeNil)
+
+		Notify(context.Background(), model.NotificationEventAdminAction, "ignored", "")
+		Notify(context.Background(), model.NotificationEventUserRegister, "新用户注册", "someone")
+		select {
+		case notification := <-received:
+			So(notification.Event, ShouldEqual, model.NotificationEventUserRegister)
+			So(notification.Content, ShouldEqual, "someone")
+		case <-time.After(5 * time.Second):
+			So("notification not received", ShouldBeEmpty)
+		}
+		So(requests.Load(), ShouldEqual, 2)
+
+		redacted := GetNotificationChannels()
+		So(redacted[0].Secret, ShouldEqual, redactedOptionValue)
+		So(redacted[0].URL, ShouldNotContainSubstring, "key=1")
+
+		So(TestNotificationChannel(context.Background(), "hook", nil), ShouldBeNil)
+		So(TestNotificationChannel(context.Background(), "missing", nil), ShouldNotBeNil)
+
+		robot := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"errcode":310000,"errmsg":"sign not match"}`))
+		}))
+		defer robot.Close()
+		err = TestNotificationChannel(context.Background(), "", &model.NotificationChannel{Name: "ding", Type: model.NotificationTypeDingTalk, URL: robot.URL + "?access_token=x", Secret: "s"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "sign not match")
+	})
+}
@@ -1,6 +1,10 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -9,50 +13,327 @@ import (
 	"github.com/9688101/hx-admin/global" // 引入配置模块
 	"github.com/9688101/hx-admin/initialize"
 	"github.com/9688101/hx-admin/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func SyncOptions(frequency int) {
+// SyncOptions reloads all options from the database periodically until ctx is done
+func SyncOptions(ctx context.Context, frequency int) {
+	ticker := time.NewTicker(time.Duration(frequency) * time.Second)
+	defer ticker.Stop()
 	for {
-		time.Sleep(time.Duration(frequency) * time.Second)
-		logger.SysLog("syncing options from database")
-		loadOptionsFromDatabase()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			logger.SysLog("syncing options from database")
+			loadOptionsFromDatabase(ctx)
+		}
 	}
 }
 
-func AllOption() ([]*model.Option, error) {
+func AllOption(ctx context.Context) ([]*model.Option, error) {
 	var options []*model.Option
 	var err error
-	err = initialize.DB.Find(&options).Error
+	err = initialize.DB.WithContext(ctx).Find(&options).Error
 	return options, err
 }
 
-func loadOptionsFromDatabase() {
-	options, _ := AllOption()
+func loadOptionsFromDatabase(ctx context.Context) {
+	// read the latest revision first, so that it never claims more than what is loaded below
+	revision, err := getLatestOptionRevisionId(ctx)
+	if err != nil {
+		logger.SysError("failed to get latest option revision: " + err.Error())
+	}
+	options, _ := AllOption(ctx)
 	for _, option := range options {
 		// if option.Key == "ModelRatio" {
 		// 	option.Value = billingratio.AddNewMissingRatio(option.Value)
 		// }
-		err := updateOptionMap(option.Key, option.Value)
+		err = updateOptionMap(option.Key, option.Value)
 		if err != nil {
 			logger.SysError("failed to update option map: " + err.Error())
 		}
 	}
+	setLastAppliedOptionRevision(revision)
 }
 
-func UpdateOption(key string, value string) error {
+// UpdateOption saves the option and records a revision of the change made by operatorId.
+func UpdateOption(ctx context.Context, operatorId int, key string, value string) error {
+	return updateOption(ctx, operatorId, key, value, 0)
+}
+
+func updateOption(ctx context.Context, operatorId int, key string, value string, rollbackOf int) error {
+	revision := newOptionRevision(ctx, operatorId, key, value, rollbackOf)
 	// Save to database first
+	err := initialize.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) (err error) {
+		revision, err = saveOption(tx, key, value, revision)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	wakeWebhookQueue()
+	// Update OptionMap
+	err = updateOptionMap(key, value)
+	publishOptionChange(ctx, []string{key}, []*model.OptionRevision{revision})
+	notifyOptionChange(ctx, operatorId, []*model.OptionRevision{revision})
+	return err
+}
+
+// UpdateOptions saves all options in one transaction, so either every option is applied or none of them.
+// The caller should call ValidateOptions first.
+func UpdateOptions(ctx context.Context, operatorId int, options []*model.Option) error {
+	revisions := make([]*model.OptionRevision, len(options))
+	for i, option := range options {
+		revisions[i] = newOptionRevision(ctx, operatorId, option.Key, option.Value, 0)
+	}
+	err := initialize.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, option := range options {
+			revision, err := saveOption(tx, option.Key, option.Value, revisions[i])
+			if err != nil {
+				return err
+			}
+			revisions[i] = revision
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	wakeWebhookQueue()
+	keys := make([]string, 0, len(options))
+	for _, option := range options {
+		if e := updateOptionMap(option.Key, option.Value); e != nil && err == nil {
+			err = e
+		}
+		keys = append(keys, option.Key)
+	}
+	publishOptionChange(ctx, keys, revisions)
+	notifyOptionChange(ctx, operatorId, revisions)
+	return err
+}
+
+// notifyOptionChange 只通知值发生了变化的配置项，revision 为 nil 表示值未变化
+func notifyOptionChange(ctx context.Context, operatorId int, revisions []*model.OptionRevision) {
+	var keys []string
+	for _, revision := range revisions {
+		if revision != nil {
+			keys = append(keys, revision.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	Notify(ctx, model.NotificationEventAdminAction, "系统设置已修改",
+		fmt.Sprintf("用户 ID %d 修改了系统设置：%s", operatorId, strings.Join(keys, "、")))
+}
+
+// saveOption saves the option and records the revision, the old value is read in tx so that
+// the revision always holds the value that was actually replaced.
+// The returned revision is nil if the value is not changed.
+func saveOption(tx *gorm.DB, key string, value string, revision *model.OptionRevision) (*model.OptionRevision, error) {
 	option := model.Option{
 		Key: key,
 	}
-	// https://gorm.io/docs/update.html#Save-All-Fields
-	initialize.DB.FirstOrCreate(&option, model.Option{Key: key})
-	option.Value = value
-	// Save is a combination function.
-	// If save value does not contain primary key, it will execute Create,
-	// otherwise it will execute Update (with all fields).
-	initialize.DB.Save(&option)
-	// Update OptionMap
-	return updateOptionMap(key, value)
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where(&model.Option{Key: key}).Take(&option).Error
+	oldValue := option.Value
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 数据库中还没有的配置项当前使用的是默认值
+		oldValue = getOptionValue(key)
+		option.Value = value
+		err = tx.Create(&option).Error
+	} else if err == nil {
+		option.Value = value
+		err = tx.Save(&option).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	if oldValue == value {
+		return nil, nil
+	}
+	if !revision.Redacted {
+		revision.OldValue = oldValue
+	}
+	if err := tx.Create(revision).Error; err != nil {
+		return nil, err
+	}
+	// 密钥类配置项的值在 revision 中已被隐藏
+	return revision, emitWebhookEvent(tx, model.WebhookEventOptionUpdated, map[string]any{
+		"key":         revision.Key,
+		"old_value":   revision.OldValue,
+		"new_value":   revision.NewValue,
+		"redacted":    revision.Redacted,
+		"operator_id": revision.UserId,
+		"rollback_of": revision.RollbackOf,
+	})
+}
+
+func getOptionValue(key string) string {
+	global.OptionMapRWMutex.RLock()
+	defer global.OptionMapRWMutex.RUnlock()
+	return global.OptionMap[key]
+}
+
+// ValidateOptions checks all options before any of them is saved.
+// Options depending on each other are checked against the values after the whole update is applied.
+func ValidateOptions(options []*model.Option) error {
+	pending := make(map[string]string, len(options))
+	for _, option := range options {
+		if option.Key == "" {
+			return errors.New("配置项名称为空")
+		}
+		if _, ok := pending[option.Key]; ok {
+			return fmt.Errorf("配置项 %s 重复", option.Key)
+		}
+		if !isKnownOption(option.Key) {
+			return fmt.Errorf("未知的配置项 %s", option.Key)
+		}
+		pending[option.Key] = option.Value
+	}
+	lookup := func(key string) string {
+		if value, ok := pending[key]; ok {
+			return value
+		}
+		return getOptionValue(key)
+	}
+	for _, option := range options {
+		if err := validateOption(option.Key, option.Value, lookup); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isKnownOption reports whether the key is one of the options in OptionMap,
+// email templates are not in OptionMap until they are customized and are checked by validateEmailTemplate.
+func isKnownOption(key string) bool {
+	if strings.HasPrefix(key, "EmailTemplate.") {
+		return true
+	}
+	global.OptionMapRWMutex.RLock()
+	defer global.OptionMapRWMutex.RUnlock()
+	_, ok := global.OptionMap[key]
+	return ok
+}
+
+func validateOption(key string, value string, lookup func(key string) string) error {
+	if strings.HasSuffix(key, "Enabled") && value != "true" && value != "false" {
+		return fmt.Errorf("配置项 %s 的值必须为 true 或 false", key)
+	}
+	if strings.HasPrefix(key, "EmailTemplate.") {
+		return validateEmailTemplate(key, value)
+	}
+	switch key {
+	case "SMTPPort", "RetryTimes":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("配置项 %s 的值必须为整数", key)
+		}
+	case "QuotaForNewUser", "QuotaForInviter", "QuotaForInvitee", "QuotaRemindThreshold", "PreConsumedQuota":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("配置项 %s 的值必须为整数", key)
+		}
+	case "ChannelDisableThreshold", "QuotaPerUnit":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("配置项 %s 的值必须为数字", key)
+		}
+	case "RateLimitPolicies":
+		if _, err := ParseRateLimitPolicies(value); err != nil {
+			return err
+		}
+	case "NotificationChannels":
+		if _, err := ParseNotificationChannels(value); err != nil {
+			return err
+		}
+	case "Theme":
+		if !global.ValidThemes[value] {
+			return errors.New("无效的主题")
+		}
+	case "GitHubOAuthEnabled":
+		if value == "true" && lookup("GitHubClientId") == "" {
+			return errors.New("无法启用 GitHub OAuth，请先填入 GitHub Client Id 以及 GitHub Client Secret！")
+		}
+	case "EmailDomainRestrictionEnabled":
+		if value == "true" && lookup("EmailDomainWhitelist") == "" {
+			return errors.New("无法启用邮箱域名限制，请先填入限制的邮箱域名！")
+		}
+	case "WeChatAuthEnabled":
+		if value == "true" && lookup("WeChatServerAddress") == "" {
+			return errors.New("无法启用微信登录，请先填入微信登录相关配置信息！")
+		}
+	case "TurnstileCheckEnabled":
+		if value == "true" && lookup("TurnstileSiteKey") == "" {
+			return errors.New("无法启用 Turnstile 校验，请先填入 Turnstile 校验相关配置信息！")
+		}
+	}
+	return nil
+}
+
+// DiffOptions returns the options whose value differs from the current one, sorted by key.
+// Values of secret options are redacted.
+func DiffOptions(options map[string]string) []*model.OptionChange {
+	var changes []*model.OptionChange
+	global.OptionMapRWMutex.RLock()
+	for key, value := range options {
+		oldValue, exists := global.OptionMap[key]
+		if exists && oldValue == value {
+			continue
+		}
+		change := &model.OptionChange{
+			Key:      key,
+			OldValue: oldValue,
+			NewValue: value,
+			Created:  !exists,
+		}
+		if IsSecretOption(key) {
+			change.OldValue = redactedOptionValue
+			change.NewValue = redactedOptionValue
+		}
+		changes = append(changes, change)
+	}
+	global.OptionMapRWMutex.RUnlock()
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// ImportOptions validates and saves the options that differ from the current ones,
+// with dryRun only the diff is returned and nothing is saved.
+func ImportOptions(ctx context.Context, operatorId int, options map[string]string, dryRun bool) ([]*model.OptionChange, error) {
+	changes := DiffOptions(options)
+	updates := make([]*model.Option, 0, len(changes))
+	for _, change := range changes {
+		updates = append(updates, &model.Option{
+			Key:   change.Key,
+			Value: options[change.Key],
+		})
+	}
+	if err := ValidateOptions(updates); err != nil {
+		return nil, err
+	}
+	if dryRun || len(updates) == 0 {
+		return changes, nil
+	}
+	if err := UpdateOptions(ctx, operatorId, updates); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// ExportOptions returns a copy of the current options, secret options are only included when includeSecrets is true.
+func ExportOptions(includeSecrets bool) map[string]string {
+	global.OptionMapRWMutex.RLock()
+	defer global.OptionMapRWMutex.RUnlock()
+	options := make(map[string]string, len(global.OptionMap))
+	for key, value := range global.OptionMap {
+		if !includeSecrets && IsSecretOption(key) {
+			continue
+		}
+		options[key] = value
+	}
+	return options
 }
 
 func InitOptionMap() {
@@ -72,14 +353,15 @@ func InitOptionMap() {
 	global.OptionMap["LogConsumeEnabled"] = strconv.FormatBool(global.LogConsumeEnabled)
 	global.OptionMap["DisplayInCurrencyEnabled"] = strconv.FormatBool(global.DisplayInCurrencyEnabled)
 	global.OptionMap["DisplayTokenStatEnabled"] = strconv.FormatBool(global.DisplayTokenStatEnabled)
+	global.OptionMap["DebugEndpointsEnabled"] = strconv.FormatBool(global.DebugEndpointsEnabled)
 	global.OptionMap["ChannelDisableThreshold"] = strconv.FormatFloat(global.ChannelDisableThreshold, 'f', -1, 64)
 	global.OptionMap["EmailDomainRestrictionEnabled"] = strconv.FormatBool(global.EmailDomainRestrictionEnabled)
 	global.OptionMap["EmailDomainWhitelist"] = strings.Join(global.EmailDomainWhitelist, ",")
-	global.OptionMap["SMTPServer"] = ""
-	global.OptionMap["SMTPFrom"] = ""
+	global.OptionMap["SMTPServer"] = global.SMTPServer
+	global.OptionMap["SMTPFrom"] = global.SMTPFrom
 	global.OptionMap["SMTPPort"] = strconv.Itoa(global.SMTPPort)
-	global.OptionMap["SMTPAccount"] = ""
-	global.OptionMap["SMTPToken"] = ""
+	global.OptionMap["SMTPAccount"] = global.SMTPAccount
+	global.OptionMap["SMTPToken"] = global.SMTPToken
 	global.OptionMap["Notice"] = ""
 	global.OptionMap["About"] = ""
 	global.OptionMap["HomePageContent"] = ""
@@ -89,6 +371,14 @@ func InitOptionMap() {
 	global.OptionMap["ServerAddress"] = ""
 	global.OptionMap["GitHubClientId"] = ""
 	global.OptionMap["GitHubClientSecret"] = ""
+	global.OptionMap["LarkClientId"] = global.LarkClientId
+	global.OptionMap["LarkClientSecret"] = global.LarkClientSecret
+	global.OptionMap["OidcClientId"] = global.OidcClientId
+	global.OptionMap["OidcClientSecret"] = global.OidcClientSecret
+	global.OptionMap["OidcWellKnown"] = global.OidcWellKnown
+	global.OptionMap["OidcAuthorizationEndpoint"] = global.OidcAuthorizationEndpoint
+	global.OptionMap["OidcTokenEndpoint"] = global.OidcTokenEndpoint
+	global.OptionMap["OidcUserinfoEndpoint"] = global.OidcUserinfoEndpoint
 	global.OptionMap["WeChatServerAddress"] = ""
 	global.OptionMap["WeChatServerToken"] = ""
 	global.OptionMap["WeChatAccountQRCodeImageURL"] = ""
@@ -109,8 +399,10 @@ func InitOptionMap() {
 	global.OptionMap["QuotaPerUnit"] = strconv.FormatFloat(global.QuotaPerUnit, 'f', -1, 64)
 	global.OptionMap["RetryTimes"] = strconv.Itoa(global.RetryTimes)
 	global.OptionMap["Theme"] = global.Theme
+	global.OptionMap["RateLimitPolicies"] = "[]"
+	global.OptionMap["NotificationChannels"] = "[]"
 	global.OptionMapRWMutex.Unlock()
-	loadOptionsFromDatabase()
+	loadOptionsFromDatabase(context.Background())
 }
 
 func updateOptionMap(key string, value string) (err error) {
@@ -150,6 +442,8 @@ func updateOptionMap(key string, value string) (err error) {
 			global.DisplayInCurrencyEnabled = boolValue
 		case "DisplayTokenStatEnabled":
 			global.DisplayTokenStatEnabled = boolValue
+		case "DebugEndpointsEnabled":
+			global.DebugEndpointsEnabled = boolValue
 		}
 	}
 	switch key {
@@ -220,6 +514,10 @@ func updateOptionMap(key string, value string) (err error) {
 		global.PreConsumedQuota, _ = strconv.ParseInt(value, 10, 64)
 	case "RetryTimes":
 		global.RetryTimes, _ = strconv.Atoi(value)
+	case "RateLimitPolicies":
+		err = UpdateRateLimitPoliciesByJSONString(value)
+	case "NotificationChannels":
+		err = UpdateNotificationChannelsByJSONString(value)
 	// case "ModelRatio":
 	// 	err = billingratio.UpdateModelRatioByJSONString(value)
 	// case "GroupRatio":
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils"
+)
+
+const optionChangeChannel = "option_changes"
+
+// nodeId identifies this process, so that it can ignore the events published by itself
+var nodeId = utils.GetUUID()
+
+// lastAppliedOptionRevision is the id of the latest option revision applied to OptionMap on this node
+var lastAppliedOptionRevision atomic.Int64
+
+type optionChangeEvent struct {
+	Node     string   `json:"node"`
+	Revision int      `json:"revision"`
+	Keys     []string `json:"keys"`
+}
+
+func GetLastAppliedOptionRevision() int64 {
+	return lastAppliedOptionRevision.Load()
+}
+
+func setLastAppliedOptionRevision(revision int64) {
+	for {
+		current := lastAppliedOptionRevision.Load()
+		if revision <= current || lastAppliedOptionRevision.CompareAndSwap(current, revision) {
+			return
+		}
+	}
+}
+
+func getLatestOptionRevisionId(ctx context.Context) (id int64, err error) {
+	err = initialize.DB.WithContext(ctx).Model(model.NewOptionRevision()).Select("COALESCE(MAX(id), 0)").Scan(&id).Error
+	return id, err
+}
+
+// publishOptionChange notifies the other nodes that the given options have been changed.
+// Only the keys are published, the values are loaded from the database by the subscribers.
+func publishOptionChange(ctx context.Context, keys []string, revisions []*model.OptionRevision) {
+	revision := 0
+	for _, r := range revisions {
+		if r != nil && r.Id > revision {
+			revision = r.Id
+		}
+	}
+	if revision == 0 {
+		return
+	}
+	setLastAppliedOptionRevision(int64(revision))
+	if !initialize.RedisEnabled {
+		return
+	}
+	data, err := json.Marshal(optionChangeEvent{
+		Node:     nodeId,
+		Revision: revision,
+		Keys:     keys,
+	})
+	if err != nil {
+		logger.SysError("failed to marshal option change event: " + err.Error())
+		return
+	}
+	if err = initialize.RedisPublish(ctx, optionChangeChannel, string(data)); err != nil {
+		logger.SysError("failed to publish option change event: " + err.Error())
+	}
+}
+
+// SubscribeOptionChanges applies the option changes published by other nodes until ctx is done.
+// SyncOptions should still be running as a fallback in case some events are lost.
+func SubscribeOptionChanges(ctx context.Context) {
+	pubsub := initialize.RedisSubscribe(ctx, optionChangeChannel)
+	defer pubsub.Close()
+	logger.SysLog("subscribed to option changes")
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var event optionChangeEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				logger.SysError("failed to unmarshal option change event: " + err.Error())
+				continue
+			}
+			if event.Node == nodeId || len(event.Keys) == 0 {
+				continue
+			}
+			applyOptionChange(ctx, &event)
+		}
+	}
+}
+
+func applyOptionChange(ctx context.Context, event *optionChangeEvent) {
+	var options []*model.Option
+	err := initialize.DB.WithContext(ctx).Where(map[string]interface{}{"key": event.Keys}).Find(&options).Error
+	if err != nil {
+		logger.SysError("failed to load changed options: " + err.Error())
+		return
+	}
+	for _, option := range options {
+		if err = updateOptionMap(option.Key, option.Value); err != nil {
+			logger.SysError("failed to update option map: " + err.Error())
+		}
+	}
+	setLastAppliedOptionRevision(int64(event.Revision))
+	logger.SysLogf("applied option changes of revision %d: %v", event.Revision, event.Keys)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils"
+)
+
+const redactedOptionValue = "******"
+
+// secretOptions are the options that hold credentials, a new credential option must be added here.
+var secretOptions = map[string]bool{
+	"SMTPToken":            true,
+	"GitHubClientSecret":   true,
+	"LarkClientSecret":     true,
+	"OidcClientSecret":     true,
+	"WeChatServerToken":    true,
+	"MessagePusherToken":   true,
+	"TurnstileSecretKey":   true,
+	"NotificationChannels": true, // webhook addresses and signing secrets
+}
+
+// IsSecretOption reports whether the option holds a credential,
+// such options are never returned by the API and are redacted in revisions.
+func IsSecretOption(key string) bool {
+	return secretOptions[key]
+}
+
+// newOptionRevision returns the revision of setting the option to newValue,
+// the old value is filled in by saveOption.
+func newOptionRevision(ctx context.Context, operatorId int, key string, newValue string, rollbackOf int) *model.OptionRevision {
+	revision := &model.OptionRevision{
+		Key:        key,
+		NewValue:   newValue,
+		UserId:     operatorId,
+		RequestId:  utils.GetRequestID(ctx),
+		RollbackOf: rollbackOf,
+		CreatedAt:  utils.GetTimestamp(),
+	}
+	if operatorId != 0 {
+		revision.Username = GetUsernameById(ctx, operatorId)
+	}
+	if IsSecretOption(key) {
+		revision.Redacted = true
+		revision.OldValue = redactedOptionValue
+		revision.NewValue = redactedOptionValue
+	}
+	return revision
+}
+
+func GetOptionRevisions(ctx context.Context, key string, startIdx int, num int) (revisions []*model.OptionRevision, err error) {
+	query := initialize.DB.WithContext(ctx).Order("id desc").Limit(num).Offset(startIdx)
+	if key != "" {
+		query = query.Where(&model.OptionRevision{Key: key})
+	}
+	err = query.Find(&revisions).Error
+	return revisions, err
+}
+
+func GetOptionRevisionById(ctx context.Context, id int) (*model.OptionRevision, error) {
+	if id == 0 {
+		return nil, errors.New("id 为空！")
+	}
+	revision := model.NewOptionRevisionById(id)
+	err := initialize.DB.WithContext(ctx).First(revision, "id = ?", id).Error
+	return revision, err
+}
+
+// RollbackOption restores the value the option had before the given revision was applied.
+// The rollback itself is recorded as a new revision.
+func RollbackOption(ctx context.Context, operatorId int, revision *model.OptionRevision) error {
+	if revision.Redacted {
+		return errors.New("该配置项包含密钥，无法回滚")
+	}
+	return updateOption(ctx, operatorId, revision.Key, revision.OldValue, revision.Id)
+}
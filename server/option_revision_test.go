@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+)
+
+func TestSecretOptions(t *testing.T) {
+	Convey("TestSecretOptions", t, func() {
+		setupTestDB(t, nil)
+		setupTestOptions(t)
+
+		// 所有看起来是凭据的系统设置都必须在 secretOptions 中
+		keys := []string{"LarkClientSecret", "OidcClientSecret"}
+		for key := range ExportOptions(true) {
+			keys = append(keys, key)
+		}
+		for _, key := range keys {
+			credential := key == "NotificationChannels" || strings.Contains(key, "Secret") ||
+				strings.Contains(key, "Token") || strings.Contains(key, "Password")
+			if strings.HasSuffix(key, "Enabled") || strings.HasSuffix(key, "Endpoint") {
+				credential = false
+			}
+			So(IsSecretOption(key), ShouldEqual, credential)
+		}
+		So(IsSecretOption("TurnstileSiteKey"), ShouldBeFalse)
+
+		// 凭据不会出现在导出内容与修改历史中
+		So(UpdateOption(context.Background(), 1, "TurnstileSecretKey", "0x4AAA-secret"), ShouldBeNil)
+		So(ExportOptions(false), ShouldNotContainKey, "TurnstileSecretKey")
+		revisions, err := GetOptionRevisions(context.Background(), "TurnstileSecretKey", 0, 10)
+		So(err, ShouldBeNil)
+		So(revisions, ShouldHaveLength, 1)
+		So(revisions[0].Redacted, ShouldBeTrue)
+		So(revisions[0].NewValue, ShouldEqual, redactedOptionValue)
+	})
+}
+
+func TestRollbackOption(t *testing.T) {
+	Convey("TestRollbackOption", t, func() {
+		setupTestDB(t, nil)
+		setupTestOptions(t)
+		ctx := context.Background()
+
+		So(UpdateOption(ctx, 1, "Notice", "first"), ShouldBeNil)
+		So(UpdateOption(ctx, 1, "Notice", "second"), ShouldBeNil)
+		// 值未变化时不记录
+		So(UpdateOption(ctx, 1, "Notice", "second"), ShouldBeNil)
+		So(UpdateOption(ctx, 1, "About", "about"), ShouldBeNil)
+
+		revisions, err := GetOptionRevisions(ctx, "Notice", 0, 10)
+		So(err, ShouldBeNil)
+		So(revisions, ShouldHaveLength, 2)
+		So(revisions[0].OldValue, ShouldEqual, "first")
+		So(revisions[0].NewValue, ShouldEqual, "second")
+		So(revisions[1].OldValue, ShouldEqual, "")
+		So(revisions[1].NewValue, ShouldEqual, "first")
+		revisions, err = GetOptionRevisions(ctx, "", 0, 10)
+		So(err, ShouldBeNil)
+		So(revisions, ShouldHaveLength, 3)
+		revisions, err = GetOptionRevisions(ctx, "", 1, 1)
+		So(err, ShouldBeNil)
+		So(revisions, ShouldHaveLength, 1)
+		So(revisions[0].Key, ShouldEqual, "Notice")
+
+		// 旧值从数据库中读取，不受其他节点尚未同步的 OptionMap 影响
+		So(initialize.DB.Model(&model.Option{}).Where(&model.Option{Key: "Notice"}).Update("value", "third").Error, ShouldBeNil)
+		So(getOptionValue("Notice"), ShouldEqual, "second")
+		So(UpdateOption(ctx, 1, "Notice", "fourth"), ShouldBeNil)
+		revisions, err = GetOptionRevisions(ctx, "Notice", 0, 1)
+		So(err, ShouldBeNil)
+		So(revisions[0].OldValue, ShouldEqual, "third")
+
+		// 回滚恢复修改前的值，并记录为新的修改
+		revision, err := GetOptionRevisionById(ctx, revisions[0].Id)
+		So(err, ShouldBeNil)
+		So(RollbackOption(ctx, 2, revision), ShouldBeNil)
+		So(getOptionValue("Notice"), ShouldEqual, "third")
+		revisions, err = GetOptionRevisions(ctx, "Notice", 0, 1)
+		So(err, ShouldBeNil)
+		So(revisions[0].OldValue, ShouldEqual, "fourth")
+		So(revisions[0].NewValue, ShouldEqual, "third")
+		So(revisions[0].RollbackOf, ShouldEqual, revision.Id)
+		So(revisions[0].UserId, ShouldEqual, 2)
+
+		// 密钥类配置项无法回滚
+		So(UpdateOption(ctx, 1, "SMTPToken", "secret"), ShouldBeNil)
+		revisions, err = GetOptionRevisions(ctx, "SMTPToken", 0, 1)
+		So(err, ShouldBeNil)
+		So(RollbackOption(ctx, 1, revisions[0]), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/9688101/hx-admin/model"
+)
+
+func TestImportOptions(t *testing.T) {
+	Convey("TestImportOptions", t, func() {
+		setupTestDB(t, nil)
+		setupTestOptions(t)
+		ctx := context.Background()
+
+		// 未知的配置项与无效的值都会使整批配置被拒绝
+		So(ValidateOptions([]*model.Option{{Key: "Notice", Value: "hello"}, {Key: "NoSuchOption", Value: "1"}}), ShouldNotBeNil)
+		So(ValidateOptions([]*model.Option{{Key: "LarkClientId", Value: "cli_a"}, {Key: "OidcWellKnown", Value: "https://example.com"}}), ShouldBeNil)
+		So(ValidateOptions([]*model.Option{{Key: "EmailTemplate.verification.en", Value: ""}}), ShouldBeNil)
+
+		_, err := ImportOptions(ctx, 1, map[string]string{"Notice": "hello", "NoSuchOption": "1"}, false)
+		So(err, ShouldNotBeNil)
+		_, err = ImportOptions(ctx, 1, map[string]string{"About": "about", "RetryTimes": "many"}, false)
+		So(err, ShouldNotBeNil)
+		So(getOptionValue("Notice"), ShouldEqual, "")
+		So(getOptionValue("About"), ShouldEqual, "")
+		options, err := AllOption(ctx)
+		So(err, ShouldBeNil)
+		So(options, ShouldBeEmpty)
+
+		// dry run 只返回差异
+		changes, err := ImportOptions(ctx, 1, map[string]string{"Notice": "hello", "About": ""}, true)
+		So(err, ShouldBeNil)
+		So(changes, ShouldHaveLength, 1)
+		So(changes[0].Key, ShouldEqual, "Notice")
+		So(changes[0].NewValue, ShouldEqual, "hello")
+		So(getOptionValue("Notice"), ShouldEqual, "")
+		options, err = AllOption(ctx)
+		So(err, ShouldBeNil)
+		So(options, ShouldBeEmpty)
+
+		changes, err = ImportOptions(ctx, 1, map[string]string{"Notice": "hello", "About": "about"}, false)
+		So(err, ShouldBeNil)
+		So(changes, ShouldHaveLength, 2)
+		So(getOptionValue("Notice"), ShouldEqual, "hello")
+		So(getOptionValue("About"), ShouldEqual, "about")
+		options, err = AllOption(ctx)
+		So(err, ShouldBeNil)
+		So(options, ShouldHaveLength, 2)
+
+		// 再次导入相同的内容没有差异
+		changes, err = ImportOptions(ctx, 1, map[string]string{"Notice": "hello"}, false)
+		So(err, ShouldBeNil)
+		So(changes, ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/9688101/hx-admin/model"
+)
+
+var rateLimitPolicies atomic.Pointer[[]*model.RateLimitPolicy]
+
+// ParseRateLimitPolicies 解析并校验 RateLimitPolicies 配置项的值
+func ParseRateLimitPolicies(value string) ([]*model.RateLimitPolicy, error) {
+	var policies []*model.RateLimitPolicy
+	if strings.TrimSpace(value) == "" {
+		return policies, nil
+	}
+	if err := json.Unmarshal([]byte(value), &policies); err != nil {
+		return nil, fmt.Errorf("限流策略不是有效的 JSON：%w", err)
+	}
+	for i, policy := range policies {
+		if policy == nil || !strings.HasPrefix(policy.Path, "/") {
+			return nil, fmt.Errorf("第 %d 条限流策略的路径必须以 / 开头", i+1)
+		}
+		policy.Method = strings.ToUpper(policy.Method)
+		if policy.Method == "" {
+			policy.Method = "*"
+		}
+		switch policy.Key {
+		case "":
+			policy.Key = model.RateLimitKeyIP
+		case model.RateLimitKeyIP, model.RateLimitKeyUser:
+		default:
+			// 没有按令牌鉴权的路由，按令牌限流不会生效
+			return nil, fmt.Errorf("第 %d 条限流策略的 key 必须为 ip 或 user", i+1)
+		}
+		if policy.Num <= 0 || policy.Duration <= 0 {
+			return nil, fmt.Errorf("第 %d 条限流策略的 num 与 duration 必须大于 0", i+1)
+		}
+	}
+	return policies, nil
+}
+
+// UpdateRateLimitPoliciesByJSONString 替换当前生效的限流策略
+func UpdateRateLimitPoliciesByJSONString(value string) error {
+	policies, err := ParseRateLimitPolicies(value)
+	if err != nil {
+		return err
+	}
+	rateLimitPolicies.Store(&policies)
+	return nil
+}
+
+// GetRateLimitPolicies 返回匹配该路由的所有限流策略，path 为 gin 的路由路径
+func GetRateLimitPolicies(method string, path string) []*model.RateLimitPolicy {
+	policies := rateLimitPolicies.Load()
+	if policies == nil {
+		return nil
+	}
+	var matched []*model.RateLimitPolicy
+	for _, policy := range *policies {
+		if policy.Path == path && (policy.Method == "*" || policy.Method == method) {
+			matched = append(matched, policy)
+		}
+	}
+	return matched
+}
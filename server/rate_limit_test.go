@@ -0,0 +1,28 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/9688101/hx-admin/model"
+)
+
+func TestParseRateLimitPolicies(t *testing.T) {
+	Convey("TestParseRateLimitPolicies", t, func() {
+		policies, err := ParseRateLimitPolicies(`[{"path":"/api/user/login","num":5,"duration":60},{"method":"get","path":"/api/user/self","key":"user","num":10,"duration":60}]`)
+		So(err, ShouldBeNil)
+		So(policies, ShouldHaveLength, 2)
+		So(policies[0].Method, ShouldEqual, "*")
+		So(policies[0].Key, ShouldEqual, model.RateLimitKeyIP)
+		So(policies[1].Method, ShouldEqual, "GET")
+
+		// 没有按令牌鉴权的路由，按令牌限流的策略会被拒绝而不是静默失效
+		_, err = ParseRateLimitPolicies(`[{"path":"/api/user/self","key":"token","num":10,"duration":60}]`)
+		So(err, ShouldNotBeNil)
+		_, err = ParseRateLimitPolicies(`[{"path":"api/user/self","num":10,"duration":60}]`)
+		So(err, ShouldNotBeNil)
+		_, err = ParseRateLimitPolicies(`[{"path":"/api/user/self","num":0,"duration":60}]`)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"github.com/9688101/hx-admin/config"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/initialize"
+)
+
+// setupTestDB 使用迁移到最新版本的临时 SQLite 数据库与给定的配置，并关闭 Redis，测试结束时恢复原来的值
+func setupTestDB(t *testing.T, cfg *config.Config) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = initialize.MigrateUp(db, initialize.Migrations, 0); err != nil {
+		t.Fatal(err)
+	}
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	oldDB, oldConfig, oldRedisEnabled := initialize.DB, global.GetConfig(), initialize.RedisEnabled
+	t.Cleanup(func() {
+		initialize.DB, initialize.RedisEnabled = oldDB, oldRedisEnabled
+		global.SetConfig(oldConfig)
+	})
+	initialize.DB, initialize.RedisEnabled = db, false
+	global.SetConfig(cfg)
+}
+
+// setupTestOptions 从 setupTestDB 的数据库初始化 OptionMap，测试结束时恢复原来的 OptionMap
+func setupTestOptions(t *testing.T) {
+	global.OptionMapRWMutex.RLock()
+	oldOptionMap := global.OptionMap
+	global.OptionMapRWMutex.RUnlock()
+	t.Cleanup(func() {
+		global.OptionMapRWMutex.Lock()
+		global.OptionMap = oldOptionMap
+		global.OptionMapRWMutex.Unlock()
+	})
+	InitOptionMap()
+}
+
+// setupTestRedis 使用 REDIS_CONN_STRING 指定的 Redis 并启用 Redis，未设置或无法连接时跳过测试
+func setupTestRedis(t *testing.T) {
+	connString := os.Getenv("REDIS_CONN_STRING")
+	if connString == "" {
+		t.Skip("REDIS_CONN_STRING not set")
+	}
+	opt, err := redis.ParseURL(connString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rdb := redis.NewClient(opt)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err = rdb.Ping(ctx).Err(); err != nil {
+		_ = rdb.Close()
+		t.Skip("redis is not available: " + err.Error())
+	}
+	oldRDB, oldRedisEnabled := initialize.RDB, initialize.RedisEnabled
+	t.Cleanup(func() {
+		initialize.RDB, initialize.RedisEnabled = oldRDB, oldRedisEnabled
+		_ = rdb.Close()
+	})
+	initialize.RDB, initialize.RedisEnabled = rdb, true
+}
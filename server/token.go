@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"errors"
 
 	"github.com/9688101/hx-admin/initialize"
@@ -14,47 +15,47 @@ const (
 	TokenStatusExhausted = 4
 )
 
-func GetTokenByIds(id int, userId int) (*model.Token, error) {
+func GetTokenByIds(ctx context.Context, id int, userId int) (*model.Token, error) {
 	if id == 0 || userId == 0 {
 		return nil, errors.New("id 或 userId 为空！")
 	}
 	t := model.NewTokenByUserId(id, userId)
 	var err error = nil
-	err = initialize.DB.First(t, "id = ? and user_id = ?", id, userId).Error
+	err = initialize.DB.WithContext(ctx).First(t, "id = ? and user_id = ?", id, userId).Error
 	return t, err
 }
 
-func GetTokenById(id int) (*model.Token, error) {
+func GetTokenById(ctx context.Context, id int) (*model.Token, error) {
 	if id == 0 {
 		return nil, errors.New("id 为空！")
 	}
 	t := model.NewTokenById(id)
 	var err error = nil
-	err = initialize.DB.First(&t, "id = ?", id).Error
+	err = initialize.DB.WithContext(ctx).First(&t, "id = ?", id).Error
 	return t, err
 }
 
-func InsertToken(t *model.Token) error {
+func InsertToken(ctx context.Context, t *model.Token) error {
 	var err error
-	err = initialize.DB.Create(t).Error
+	err = initialize.DB.WithContext(ctx).Create(t).Error
 	return err
 }
 
 // Update Make sure your token's fields is completed, because this will update non-zero values
-func UpdateToken(t *model.Token) error {
+func UpdateToken(ctx context.Context, t *model.Token) error {
 	var err error
-	err = initialize.DB.Model(t).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "models", "subnet").Updates(t).Error
+	err = initialize.DB.WithContext(ctx).Model(t).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota", "models", "subnet").Updates(t).Error
 	return err
 }
 
-func SelectUpdateToken(t *model.Token) error {
+func SelectUpdateToken(ctx context.Context, t *model.Token) error {
 	// This can update zero values
-	return initialize.DB.Model(t).Select("accessed_time", "status").Updates(t).Error
+	return initialize.DB.WithContext(ctx).Model(t).Select("accessed_time", "status").Updates(t).Error
 }
 
-func DeleteToken(t *model.Token) error {
+func DeleteToken(ctx context.Context, t *model.Token) error {
 	var err error
-	err = initialize.DB.Delete(t).Error
+	err = initialize.DB.WithContext(ctx).Delete(t).Error
 	return err
 }
 
@@ -68,15 +69,24 @@ func DeleteToken(t *model.Token) error {
 // 	return *t.Models
 // }
 
-func DeleteTokenById(id int, userId int) (err error) {
+func DeleteTokenById(ctx context.Context, id int, userId int) (err error) {
 	// Why we need userId here? In case user want to delete other's token.
 	if id == 0 || userId == 0 {
 		return errors.New("id 或 userId 为空！")
 	}
 	token := model.Token{Id: id, UserId: userId}
-	err = initialize.DB.Where(token).First(&token).Error
+	err = initialize.DB.WithContext(ctx).Where(token).First(&token).Error
 	if err != nil {
 		return err
 	}
-	return DeleteToken(&token)
+	return DeleteToken(ctx, &token)
+}
+
+// DeleteTokensByUserId 删除用户的所有令牌，返回删除的数量
+func DeleteTokensByUserId(ctx context.Context, userId int) (int64, error) {
+	if userId == 0 {
+		return 0, errors.New("userId 为空！")
+	}
+	result := initialize.DB.WithContext(ctx).Where("user_id = ?", userId).Delete(model.NewToken())
+	return result.RowsAffected, result.Error
 }
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"strings"
 
+	"gorm.io/gorm"
+
 	"github.com/9688101/hx-admin/core/logger"
 	"github.com/9688101/hx-admin/initialize"
 	"github.com/9688101/hx-admin/model"
@@ -25,14 +27,14 @@ const (
 	UserStatusDeleted  = 3
 )
 
-func GetMaxUserId() int {
+func GetMaxUserId(ctx context.Context) int {
 	u := model.NewUser()
-	initialize.DB.Last(u)
+	initialize.DB.WithContext(ctx).Last(u)
 	return u.Id
 }
 
-func GetAllUsers(startIdx int, num int, order string) (users []model.User, err error) {
-	query := initialize.DB.Limit(num).Offset(startIdx).Omit("password").Where("status != ?", UserStatusDeleted)
+func GetAllUsers(ctx context.Context, startIdx int, num int, order string) (users []model.User, err error) {
+	query := initialize.DB.WithContext(ctx).Limit(num).Offset(startIdx).Omit("password").Where("status != ?", UserStatusDeleted)
 
 	switch order {
 	// case "quota":
@@ -49,47 +51,47 @@ func GetAllUsers(startIdx int, num int, order string) (users []model.User, err e
 	return users, err
 }
 
-func SearchUsers(keyword string) (users []model.User, err error) {
+func SearchUsers(ctx context.Context, keyword string) (users []model.User, err error) {
 	if !initialize.UsingPostgreSQL {
-		err = initialize.DB.Omit("password").Where("id = ? or username LIKE ? or email LIKE ? or display_name LIKE ?", keyword, keyword+"%", keyword+"%", keyword+"%").Find(&users).Error
+		err = initialize.DB.WithContext(ctx).Omit("password").Where("id = ? or username LIKE ? or email LIKE ? or display_name LIKE ?", keyword, keyword+"%", keyword+"%", keyword+"%").Find(&users).Error
 	} else {
-		err = initialize.DB.Omit("password").Where("username LIKE ? or email LIKE ? or display_name LIKE ?", keyword+"%", keyword+"%", keyword+"%").Find(&users).Error
+		err = initialize.DB.WithContext(ctx).Omit("password").Where("username LIKE ? or email LIKE ? or display_name LIKE ?", keyword+"%", keyword+"%", keyword+"%").Find(&users).Error
 	}
 	return users, err
 }
 
-func GetUserById(id int, selectAll bool) (*model.User, error) {
+func GetUserById(ctx context.Context, id int, selectAll bool) (*model.User, error) {
 	if id == 0 {
 		return nil, errors.New("id 为空！")
 	}
 	u := model.NewUserById(id)
 	var err error = nil
 	if selectAll {
-		err = initialize.DB.First(u, "id = ?", id).Error
+		err = initialize.DB.WithContext(ctx).First(u, "id = ?", id).Error
 	} else {
-		err = initialize.DB.Omit("password", "access_token").First(u, "id = ?", id).Error
+		err = initialize.DB.WithContext(ctx).Omit("password", "access_token").First(u, "id = ?", id).Error
 	}
 	return u, err
 }
 
-func GetUserIdByAffCode(affCode string) (int, error) {
+func GetUserIdByAffCode(ctx context.Context, affCode string) (int, error) {
 	if affCode == "" {
 		return 0, errors.New("affCode 为空！")
 	}
 	u := model.NewUser()
-	err := initialize.DB.Select("id").First(u, "aff_code = ?", affCode).Error
+	err := initialize.DB.WithContext(ctx).Select("id").First(u, "aff_code = ?", affCode).Error
 	return u.Id, err
 }
 
-func DeleteUserById(id int) (err error) {
+func DeleteUserById(ctx context.Context, id int) (err error) {
 	if id == 0 {
 		return errors.New("id 为空！")
 	}
 	u := model.NewUserById(id)
-	return DeleteUser(u)
+	return DeleteUser(ctx, u)
 }
 
-func UpdateUser(u *model.User, updatePassword bool) error {
+func UpdateUser(ctx context.Context, u *model.User, updatePassword bool) error {
 	var err error
 	if updatePassword {
 		u.Password, err = utils.Password2Hash(u.Password)
@@ -97,24 +99,84 @@ func UpdateUser(u *model.User, updatePassword bool) error {
 			return err
 		}
 	}
-	if u.Status == UserStatusDisabled {
-		utils.BanUser(u.Id)
-	} else if u.Status == UserStatusEnabled {
-		utils.UnbanUser(u.Id)
+	old := model.NewUser()
+	err = initialize.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id", "username", "role", "status").First(old, "id = ?", u.Id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(u).Updates(u).Error; err != nil {
+			return err
+		}
+		return emitUserChangeEvents(tx, old, u)
+	})
+	if err != nil {
+		return err
 	}
-	err = initialize.DB.Model(u).Updates(u).Error
-	return err
+	wakeWebhookQueue()
+	// 会话中保存了用户的状态与角色，变化后需要重新登录
+	if u.Status == UserStatusDisabled && old.Status != UserStatusDisabled {
+		ForceLogout(ctx, u.Id, "账户已被禁用")
+	} else if u.Role != 0 && u.Role != old.Role {
+		ForceLogout(ctx, u.Id, "账户角色已变更，请重新登录")
+	}
+	if u.Status != 0 {
+		if err = syncUserBan(ctx, u.Id); err != nil {
+			logger.SysError("failed to sync user ban: " + err.Error())
+		}
+	}
+	return nil
 }
 
-func DeleteUser(user *model.User) error {
+// emitUserChangeEvents 根据修改前后的状态与角色产生 webhook 事件，u 中为 0 的字段没有被修改
+func emitUserChangeEvents(tx *gorm.DB, old *model.User, u *model.User) error {
+	if u.Status != 0 && u.Status != old.Status {
+		switch u.Status {
+		case UserStatusEnabled:
+			if err := emitWebhookEvent(tx, model.WebhookEventUserEnabled, map[string]any{"id": old.Id, "username": old.Username}); err != nil {
+				return err
+			}
+		case UserStatusDisabled:
+			if err := emitWebhookEvent(tx, model.WebhookEventUserDisabled, map[string]any{"id": old.Id, "username": old.Username}); err != nil {
+				return err
+			}
+		}
+	}
+	if u.Role != 0 && u.Role != old.Role {
+		return emitWebhookEvent(tx, model.WebhookEventUserRoleChanged, map[string]any{
+			"id":       old.Id,
+			"username": old.Username,
+			"old_role": old.Role,
+			"new_role": u.Role,
+		})
+	}
+	return nil
+}
+
+func DeleteUser(ctx context.Context, user *model.User) error {
 	if user.Id == 0 {
 		return errors.New("id 为空！")
 	}
-	utils.BanUser(user.Id)
-	user.Username = fmt.Sprintf("deleted_%s", utils.GetUUID())
-	user.Status = UserStatusDeleted
-	err := initialize.DB.Model(user).Updates(user).Error
-	return err
+	err := initialize.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		old := model.NewUser()
+		if err := tx.Select("id", "username").First(old, "id = ?", user.Id).Error; err != nil {
+			return err
+		}
+		user.Username = fmt.Sprintf("deleted_%s", utils.GetUUID())
+		user.Status = UserStatusDeleted
+		if err := tx.Model(user).Updates(user).Error; err != nil {
+			return err
+		}
+		return emitWebhookEvent(tx, model.WebhookEventUserDeleted, map[string]any{"id": old.Id, "username": old.Username})
+	})
+	if err != nil {
+		return err
+	}
+	wakeWebhookQueue()
+	ForceLogout(ctx, user.Id, "账户已被删除")
+	if err = syncUserBan(ctx, user.Id); err != nil {
+		logger.SysError("failed to sync user ban: " + err.Error())
+	}
+	return nil
 }
 func InsertUser(ctx context.Context, user *model.User, inviterId int) error {
 	var err error
@@ -127,10 +189,16 @@ func InsertUser(ctx context.Context, user *model.User, inviterId int) error {
 	// user.Quota = config.QuotaForNewUser
 	user.AccessToken = utils.GetUUID()
 	user.AffCode = utils.GetRandomString(4)
-	result := initialize.DB.Create(user)
-	if result.Error != nil {
-		return result.Error
+	err = initialize.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return emitWebhookEvent(tx, model.WebhookEventUserRegistered, webhookUserData(user))
+	})
+	if err != nil {
+		return err
 	}
+	wakeWebhookQueue()
 	// if config.QuotaForNewUser > 0 {
 	// 	RecordLog(ctx, user.Id, LogTypeSystem, fmt.Sprintf("新用户注册赠送 %s", common.LogQuota(config.QuotaForNewUser)))
 	// }
@@ -155,16 +223,18 @@ func InsertUser(ctx context.Context, user *model.User, inviterId int) error {
 		// RemainQuota:    -1,
 		// UnlimitedQuota: true,
 	}
-	result.Error = InsertToken(&cleanToken)
-	if result.Error != nil {
+	err = InsertToken(ctx, &cleanToken)
+	if err != nil {
 		// do not block
-		logger.SysError(fmt.Sprintf("create default token for user %d failed: %s", user.Id, result.Error.Error()))
+		logger.SysError(fmt.Sprintf("create default token for user %d failed: %s", user.Id, err.Error()))
 	}
+	Notify(ctx, model.NotificationEventUserRegister, "新用户注册",
+		fmt.Sprintf("用户 %s（ID %d）已注册，邮箱：%s", user.Username, user.Id, user.Email))
 	return nil
 }
 
 // ValidateAndFill check password & user status
-func ValidateAndFill(user *model.User) (err error) {
+func ValidateAndFill(ctx context.Context, user *model.User) (err error) {
 	// When querying with struct, GORM will only query with non-zero fields,
 	// that means if your field’s value is 0, '', false or other zero values,
 	// it won’t be used to build query conditions
@@ -172,11 +242,11 @@ func ValidateAndFill(user *model.User) (err error) {
 	if user.Username == "" || password == "" {
 		return errors.New("用户名或密码为空")
 	}
-	err = initialize.DB.Where("username = ?", user.Username).First(user).Error
+	err = initialize.DB.WithContext(ctx).Where("username = ?", user.Username).First(user).Error
 	if err != nil {
 		// we must make sure check username firstly
 		// consider this case: a malicious user set his username as other's email
-		err := initialize.DB.Where("email = ?", user.Username).First(user).Error
+		err := initialize.DB.WithContext(ctx).Where("email = ?", user.Username).First(user).Error
 		if err != nil {
 			return errors.New("用户名或密码错误，或用户已被封禁")
 		}
@@ -188,87 +258,87 @@ func ValidateAndFill(user *model.User) (err error) {
 	return nil
 }
 
-func FillUserById(u *model.User) error {
+func FillUserById(ctx context.Context, u *model.User) error {
 	if u.Id == 0 {
 		return errors.New("id 为空！")
 	}
-	initialize.DB.Where(model.NewUserById(u.Id)).First(u)
+	initialize.DB.WithContext(ctx).Where(model.NewUserById(u.Id)).First(u)
 	return nil
 }
 
-func FillUserByEmail(u *model.User) error {
+func FillUserByEmail(ctx context.Context, u *model.User) error {
 	if u.Email == "" {
 		return errors.New("email 为空！")
 	}
-	initialize.DB.Where(model.NewUserByEmail(u.Email)).First(u)
+	initialize.DB.WithContext(ctx).Where(model.NewUserByEmail(u.Email)).First(u)
 	return nil
 }
 
-func FillUserByGitHubId(u *model.User) error {
+func FillUserByGitHubId(ctx context.Context, u *model.User) error {
 	if u.GitHubId == "" {
 		return errors.New("GitHub id 为空！")
 	}
-	initialize.DB.Where(model.NewUserByGitHubId(u.GitHubId)).First(u)
+	initialize.DB.WithContext(ctx).Where(model.NewUserByGitHubId(u.GitHubId)).First(u)
 	return nil
 }
 
-func FillUserByLarkId(u *model.User) error {
+func FillUserByLarkId(ctx context.Context, u *model.User) error {
 	if u.LarkId == "" {
 		return errors.New("lark id 为空！")
 	}
-	initialize.DB.Where(model.NewUserByLarkId(u.LarkId)).First(u)
+	initialize.DB.WithContext(ctx).Where(model.NewUserByLarkId(u.LarkId)).First(u)
 	return nil
 }
 
-func FillUserByOidcId(u *model.User) error {
+func FillUserByOidcId(ctx context.Context, u *model.User) error {
 	if u.OidcId == "" {
 		return errors.New("oidc id 为空！")
 	}
-	initialize.DB.Where(model.NewUserByOidcId(u.OidcId))
+	initialize.DB.WithContext(ctx).Where(model.NewUserByOidcId(u.OidcId))
 	return nil
 }
 
-func FillUserByWeChatId(u *model.User) error {
+func FillUserByWeChatId(ctx context.Context, u *model.User) error {
 	if u.WeChatId == "" {
 		return errors.New("WeChat id 为空！")
 	}
-	initialize.DB.Where(model.NewUserByWeChatId(u.WeChatId)).First(u)
+	initialize.DB.WithContext(ctx).Where(model.NewUserByWeChatId(u.WeChatId)).First(u)
 	return nil
 }
 
-func FillUserByUsername(u *model.User) error {
+func FillUserByUsername(ctx context.Context, u *model.User) error {
 	if u.Username == "" {
 		return errors.New("username 为空！")
 	}
-	initialize.DB.Where(model.NewUserByUsername(u.Username)).First(u)
+	initialize.DB.WithContext(ctx).Where(model.NewUserByUsername(u.Username)).First(u)
 	return nil
 }
 
-func IsEmailAlreadyTaken(email string) bool {
-	return initialize.DB.Where("email = ?", email).Find(model.NewUser()).RowsAffected == 1
+func IsEmailAlreadyTaken(ctx context.Context, email string) bool {
+	return initialize.DB.WithContext(ctx).Where("email = ?", email).Find(model.NewUser()).RowsAffected == 1
 }
 
-func IsWeChatIdAlreadyTaken(wechatId string) bool {
-	return initialize.DB.Where("wechat_id = ?", wechatId).Find(model.NewUser()).RowsAffected == 1
+func IsWeChatIdAlreadyTaken(ctx context.Context, wechatId string) bool {
+	return initialize.DB.WithContext(ctx).Where("wechat_id = ?", wechatId).Find(model.NewUser()).RowsAffected == 1
 }
 
-func IsGitHubIdAlreadyTaken(githubId string) bool {
-	return initialize.DB.Where("github_id = ?", githubId).Find(model.NewUser()).RowsAffected == 1
+func IsGitHubIdAlreadyTaken(ctx context.Context, githubId string) bool {
+	return initialize.DB.WithContext(ctx).Where("github_id = ?", githubId).Find(model.NewUser()).RowsAffected == 1
 }
 
-func IsLarkIdAlreadyTaken(githubId string) bool {
-	return initialize.DB.Where("lark_id = ?", githubId).Find(model.NewUser()).RowsAffected == 1
+func IsLarkIdAlreadyTaken(ctx context.Context, githubId string) bool {
+	return initialize.DB.WithContext(ctx).Where("lark_id = ?", githubId).Find(model.NewUser()).RowsAffected == 1
 }
 
-func IsOidcIdAlreadyTaken(oidcId string) bool {
-	return initialize.DB.Where("oidc_id = ?", oidcId).Find(model.NewUser()).RowsAffected == 1
+func IsOidcIdAlreadyTaken(ctx context.Context, oidcId string) bool {
+	return initialize.DB.WithContext(ctx).Where("oidc_id = ?", oidcId).Find(model.NewUser()).RowsAffected == 1
 }
 
-func IsUsernameAlreadyTaken(username string) bool {
-	return initialize.DB.Where("username = ?", username).Find(model.NewUser()).RowsAffected == 1
+func IsUsernameAlreadyTaken(ctx context.Context, username string) bool {
+	return initialize.DB.WithContext(ctx).Where("username = ?", username).Find(model.NewUser()).RowsAffected == 1
 }
 
-func ResetUserPasswordByEmail(email string, password string) error {
+func ResetUserPasswordByEmail(ctx context.Context, email string, password string) error {
 	if email == "" || password == "" {
 		return errors.New("邮箱地址或密码为空！")
 	}
@@ -276,16 +346,16 @@ func ResetUserPasswordByEmail(email string, password string) error {
 	if err != nil {
 		return err
 	}
-	err = initialize.DB.Model(model.NewUser()).Where("email = ?", email).Update("password", hashedPassword).Error
+	err = initialize.DB.WithContext(ctx).Model(model.NewUser()).Where("email = ?", email).Update("password", hashedPassword).Error
 	return err
 }
 
-func IsAdmin(userId int) bool {
+func IsAdmin(ctx context.Context, userId int) bool {
 	if userId == 0 {
 		return false
 	}
 	var user model.User
-	err := initialize.DB.Where("id = ?", userId).Select("role").Find(&user).Error
+	err := initialize.DB.WithContext(ctx).Where("id = ?", userId).Select("role").Find(&user).Error
 	if err != nil {
 		logger.SysError("no such user " + err.Error())
 		return false
@@ -293,25 +363,25 @@ func IsAdmin(userId int) bool {
 	return user.Role >= RoleAdminUser
 }
 
-func IsUserEnabled(userId int) (bool, error) {
+func IsUserEnabled(ctx context.Context, userId int) (bool, error) {
 	if userId == 0 {
 		return false, errors.New("user id is empty")
 	}
 	var user model.User
-	err := initialize.DB.Where("id = ?", userId).Select("status").Find(&user).Error
+	err := initialize.DB.WithContext(ctx).Where("id = ?", userId).Select("status").Find(&user).Error
 	if err != nil {
 		return false, err
 	}
 	return user.Status == UserStatusEnabled, nil
 }
 
-func ValidateAccessToken(token string) (user *model.User) {
+func ValidateAccessToken(ctx context.Context, token string) (user *model.User) {
 	if token == "" {
 		return nil
 	}
 	token = strings.Replace(token, "Bearer ", "", 1)
 	user = model.NewUser()
-	if initialize.DB.Where("access_token = ?", token).First(user).RowsAffected == 1 {
+	if initialize.DB.WithContext(ctx).Where("access_token = ?", token).First(user).RowsAffected == 1 {
 		return user
 	}
 	return nil
@@ -327,18 +397,18 @@ func ValidateAccessToken(token string) (user *model.User) {
 // 	return quota, err
 // }
 
-func GetUserEmail(id int) (email string, err error) {
-	err = initialize.DB.Model(model.NewUser()).Where("id = ?", id).Select("email").Find(&email).Error
+func GetUserEmail(ctx context.Context, id int) (email string, err error) {
+	err = initialize.DB.WithContext(ctx).Model(model.NewUser()).Where("id = ?", id).Select("email").Find(&email).Error
 	return email, err
 }
 
-func GetUserGroup(id int) (group string, err error) {
+func GetUserGroup(ctx context.Context, id int) (group string, err error) {
 	groupCol := "`group`"
 	if initialize.UsingPostgreSQL {
 		groupCol = `"group"`
 	}
 
-	err = initialize.DB.Model(model.NewUser()).Where("id = ?", id).Select(groupCol).Find(&group).Error
+	err = initialize.DB.WithContext(ctx).Model(model.NewUser()).Where("id = ?", id).Select(groupCol).Find(&group).Error
 	return group, err
 }
 
@@ -374,8 +444,8 @@ func GetUserGroup(id int) (group string, err error) {
 // 	return err
 // }
 
-func GetRootUserEmail() (email string) {
-	initialize.DB.Model(model.NewUser()).Where("role = ?", RoleRootUser).Select("email").Find(&email)
+func GetRootUserEmail(ctx context.Context) (email string) {
+	initialize.DB.WithContext(ctx).Model(model.NewUser()).Where("role = ?", RoleRootUser).Select("email").Find(&email)
 	return email
 }
 
@@ -418,7 +488,7 @@ func GetRootUserEmail() (email string) {
 // 	}
 // }
 
-func GetUsernameById(id int) (username string) {
-	initialize.DB.Model(model.NewUser()).Where("id = ?", id).Select("username").Find(&username)
+func GetUsernameById(ctx context.Context, id int) (username string) {
+	initialize.DB.WithContext(ctx).Model(model.NewUser()).Where("id = ?", id).Select("username").Find(&username)
 	return username
 }
@@ -0,0 +1,291 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils"
+)
+
+// 被禁用、被删除或有未过期封禁记录的用户处于封禁状态。数据库是唯一的来源，
+// Redis 中的有序集合 banned_users 保存所有节点共享的封禁列表，分数为过期时间，0 表示永久；
+// 变更时通过 user_bans 频道通知其他节点更新各自内存中的列表。
+const (
+	bannedUsersKey = "banned_users"
+	userBanChannel = "user_bans"
+)
+
+type userBanEvent struct {
+	Node   string `json:"node"`
+	UserId int    `json:"user_id"`
+}
+
+// LoadBannedUsers 启动时从数据库加载封禁列表，主节点同时重建 Redis 中的封禁集合
+func LoadBannedUsers(ctx context.Context) error {
+	bans, err := getBansFromDatabase(ctx)
+	if err != nil {
+		return err
+	}
+	utils.ResetBannedUsers(bans)
+	logger.SysLogf("loaded %d banned users", len(bans))
+	if !initialize.RedisEnabled || !global.IsMasterNode {
+		return nil
+	}
+	return saveBansToRedis(ctx, bans, true)
+}
+
+// saveBansToRedis 将封禁列表写入 Redis，replace 为 false 时只添加，不会覆盖其他节点同时写入的封禁
+func saveBansToRedis(ctx context.Context, bans map[int]int64, replace bool) error {
+	members := make([]*redis.Z, 0, len(bans))
+	for id, expiresAt := range bans {
+		members = append(members, &redis.Z{Score: float64(expiresAt), Member: strconv.Itoa(id)})
+	}
+	_, err := initialize.RDB.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if replace {
+			pipe.Del(ctx, bannedUsersKey)
+		}
+		if len(members) > 0 {
+			pipe.ZAdd(ctx, bannedUsersKey, members...)
+		}
+		return nil
+	})
+	return err
+}
+
+func getBansFromDatabase(ctx context.Context) (map[int]int64, error) {
+	var ids []int
+	err := initialize.DB.WithContext(ctx).Model(model.NewUser()).Where("status <> ?", UserStatusEnabled).Pluck("id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	bans := make(map[int]int64, len(ids))
+	for _, id := range ids {
+		bans[id] = 0
+	}
+	var userBans []*model.UserBan
+	err = initialize.DB.WithContext(ctx).Where("expires_at = 0 OR expires_at > ?", utils.GetTimestamp()).Find(&userBans).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, ban := range userBans {
+		// 永久封禁优先于临时封禁
+		if expiresAt, ok := bans[ban.UserId]; !ok || (expiresAt != 0 && ban.ExpiresAt == 0) {
+			bans[ban.UserId] = ban.ExpiresAt
+		}
+	}
+	return bans, nil
+}
+
+// getUserBanFromDatabase 返回用户是否被封禁以及封禁的过期时间
+func getUserBanFromDatabase(ctx context.Context, id int) (banned bool, expiresAt int64, err error) {
+	var status []int
+	err = initialize.DB.WithContext(ctx).Model(model.NewUser()).Where("id = ?", id).Pluck("status", &status).Error
+	if err != nil || len(status) == 0 {
+		return false, 0, err
+	}
+	if status[0] != UserStatusEnabled {
+		return true, 0, nil
+	}
+	var ban model.UserBan
+	err = initialize.DB.WithContext(ctx).Where("user_id = ? AND (expires_at = 0 OR expires_at > ?)", id, utils.GetTimestamp()).Limit(1).Find(&ban).Error
+	if err != nil || ban.Id == 0 {
+		return false, 0, err
+	}
+	return true, ban.ExpiresAt, nil
+}
+
+// syncUserBan 根据数据库重新计算用户的封禁状态，更新本节点与 Redis 中的封禁列表并通知其他节点
+func syncUserBan(ctx context.Context, id int) error {
+	banned, expiresAt, err := getUserBanFromDatabase(ctx, id)
+	if err != nil {
+		return err
+	}
+	if banned {
+		utils.BanUserUntil(id, expiresAt)
+	} else {
+		utils.UnbanUser(id)
+	}
+	if !initialize.RedisEnabled {
+		return nil
+	}
+	if banned {
+		err = initialize.RDB.ZAdd(ctx, bannedUsersKey, &redis.Z{Score: float64(expiresAt), Member: strconv.Itoa(id)}).Err()
+	} else {
+		err = initialize.RDB.ZRem(ctx, bannedUsersKey, strconv.Itoa(id)).Err()
+	}
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(userBanEvent{Node: nodeId, UserId: id})
+	if err != nil {
+		return err
+	}
+	return initialize.RedisPublish(ctx, userBanChannel, string(data))
+}
+
+// BanUser 封禁用户，duration 为封禁的秒数，0 表示永久封禁，已有的封禁会被替换
+func BanUser(ctx context.Context, userId int, reason string, duration int64, operatorId int) error {
+	if duration < 0 {
+		return errors.New("封禁时长不能为负数")
+	}
+	ban := &model.UserBan{
+		UserId:     userId,
+		Reason:     reason,
+		OperatorId: operatorId,
+		CreatedAt:  utils.GetTimestamp(),
+	}
+	if duration > 0 {
+		ban.ExpiresAt = ban.CreatedAt + duration
+	}
+	err := initialize.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(model.NewUserBan()).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(ban).Error; err != nil {
+			return err
+		}
+		return emitWebhookEvent(tx, model.WebhookEventUserBanned, map[string]any{
+			"id":          userId,
+			"reason":      reason,
+			"expires_at":  ban.ExpiresAt,
+			"operator_id": operatorId,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	wakeWebhookQueue()
+	ForceLogout(ctx, userId, "账户已被封禁")
+	return syncUserBan(ctx, userId)
+}
+
+// UnbanUser 解除用户的封禁记录，被禁用的用户仍然处于封禁状态，需要另外启用
+func UnbanUser(ctx context.Context, userId int) error {
+	err := initialize.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ?", userId).Delete(model.NewUserBan())
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return emitWebhookEvent(tx, model.WebhookEventUserUnbanned, map[string]any{"id": userId})
+	})
+	if err != nil {
+		return err
+	}
+	wakeWebhookQueue()
+	return syncUserBan(ctx, userId)
+}
+
+// GetBannedUsers 返回当前被禁用或有未过期封禁记录的用户，不包括已删除的用户
+func GetBannedUsers(ctx context.Context) ([]*model.BannedUser, error) {
+	users := make([]*model.BannedUser, 0)
+	err := initialize.DB.WithContext(ctx).Model(model.NewUser()).
+		Select("users.id AS user_id, users.username, users.status, COALESCE(user_bans.reason, '') AS reason, COALESCE(user_bans.expires_at, 0) AS expires_at, COALESCE(user_bans.created_at, 0) AS created_at").
+		Joins("LEFT JOIN user_bans ON user_bans.user_id = users.id AND (user_bans.expires_at = 0 OR user_bans.expires_at > ?)", utils.GetTimestamp()).
+		Where("users.status = ? OR user_bans.id IS NOT NULL", UserStatusDisabled).
+		Where("users.status <> ?", UserStatusDeleted).
+		Order("users.id").
+		Scan(&users).Error
+	return users, err
+}
+
+// SyncBannedUsers 定期重新加载封禁列表，作为 Redis 推送的兜底。
+// 启用 Redis 时从 Redis 加载并清理已过期的封禁，否则从数据库加载。
+func SyncBannedUsers(ctx context.Context, frequency int) {
+	ticker := time.NewTicker(time.Duration(frequency) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := syncBannedUsers(ctx); err != nil {
+				logger.SysError("failed to sync banned users: " + err.Error())
+			}
+		}
+	}
+}
+
+func syncBannedUsers(ctx context.Context) error {
+	exists := int64(0)
+	if initialize.RedisEnabled {
+		var err error
+		if exists, err = initialize.RDB.Exists(ctx, bannedUsersKey).Result(); err != nil {
+			return err
+		}
+	}
+	// 没有任何封禁时集合不存在，Redis 数据丢失时也需要从数据库重建
+	if exists == 0 {
+		bans, err := getBansFromDatabase(ctx)
+		if err != nil {
+			return err
+		}
+		utils.ResetBannedUsers(bans)
+		if !initialize.RedisEnabled || len(bans) == 0 {
+			return nil
+		}
+		return saveBansToRedis(ctx, bans, false)
+	}
+	now := strconv.FormatInt(utils.GetTimestamp(), 10)
+	if err := initialize.RDB.ZRemRangeByScore(ctx, bannedUsersKey, "(0", now).Err(); err != nil {
+		return err
+	}
+	members, err := initialize.RDB.ZRangeWithScores(ctx, bannedUsersKey, 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	bans := make(map[int]int64, len(members))
+	for _, member := range members {
+		id, err := strconv.Atoi(member.Member.(string))
+		if err != nil {
+			continue
+		}
+		bans[id] = int64(member.Score)
+	}
+	utils.ResetBannedUsers(bans)
+	return nil
+}
+
+// SubscribeUserBans 接收其他节点的封禁变更，从 Redis 读取该用户最新的封禁状态
+func SubscribeUserBans(ctx context.Context) {
+	pubsub := initialize.RedisSubscribe(ctx, userBanChannel)
+	defer pubsub.Close()
+	logger.SysLog("subscribed to user ban changes")
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var event userBanEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				logger.SysError("failed to unmarshal user ban event: " + err.Error())
+				continue
+			}
+			if event.Node == nodeId {
+				continue
+			}
+			score, err := initialize.RDB.ZScore(ctx, bannedUsersKey, strconv.Itoa(event.UserId)).Result()
+			switch {
+			case errors.Is(err, redis.Nil):
+				utils.UnbanUser(event.UserId)
+			case err != nil:
+				logger.SysError("failed to get user ban: " + err.Error())
+			default:
+				utils.BanUserUntil(event.UserId, int64(score))
+			}
+		}
+	}
+}
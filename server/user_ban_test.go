@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils"
+)
+
+func TestUserBan(t *testing.T) {
+	Convey("TestUserBan", t, func() {
+		setupTestDB(t, nil)
+		t.Cleanup(func() { utils.ResetBannedUsers(nil) })
+		ctx := context.Background()
+		var users []*model.User
+		for _, username := range []string{"alice", "bob", "carol", "dave"} {
+			user := &model.User{Username: username, Password: "12345678"}
+			So(InsertUser(ctx, user, 0), ShouldBeNil)
+			users = append(users, user)
+		}
+		alice, bob, carol, dave := users[0], users[1], users[2], users[3]
+		setStatus := func(user *model.User, status int) {
+			So(initialize.DB.Model(user).Update("status", status).Error, ShouldBeNil)
+		}
+
+		So(BanUser(ctx, alice.Id, "spam", -1, 1), ShouldNotBeNil)
+		So(BanUser(ctx, alice.Id, "spam", 3600, 1), ShouldBeNil)
+		So(utils.IsUserBanned(alice.Id), ShouldBeTrue)
+
+		// 过期的封禁不再生效
+		So(initialize.DB.Model(model.NewUserBan()).Where("user_id = ?", alice.Id).Update("expires_at", utils.GetTimestamp()-1).Error, ShouldBeNil)
+		bans, err := getBansFromDatabase(ctx)
+		So(err, ShouldBeNil)
+		So(bans, ShouldNotContainKey, alice.Id)
+		So(syncUserBan(ctx, alice.Id), ShouldBeNil)
+		So(utils.IsUserBanned(alice.Id), ShouldBeFalse)
+
+		// 被禁用的用户是永久封禁，优先于临时封禁
+		So(BanUser(ctx, bob.Id, "spam", 3600, 1), ShouldBeNil)
+		setStatus(bob, UserStatusDisabled)
+		bans, err = getBansFromDatabase(ctx)
+		So(err, ShouldBeNil)
+		So(bans[bob.Id], ShouldEqual, 0)
+
+		// 解除封禁记录后，被禁用的用户仍然处于封禁状态
+		So(UnbanUser(ctx, bob.Id), ShouldBeNil)
+		So(utils.IsUserBanned(bob.Id), ShouldBeTrue)
+		setStatus(bob, UserStatusEnabled)
+		So(syncUserBan(ctx, bob.Id), ShouldBeNil)
+		So(utils.IsUserBanned(bob.Id), ShouldBeFalse)
+
+		// 封禁列表包括被禁用与有未过期封禁记录的用户，不包括已删除的用户与过期的封禁
+		So(BanUser(ctx, carol.Id, "abuse", 0, 1), ShouldBeNil)
+		setStatus(bob, UserStatusDisabled)
+		So(BanUser(ctx, dave.Id, "abuse", 0, 1), ShouldBeNil)
+		setStatus(dave, UserStatusDeleted)
+		banned, err := GetBannedUsers(ctx)
+		So(err, ShouldBeNil)
+		So(banned, ShouldHaveLength, 2)
+		So(banned[0].UserId, ShouldEqual, bob.Id)
+		So(banned[0].Status, ShouldEqual, UserStatusDisabled)
+		So(banned[0].Reason, ShouldEqual, "")
+		So(banned[1].UserId, ShouldEqual, carol.Id)
+		So(banned[1].Reason, ShouldEqual, "abuse")
+		So(banned[1].ExpiresAt, ShouldEqual, 0)
+	})
+}
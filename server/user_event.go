@@ -0,0 +1,184 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+)
+
+const userEventChannel = "user_events"
+
+// userEventBufferSize 每个连接缓存的事件数，客户端读取过慢时丢弃新的事件
+const userEventBufferSize = 16
+
+type userEventListener struct {
+	userId int
+	group  string
+	ch     chan *model.UserEvent
+}
+
+// userEventListeners 本节点上打开的事件流，按用户 ID 索引
+var userEventListeners = struct {
+	sync.Mutex
+	users  map[int]map[*userEventListener]struct{}
+	closed bool
+}{users: make(map[int]map[*userEventListener]struct{})}
+
+// userEventMessage 在节点之间传递的事件，UserIds、Group 与 All 决定接收的用户
+type userEventMessage struct {
+	Node    string           `json:"node"`
+	UserIds []int            `json:"user_ids,omitempty"`
+	Group   string           `json:"group,omitempty"`
+	All     bool             `json:"all,omitempty"`
+	Event   *model.UserEvent `json:"event"`
+}
+
+func (m *userEventMessage) matches(listener *userEventListener) bool {
+	if m.All {
+		return true
+	}
+	if m.Group != "" {
+		return listener.group == m.Group
+	}
+	for _, id := range m.UserIds {
+		if id == listener.userId {
+			return true
+		}
+	}
+	return false
+}
+
+// ListenUserEvents 订阅推送给该用户的事件，返回的 channel 在调用 cancel 或服务关闭时被关闭
+func ListenUserEvents(ctx context.Context, userId int) (<-chan *model.UserEvent, func()) {
+	group, err := GetUserGroup(ctx, userId)
+	if err != nil {
+		logger.SysError("failed to get user group: " + err.Error())
+	}
+	listener := &userEventListener{
+		userId: userId,
+		group:  group,
+		ch:     make(chan *model.UserEvent, userEventBufferSize),
+	}
+	userEventListeners.Lock()
+	defer userEventListeners.Unlock()
+	if userEventListeners.closed {
+		close(listener.ch)
+		return listener.ch, func() {}
+	}
+	if userEventListeners.users[userId] == nil {
+		userEventListeners.users[userId] = make(map[*userEventListener]struct{})
+	}
+	userEventListeners.users[userId][listener] = struct{}{}
+	cancel := func() {
+		userEventListeners.Lock()
+		defer userEventListeners.Unlock()
+		listeners := userEventListeners.users[userId]
+		if _, ok := listeners[listener]; !ok {
+			return
+		}
+		delete(listeners, listener)
+		if len(listeners) == 0 {
+			delete(userEventListeners.users, userId)
+		}
+		close(listener.ch)
+	}
+	return listener.ch, cancel
+}
+
+// CloseUserEventStreams 关闭本节点上所有的事件流，使 HTTP 服务可以在退出时结束这些长连接
+func CloseUserEventStreams() {
+	userEventListeners.Lock()
+	defer userEventListeners.Unlock()
+	userEventListeners.closed = true
+	for userId, listeners := range userEventListeners.users {
+		for listener := range listeners {
+			close(listener.ch)
+		}
+		delete(userEventListeners.users, userId)
+	}
+}
+
+// deliverUserEvent 将事件推送给本节点上匹配的事件流
+func deliverUserEvent(msg *userEventMessage) {
+	userEventListeners.Lock()
+	defer userEventListeners.Unlock()
+	deliver := func(listener *userEventListener) {
+		if !msg.matches(listener) {
+			return
+		}
+		select {
+		case listener.ch <- msg.Event:
+		default:
+			logger.SysWarnf("user event stream of user %d is full, dropping %s event", listener.userId, msg.Event.Event)
+		}
+	}
+	if len(msg.UserIds) > 0 && !msg.All {
+		for _, id := range msg.UserIds {
+			for listener := range userEventListeners.users[id] {
+				deliver(listener)
+			}
+		}
+		return
+	}
+	for _, listeners := range userEventListeners.users {
+		for listener := range listeners {
+			deliver(listener)
+		}
+	}
+}
+
+// publishUserEvent 推送给本节点上的事件流，启用 Redis 时同时通知其他节点
+func publishUserEvent(ctx context.Context, msg *userEventMessage) {
+	msg.Node = nodeId
+	deliverUserEvent(msg)
+	if !initialize.RedisEnabled {
+		return
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		logger.SysError("failed to marshal user event: " + err.Error())
+		return
+	}
+	if err = initialize.RedisPublish(ctx, userEventChannel, string(data)); err != nil {
+		logger.SysError("failed to publish user event: " + err.Error())
+	}
+}
+
+// ForceLogout 通知该用户所有打开的页面退出登录，reason 会展示给用户
+func ForceLogout(ctx context.Context, userId int, reason string) {
+	publishUserEvent(ctx, &userEventMessage{
+		UserIds: []int{userId},
+		Event:   &model.UserEvent{Event: model.UserEventLogout, Data: map[string]string{"reason": reason}},
+	})
+}
+
+// SubscribeUserEvents 接收其他节点发布的用户事件并推送给本节点上的事件流
+func SubscribeUserEvents(ctx context.Context) {
+	pubsub := initialize.RedisSubscribe(ctx, userEventChannel)
+	defer pubsub.Close()
+	logger.SysLog("subscribed to user events")
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			var event userEventMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				logger.SysError("failed to unmarshal user event: " + err.Error())
+				continue
+			}
+			if event.Node == nodeId || event.Event == nil {
+				continue
+			}
+			deliverUserEvent(&event)
+		}
+	}
+}
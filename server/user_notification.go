@@ -0,0 +1,157 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils"
+)
+
+const (
+	userNotificationTitleMaxLength = 255
+	broadcastBatchSize             = 500
+)
+
+func validateUserNotification(notification *model.UserNotification) error {
+	notification.Title = strings.TrimSpace(notification.Title)
+	if notification.Title == "" {
+		return errors.New("通知标题不能为空")
+	}
+	if utf8.RuneCountInString(notification.Title) > userNotificationTitleMaxLength {
+		return errors.New("通知标题过长")
+	}
+	switch notification.Level {
+	case "":
+		notification.Level = model.UserNotificationLevelInfo
+	case model.UserNotificationLevelInfo, model.UserNotificationLevelWarning, model.UserNotificationLevelError:
+	default:
+		return errors.New("通知级别无效")
+	}
+	return nil
+}
+
+// SendUserNotification 向用户的收件箱发送一条通知，并推送给该用户打开的页面
+func SendUserNotification(ctx context.Context, userId int, title string, content string, level string, senderId int) (*model.UserNotification, error) {
+	notification := &model.UserNotification{
+		UserId:    userId,
+		Title:     title,
+		Content:   content,
+		Level:     level,
+		SenderId:  senderId,
+		CreatedAt: utils.GetTimestamp(),
+	}
+	if err := validateUserNotification(notification); err != nil {
+		return nil, err
+	}
+	if err := initialize.DB.WithContext(ctx).Create(notification).Error; err != nil {
+		return nil, err
+	}
+	logger.Infof(ctx, "notification %d sent to user %d", notification.Id, userId)
+	publishUserEvent(ctx, &userEventMessage{
+		UserIds: []int{userId},
+		Event:   &model.UserEvent{Event: model.UserEventNotification, Data: notification},
+	})
+	return notification, nil
+}
+
+// BroadcastUserNotification 向所有启用的用户或某个分组中的用户发送通知，返回收到通知的用户数
+func BroadcastUserNotification(ctx context.Context, req *model.BroadcastRequest, senderId int) (int, error) {
+	template := &model.UserNotification{
+		Title:     req.Title,
+		Content:   req.Content,
+		Level:     req.Level,
+		SenderId:  senderId,
+		CreatedAt: utils.GetTimestamp(),
+	}
+	if err := validateUserNotification(template); err != nil {
+		return 0, err
+	}
+	query := initialize.DB.WithContext(ctx).Model(model.NewUser()).Where("status = ?", UserStatusEnabled)
+	if req.Group != "" {
+		query = query.Where(map[string]any{"group": req.Group})
+	}
+	var userIds []int
+	if err := query.Order("id").Pluck("id", &userIds).Error; err != nil {
+		return 0, err
+	}
+	if len(userIds) == 0 {
+		return 0, errors.New("没有可以接收通知的用户")
+	}
+	notifications := make([]*model.UserNotification, len(userIds))
+	for i, userId := range userIds {
+		notification := *template
+		notification.UserId = userId
+		notifications[i] = &notification
+	}
+	if err := initialize.DB.WithContext(ctx).CreateInBatches(notifications, broadcastBatchSize).Error; err != nil {
+		return 0, err
+	}
+	logger.Infof(ctx, "notification %q broadcast to %d users by user %d", template.Title, len(userIds), senderId)
+	// 各用户的通知 ID 不同，推送的通知 ID 为 0，客户端需重新获取收件箱
+	publishUserEvent(ctx, &userEventMessage{
+		All:   req.Group == "",
+		Group: req.Group,
+		Event: &model.UserEvent{Event: model.UserEventNotification, Data: template},
+	})
+	return len(userIds), nil
+}
+
+// GetUserNotifications 按时间从新到旧返回用户的通知，unreadOnly 为 true 时只返回未读的通知
+func GetUserNotifications(ctx context.Context, userId int, unreadOnly bool, startIdx int, num int) ([]*model.UserNotification, error) {
+	notifications := make([]*model.UserNotification, 0)
+	query := initialize.DB.WithContext(ctx).Where("user_id = ?", userId).Order("id desc").Limit(num).Offset(startIdx)
+	if unreadOnly {
+		query = query.Where("read_at = ?", 0)
+	}
+	err := query.Find(&notifications).Error
+	return notifications, err
+}
+
+func CountUnreadUserNotifications(ctx context.Context, userId int) (count int64, err error) {
+	err = initialize.DB.WithContext(ctx).Model(model.NewUserNotification()).Where("user_id = ? AND read_at = ?", userId, 0).Count(&count).Error
+	return count, err
+}
+
+// ReadUserNotification 将用户的一条通知标记为已读，已读的通知保持原来的阅读时间
+func ReadUserNotification(ctx context.Context, userId int, id int) error {
+	result := initialize.DB.WithContext(ctx).Model(model.NewUserNotification()).
+		Where("id = ? AND user_id = ? AND read_at = ?", id, userId, 0).
+		Update("read_at", utils.GetTimestamp())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		err := initialize.DB.WithContext(ctx).Model(model.NewUserNotification()).Where("id = ? AND user_id = ?", id, userId).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return errors.New("通知不存在")
+		}
+	}
+	return nil
+}
+
+// ReadAllUserNotifications 将用户所有未读的通知标记为已读
+func ReadAllUserNotifications(ctx context.Context, userId int) error {
+	return initialize.DB.WithContext(ctx).Model(model.NewUserNotification()).
+		Where("user_id = ? AND read_at = ?", userId, 0).
+		Update("read_at", utils.GetTimestamp()).Error
+}
+
+func DeleteUserNotification(ctx context.Context, userId int, id int) error {
+	result := initialize.DB.WithContext(ctx).Where("id = ? AND user_id = ?", id, userId).Delete(model.NewUserNotification())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("通知不存在")
+	}
+	return nil
+}
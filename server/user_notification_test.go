@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/9688101/hx-admin/model"
+)
+
+// receiveUserEvent 返回已经推送到 channel 中的事件，没有时返回 nil
+func receiveUserEvent(events <-chan *model.UserEvent) *model.UserEvent {
+	select {
+	case event := <-events:
+		return event
+	default:
+		return nil
+	}
+}
+
+func TestUserNotification(t *testing.T) {
+	Convey("TestUserNotification", t, func() {
+		setupTestDB(t, nil)
+		ctx := context.Background()
+		alice := &model.User{Username: "alice", Password: "12345678", Group: "vip"}
+		bob := &model.User{Username: "bob", Password: "12345678"}
+		carol := &model.User{Username: "carol", Password: "12345678", Status: UserStatusDisabled}
+		for _, user := range []*model.User{alice, bob, carol} {
+			So(InsertUser(ctx, user, 0), ShouldBeNil)
+		}
+		aliceEvents, cancelAlice := ListenUserEvents(ctx, alice.Id)
+		defer cancelAlice()
+		bobEvents, cancelBob := ListenUserEvents(ctx, bob.Id)
+		defer cancelBob()
+
+		// 只推送给目标用户
+		notification, err := SendUserNotification(ctx, alice.Id, "hello", "world", "", 0)
+		So(err, ShouldBeNil)
+		So(notification.Level, ShouldEqual, model.UserNotificationLevelInfo)
+		event := receiveUserEvent(aliceEvents)
+		So(event, ShouldNotBeNil)
+		So(event.Event, ShouldEqual, model.UserEventNotification)
+		So(event.Data.(*model.UserNotification).Id, ShouldEqual, notification.Id)
+		So(receiveUserEvent(bobEvents), ShouldBeNil)
+		_, err = SendUserNotification(ctx, alice.Id, "", "", "", 0)
+		So(err, ShouldNotBeNil)
+
+		// 广播给分组或所有启用的用户
+		count, err := BroadcastUserNotification(ctx, &model.BroadcastRequest{Title: "vip only", Group: "vip"}, 1)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		So(receiveUserEvent(aliceEvents), ShouldNotBeNil)
+		So(receiveUserEvent(bobEvents), ShouldBeNil)
+		count, err = BroadcastUserNotification(ctx, &model.BroadcastRequest{Title: "maintenance", Level: model.UserNotificationLevelWarning}, 1)
+		So(err, ShouldBeNil)
+		So(count, ShouldEqual, 2)
+		So(receiveUserEvent(aliceEvents), ShouldNotBeNil)
+		So(receiveUserEvent(bobEvents), ShouldNotBeNil)
+		_, err = BroadcastUserNotification(ctx, &model.BroadcastRequest{Title: "nobody", Group: "none"}, 1)
+		So(err, ShouldNotBeNil)
+
+		// 已读状态只属于通知的接收者
+		unread, err := CountUnreadUserNotifications(ctx, alice.Id)
+		So(err, ShouldBeNil)
+		So(unread, ShouldEqual, 3)
+		So(ReadUserNotification(ctx, bob.Id, notification.Id), ShouldNotBeNil)
+		So(ReadUserNotification(ctx, alice.Id, notification.Id), ShouldBeNil)
+		So(ReadUserNotification(ctx, alice.Id, notification.Id), ShouldBeNil)
+		unreadNotifications, err := GetUserNotifications(ctx, alice.Id, true, 0, 10)
+		So(err, ShouldBeNil)
+		So(unreadNotifications, ShouldHaveLength, 2)
+		So(ReadAllUserNotifications(ctx, alice.Id), ShouldBeNil)
+		unread, _ = CountUnreadUserNotifications(ctx, alice.Id)
+		So(unread, ShouldEqual, 0)
+		So(DeleteUserNotification(ctx, bob.Id, notification.Id), ShouldNotBeNil)
+		So(DeleteUserNotification(ctx, alice.Id, notification.Id), ShouldBeNil)
+		notifications, err := GetUserNotifications(ctx, alice.Id, false, 0, 10)
+		So(err, ShouldBeNil)
+		So(notifications, ShouldHaveLength, 2)
+
+		// 封禁与禁用时通知用户退出登录
+		So(BanUser(ctx, bob.Id, "spam", 0, 1), ShouldBeNil)
+		event = receiveUserEvent(bobEvents)
+		So(event, ShouldNotBeNil)
+		So(event.Event, ShouldEqual, model.UserEventLogout)
+		So(UpdateUser(ctx, &model.User{Id: alice.Id, Status: UserStatusDisabled}, false), ShouldBeNil)
+		So(receiveUserEvent(aliceEvents).Event, ShouldEqual, model.UserEventLogout)
+
+		// 服务关闭时结束所有的事件流
+		CloseUserEventStreams()
+		defer func() { userEventListeners.closed = false }()
+		_, ok := <-aliceEvents
+		So(ok, ShouldBeFalse)
+		closedEvents, cancel := ListenUserEvents(ctx, bob.Id)
+		cancel()
+		_, ok = <-closedEvents
+		So(ok, ShouldBeFalse)
+	})
+}
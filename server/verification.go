@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/utils"
+)
+
+// 启用 Redis 时验证码保存在 Redis 中，多个节点共享，否则保存在当前节点的内存中
+
+// verifyCodeScript 原子地校验验证码，错误时增加尝试次数，达到上限后删除验证码
+// KEYS[1] 验证码的 key；ARGV[1] 用户输入的验证码；ARGV[2] 最大尝试次数
+// 脚本中的 == 不是常量时间的比较，但每个验证码最多只能尝试 ARGV[2] 次，且请求经过网络与 Redis 的耗时抖动远大于比较的耗时，
+// 无法通过计时逐位猜出验证码。
+var verifyCodeScript = redis.NewScript(`
+local code = redis.call('HGET', KEYS[1], 'code')
+if not code then
+	return 0
+end
+if code == ARGV[1] then
+	return 1
+end
+local attempts = redis.call('HINCRBY', KEYS[1], 'attempts', 1)
+if attempts >= tonumber(ARGV[2]) then
+	redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func verificationKey(key string, purpose string) string {
+	return "verification:" + purpose + ":" + key
+}
+
+// RegisterVerificationCode 保存验证码，同一个 key 已有的验证码及其尝试次数会被覆盖
+func RegisterVerificationCode(ctx context.Context, key string, code string, purpose string) error {
+	if !initialize.RedisEnabled {
+		utils.RegisterVerificationCodeWithKey(key, code, purpose)
+		return nil
+	}
+	redisKey := verificationKey(key, purpose)
+	_, err := initialize.RDB.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, redisKey)
+		pipe.HSet(ctx, redisKey, "code", code, "attempts", 0)
+		pipe.Expire(ctx, redisKey, time.Duration(utils.VerificationValidMinutes)*time.Minute)
+		return nil
+	})
+	return err
+}
+
+// VerifyCode 校验验证码，输错 utils.VerificationMaxAttempts 次后验证码失效
+func VerifyCode(ctx context.Context, key string, code string, purpose string) bool {
+	if !initialize.RedisEnabled {
+		return utils.VerifyCodeWithKey(key, code, purpose)
+	}
+	ok, err := verifyCodeScript.Run(ctx, initialize.RDB, []string{verificationKey(key, purpose)}, code, utils.VerificationMaxAttempts).Int()
+	if err != nil {
+		logger.Error(ctx, "failed to verify code: "+err.Error())
+		return false
+	}
+	return ok == 1
+}
+
+// DeleteVerificationCode 删除已使用的验证码
+func DeleteVerificationCode(ctx context.Context, key string, purpose string) {
+	if !initialize.RedisEnabled {
+		utils.DeleteKey(key, purpose)
+		return
+	}
+	if err := initialize.RDB.Del(ctx, verificationKey(key, purpose)).Err(); err != nil {
+		logger.Error(ctx, "failed to delete verification code: "+err.Error())
+	}
+}
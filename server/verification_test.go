@@ -0,0 +1,53 @@
+package server
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/9688101/hx-admin/utils"
+)
+
+func testVerifyCode(ctx context.Context) {
+	key := "verify-" + strconv.FormatInt(time.Now().UnixNano(), 10) + "@example.com"
+	purpose := utils.EmailVerificationPurpose
+	defer DeleteVerificationCode(ctx, key, purpose)
+
+	So(RegisterVerificationCode(ctx, key, "123456", purpose), ShouldBeNil)
+	So(VerifyCode(ctx, key, "654321", purpose), ShouldBeFalse)
+	So(VerifyCode(ctx, key, "123456", "other"), ShouldBeFalse)
+	So(VerifyCode(ctx, key, "123456", purpose), ShouldBeTrue)
+
+	// 输错达到上限后验证码失效
+	for i := 0; i < utils.VerificationMaxAttempts; i++ {
+		So(VerifyCode(ctx, key, "654321", purpose), ShouldBeFalse)
+	}
+	So(VerifyCode(ctx, key, "123456", purpose), ShouldBeFalse)
+
+	// 重新获取的验证码重新计算尝试次数
+	So(RegisterVerificationCode(ctx, key, "123456", purpose), ShouldBeNil)
+	for i := 0; i < utils.VerificationMaxAttempts-1; i++ {
+		So(VerifyCode(ctx, key, "654321", purpose), ShouldBeFalse)
+	}
+	So(VerifyCode(ctx, key, "123456", purpose), ShouldBeTrue)
+
+	DeleteVerificationCode(ctx, key, purpose)
+	So(VerifyCode(ctx, key, "123456", purpose), ShouldBeFalse)
+}
+
+func TestVerifyCode(t *testing.T) {
+	Convey("TestVerifyCode", t, func() {
+		setupTestDB(t, nil)
+		testVerifyCode(context.Background())
+	})
+}
+
+func TestVerifyCodeRedis(t *testing.T) {
+	setupTestRedis(t)
+	Convey("TestVerifyCodeRedis", t, func() {
+		testVerifyCode(context.Background())
+	})
+}
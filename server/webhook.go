@@ -0,0 +1,493 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils"
+	"github.com/9688101/hx-admin/utils/message"
+)
+
+const (
+	WebhookStatusEnabled  = 1
+	WebhookStatusDisabled = 2
+)
+
+const (
+	WebhookDeliveryStatusQueued    = 1
+	WebhookDeliveryStatusSending   = 2
+	WebhookDeliveryStatusDelivered = 3
+	WebhookDeliveryStatusFailed    = 4 // 达到最大尝试次数，不再重试
+)
+
+const (
+	webhookBatchSize       = 20
+	webhookLockSeconds     = 5 * 60 // 投递中的记录超过该时间未完成，视为节点已崩溃，重新投递
+	webhookResponseMaxSize = 4096   // 投递记录中保留的响应体长度
+)
+
+// webhookQueueWakeup 有新事件时唤醒本节点的投递任务，不必等到下一次轮询
+var webhookQueueWakeup = make(chan struct{}, 1)
+
+func wakeWebhookQueue() {
+	select {
+	case webhookQueueWakeup <- struct{}{}:
+	default:
+	}
+}
+
+// emitWebhookEvent 将领域事件写入 outbox，必须在修改数据的同一个事务中调用，事务回滚时事件也不会被投递。
+// 事务提交后调用 wakeWebhookQueue 可以立即投递。
+func emitWebhookEvent(tx *gorm.DB, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&model.WebhookEvent{
+		Event:     event,
+		Payload:   string(payload),
+		CreatedAt: utils.GetTimestamp(),
+	}).Error
+}
+
+// webhookUserData 用户相关事件的数据，不包括密码与令牌
+func webhookUserData(user *model.User) map[string]any {
+	return map[string]any{
+		"id":           user.Id,
+		"username":     user.Username,
+		"display_name": user.DisplayName,
+		"email":        user.Email,
+		"role":         user.Role,
+		"status":       user.Status,
+	}
+}
+
+// RunWebhookQueue 定期将 outbox 中的事件分发给订阅的 webhook 并投递，直到 ctx 结束。
+// 所有节点都可以运行，每个事件只会被一个节点分发，每次投递只会被一个节点发送。
+func RunWebhookQueue(ctx context.Context) {
+	interval := time.Duration(global.GetConfig().Webhook.PollInterval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastPurge time.Time
+	for {
+		processWebhookQueue(ctx)
+		if time.Since(lastPurge) > time.Hour {
+			purgeWebhookEvents(ctx)
+			lastPurge = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-webhookQueueWakeup:
+		}
+	}
+}
+
+func processWebhookQueue(ctx context.Context) {
+	dispatchWebhookEvents(ctx)
+	processWebhookDeliveries(ctx)
+}
+
+// dispatchWebhookEvents 为尚未分发的事件创建投递记录，每个事件只会被分发一次
+func dispatchWebhookEvents(ctx context.Context) {
+	for {
+		var events []*model.WebhookEvent
+		err := initialize.DB.WithContext(ctx).Where("dispatched_at = ?", 0).Order("id").Limit(webhookBatchSize).Find(&events).Error
+		if err != nil {
+			logger.SysError("failed to load webhook events: " + err.Error())
+			return
+		}
+		if len(events) == 0 {
+			return
+		}
+		var webhooks []*model.Webhook
+		if err = initialize.DB.WithContext(ctx).Where("status = ?", WebhookStatusEnabled).Find(&webhooks).Error; err != nil {
+			logger.SysError("failed to load webhooks: " + err.Error())
+			return
+		}
+		for _, event := range events {
+			if err = dispatchWebhookEvent(ctx, event, webhooks); err != nil {
+				logger.SysErrorf("failed to dispatch webhook event %d: %s", event.Id, err.Error())
+				return
+			}
+		}
+		if len(events) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+func dispatchWebhookEvent(ctx context.Context, event *model.WebhookEvent, webhooks []*model.Webhook) error {
+	return initialize.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := utils.GetTimestamp()
+		result := tx.Model(event).Where("dispatched_at = ?", 0).Update("dispatched_at", now)
+		if result.Error != nil || result.RowsAffected == 0 {
+			// 已被其他节点分发
+			return result.Error
+		}
+		for _, webhook := range webhooks {
+			if !webhookSubscribes(webhook, event.Event) {
+				continue
+			}
+			if err := tx.Create(newWebhookDelivery(webhook.Id, event, 0)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func newWebhookDelivery(webhookId int, event *model.WebhookEvent, redeliveryOf int) *model.WebhookDelivery {
+	now := utils.GetTimestamp()
+	return &model.WebhookDelivery{
+		WebhookId:     webhookId,
+		EventId:       event.Id,
+		Event:         event.Event,
+		RedeliveryOf:  redeliveryOf,
+		Status:        WebhookDeliveryStatusQueued,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
+
+func webhookSubscribes(webhook *model.Webhook, event string) bool {
+	for _, e := range strings.Split(webhook.Events, ",") {
+		e = strings.TrimSpace(e)
+		if e == "*" || e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func processWebhookDeliveries(ctx context.Context) {
+	// 投递中途崩溃的节点留下的记录重新排队
+	err := initialize.DB.WithContext(ctx).Model(model.NewWebhookDelivery()).
+		Where("status = ? AND locked_until < ?", WebhookDeliveryStatusSending, utils.GetTimestamp()).
+		Updates(map[string]any{"status": WebhookDeliveryStatusQueued, "locked_by": ""}).Error
+	if err != nil {
+		logger.SysError("failed to requeue stale webhook deliveries: " + err.Error())
+		return
+	}
+	for ctx.Err() == nil {
+		var deliveries []*model.WebhookDelivery
+		err = initialize.DB.WithContext(ctx).Where("status = ? AND next_attempt_at <= ?", WebhookDeliveryStatusQueued, utils.GetTimestamp()).
+			Order("next_attempt_at").Limit(webhookBatchSize).Find(&deliveries).Error
+		if err != nil {
+			logger.SysError("failed to load queued webhook deliveries: " + err.Error())
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+		claimed := false
+		for _, delivery := range deliveries {
+			if claimWebhookDelivery(ctx, delivery) {
+				claimed = true
+				deliverWebhook(ctx, delivery)
+			}
+		}
+		if !claimed {
+			return
+		}
+	}
+}
+
+// claimWebhookDelivery 将投递记录标记为由本节点发送，其他节点已经取走时返回 false
+func claimWebhookDelivery(ctx context.Context, delivery *model.WebhookDelivery) bool {
+	result := initialize.DB.WithContext(ctx).Model(delivery).
+		Where("status = ?", WebhookDeliveryStatusQueued).
+		Updates(map[string]any{
+			"status":       WebhookDeliveryStatusSending,
+			"locked_by":    nodeId,
+			"locked_until": utils.GetTimestamp() + webhookLockSeconds,
+		})
+	if result.Error != nil {
+		logger.SysError("failed to claim webhook delivery: " + result.Error.Error())
+		return false
+	}
+	return result.RowsAffected == 1
+}
+
+// sendWebhook 发送一次投递，返回接收方的响应与错误
+func sendWebhook(ctx context.Context, delivery *model.WebhookDelivery) (*message.WebhookResponse, error) {
+	webhook := model.NewWebhook()
+	err := initialize.DB.WithContext(ctx).First(webhook, "id = ?", delivery.WebhookId).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && webhook.Status != WebhookStatusEnabled) {
+		return nil, errors.New("webhook 已被删除或已禁用")
+	}
+	if err != nil {
+		return nil, err
+	}
+	event := model.NewWebhookEvent()
+	if err = initialize.DB.WithContext(ctx).First(event, "id = ?", delivery.EventId).Error; err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(&model.WebhookPayload{
+		Id:        event.Id,
+		Event:     event.Event,
+		CreatedAt: event.CreatedAt,
+		Data:      json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("X-Webhook-Event", event.Event)
+	header.Set("X-Webhook-Delivery", strconv.Itoa(delivery.Id))
+	timeout := time.Duration(global.GetConfig().Webhook.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return message.PostWebhook(ctx, webhook.URL, webhook.Secret, header, body)
+}
+
+func deliverWebhook(ctx context.Context, delivery *model.WebhookDelivery) {
+	start := time.Now()
+	resp, err := sendWebhook(ctx, delivery)
+	delivery.Attempts++
+	updates := map[string]any{
+		"attempts":        delivery.Attempts,
+		"locked_by":       "",
+		"locked_until":    0,
+		"duration":        time.Since(start).Milliseconds(),
+		"response_status": 0,
+		"response_body":   "",
+	}
+	if resp != nil {
+		updates["response_status"] = resp.StatusCode
+		updates["response_body"] = truncateResponseBody(resp.Body)
+	}
+	now := utils.GetTimestamp()
+	switch {
+	case err == nil:
+		updates["status"] = WebhookDeliveryStatusDelivered
+		updates["delivered_at"] = now
+		updates["last_error"] = ""
+	case delivery.Attempts >= global.GetConfig().Webhook.MaxAttempts:
+		updates["status"] = WebhookDeliveryStatusFailed
+		updates["last_error"] = err.Error()
+		logger.SysErrorf("webhook delivery %d of event %s failed after %d attempts: %s", delivery.Id, delivery.Event, delivery.Attempts, err.Error())
+		Notify(ctx, model.NotificationEventSystemError, "Webhook 投递失败",
+			fmt.Sprintf("事件 %s 投递到 webhook %d 在 %d 次尝试后仍然失败：%s", delivery.Event, delivery.WebhookId, delivery.Attempts, err.Error()))
+	default:
+		next := retryBackoff(global.GetConfig().Webhook.RetryBackoff, delivery.Attempts)
+		updates["status"] = WebhookDeliveryStatusQueued
+		updates["next_attempt_at"] = now + int64(next.Seconds())
+		updates["last_error"] = err.Error()
+		logger.SysWarnf("webhook delivery %d of event %s failed, retrying in %s: %s", delivery.Id, delivery.Event, next, err.Error())
+	}
+	// 服务关闭时 ctx 已经结束，投递结果仍然需要保存，否则会被重新投递
+	err = initialize.DB.WithContext(context.WithoutCancel(ctx)).Model(delivery).Where("locked_by = ?", nodeId).Updates(updates).Error
+	if err != nil {
+		logger.SysError("failed to update webhook delivery: " + err.Error())
+	}
+}
+
+func truncateResponseBody(body []byte) string {
+	if len(body) > webhookResponseMaxSize {
+		body = body[:webhookResponseMaxSize]
+	}
+	for len(body) > 0 && !utf8.Valid(body) {
+		body = body[:len(body)-1]
+	}
+	return string(body)
+}
+
+// purgeWebhookEvents 删除超过保留期限的已完成的投递记录，以及不再有投递记录的事件
+func purgeWebhookEvents(ctx context.Context) {
+	retention := global.GetConfig().Webhook.Retention
+	if retention <= 0 {
+		return
+	}
+	before := utils.GetTimestamp() - int64(retention)*24*60*60
+	result := initialize.DB.WithContext(ctx).Where("status IN ? AND created_at < ?", []int{WebhookDeliveryStatusDelivered, WebhookDeliveryStatusFailed}, before).
+		Delete(model.NewWebhookDelivery())
+	if result.Error != nil {
+		logger.SysError("failed to purge webhook deliveries: " + result.Error.Error())
+		return
+	}
+	deliveries := result.RowsAffected
+	result = initialize.DB.WithContext(ctx).Where("dispatched_at > 0 AND created_at < ?", before).
+		Where("id NOT IN (?)", initialize.DB.WithContext(ctx).Model(model.NewWebhookDelivery()).Select("event_id")).
+		Delete(model.NewWebhookEvent())
+	if result.Error != nil {
+		logger.SysError("failed to purge webhook events: " + result.Error.Error())
+		return
+	}
+	if deliveries > 0 || result.RowsAffected > 0 {
+		logger.SysLogf("purged %d old webhook deliveries and %d old webhook events", deliveries, result.RowsAffected)
+	}
+}
+
+func validateWebhook(webhook *model.Webhook) error {
+	if webhook.Name == "" {
+		return errors.New("webhook 的名称不能为空")
+	}
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("webhook 的地址无效")
+	}
+	var events []string
+	for _, event := range strings.Split(webhook.Events, ",") {
+		event = strings.TrimSpace(event)
+		if event == "" {
+			continue
+		}
+		if event != "*" && !slices.Contains(model.WebhookEvents, event) {
+			return fmt.Errorf("事件 %s 无效，可选的事件为 %s", event, strings.Join(model.WebhookEvents, "、"))
+		}
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return errors.New("webhook 至少需要订阅一个事件")
+	}
+	webhook.Events = strings.Join(events, ",")
+	if webhook.Status != WebhookStatusEnabled && webhook.Status != WebhookStatusDisabled {
+		return errors.New("webhook 的状态无效")
+	}
+	return nil
+}
+
+// GetWebhooks 返回所有 webhook，不包括签名密钥
+func GetWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	webhooks := make([]*model.Webhook, 0)
+	err := initialize.DB.WithContext(ctx).Order("id").Find(&webhooks).Error
+	for _, webhook := range webhooks {
+		webhook.Secret = redactedOptionValue
+	}
+	return webhooks, err
+}
+
+// CreateWebhook 添加 webhook，Secret 为空时生成随机的签名密钥，创建后 webhook.Secret 为实际使用的密钥
+func CreateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	if webhook.Status == 0 {
+		webhook.Status = WebhookStatusEnabled
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return err
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = "whsec_" + utils.GetUUID()
+	}
+	webhook.Id = 0
+	webhook.CreatedAt = utils.GetTimestamp()
+	webhook.UpdatedAt = webhook.CreatedAt
+	return initialize.DB.WithContext(ctx).Create(webhook).Error
+}
+
+// UpdateWebhook 修改 webhook，Secret 为空或为隐藏后的值时保留原来的签名密钥
+func UpdateWebhook(ctx context.Context, webhook *model.Webhook) error {
+	old := model.NewWebhook()
+	if err := initialize.DB.WithContext(ctx).First(old, "id = ?", webhook.Id).Error; err != nil {
+		return errors.New("webhook 不存在")
+	}
+	if webhook.Status == 0 {
+		webhook.Status = old.Status
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return err
+	}
+	fields := []string{"name", "url", "events", "status", "description", "updated_at"}
+	if webhook.Secret != "" && webhook.Secret != redactedOptionValue {
+		fields = append(fields, "secret")
+	}
+	webhook.UpdatedAt = utils.GetTimestamp()
+	return initialize.DB.WithContext(ctx).Model(old).Select(fields).Updates(webhook).Error
+}
+
+// DeleteWebhook 删除 webhook 及其投递记录
+func DeleteWebhook(ctx context.Context, id int) error {
+	return initialize.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(model.NewWebhookById(id))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("webhook 不存在")
+		}
+		return tx.Where("webhook_id = ?", id).Delete(model.NewWebhookDelivery()).Error
+	})
+}
+
+// PingWebhook 只向该 webhook 投递一个 ping 事件，用于检查地址与签名是否配置正确
+func PingWebhook(ctx context.Context, id int) error {
+	webhook := model.NewWebhook()
+	if err := initialize.DB.WithContext(ctx).First(webhook, "id = ?", id).Error; err != nil {
+		return errors.New("webhook 不存在")
+	}
+	err := initialize.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		payload, err := json.Marshal(map[string]any{"webhook_id": webhook.Id})
+		if err != nil {
+			return err
+		}
+		event := &model.WebhookEvent{
+			Event:     model.WebhookEventPing,
+			Payload:   string(payload),
+			CreatedAt: utils.GetTimestamp(),
+		}
+		event.DispatchedAt = event.CreatedAt
+		if err = tx.Create(event).Error; err != nil {
+			return err
+		}
+		return tx.Create(newWebhookDelivery(webhook.Id, event, 0)).Error
+	})
+	if err != nil {
+		return err
+	}
+	wakeWebhookQueue()
+	return nil
+}
+
+// GetWebhookDeliveries 按时间从新到旧返回 webhook 的投递记录，status 为 0 时返回所有状态的记录
+func GetWebhookDeliveries(ctx context.Context, webhookId int, status int, startIdx int, num int) ([]*model.WebhookDelivery, error) {
+	deliveries := make([]*model.WebhookDelivery, 0)
+	query := initialize.DB.WithContext(ctx).Where("webhook_id = ?", webhookId).Order("id desc").Limit(num).Offset(startIdx)
+	if status != 0 {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Find(&deliveries).Error
+	return deliveries, err
+}
+
+// RedeliverWebhook 重新投递已完成的投递记录中的事件，原记录保留，返回新的投递记录
+func RedeliverWebhook(ctx context.Context, deliveryId int) (*model.WebhookDelivery, error) {
+	original := model.NewWebhookDeliveryById(deliveryId)
+	if err := initialize.DB.WithContext(ctx).First(original).Error; err != nil {
+		return nil, errors.New("投递记录不存在")
+	}
+	if original.Status != WebhookDeliveryStatusDelivered && original.Status != WebhookDeliveryStatusFailed {
+		return nil, errors.New("该投递尚未完成")
+	}
+	event := model.NewWebhookEvent()
+	if err := initialize.DB.WithContext(ctx).First(event, "id = ?", original.EventId).Error; err != nil {
+		return nil, errors.New("事件已被清理，无法重新投递")
+	}
+	delivery := newWebhookDelivery(original.WebhookId, event, original.Id)
+	if err := initialize.DB.WithContext(ctx).Create(delivery).Error; err != nil {
+		return nil, err
+	}
+	wakeWebhookQueue()
+	return delivery, nil
+}
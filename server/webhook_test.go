@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gorm.io/gorm"
+
+	"github.com/9688101/hx-admin/config"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/model"
+	"github.com/9688101/hx-admin/utils/message"
+)
+
+type webhookReceiver struct {
+	mu       sync.Mutex
+	secret   string
+	fail     bool
+	payloads []*model.WebhookPayload
+}
+
+func (r *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("maintenance"))
+		return
+	}
+	body, _ := io.ReadAll(req.Body)
+	timestamp, _ := strconv.ParseInt(req.Header.Get("X-Webhook-Timestamp"), 10, 64)
+	if req.Header.Get("X-Webhook-Signature") != message.SignWebhook(r.secret, timestamp, body) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	payload := &model.WebhookPayload{}
+	_ = json.Unmarshal(body, payload)
+	if req.Header.Get("X-Webhook-Event") != payload.Event {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.payloads = append(r.payloads, payload)
+}
+
+func (r *webhookReceiver) events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := make([]string, len(r.payloads))
+	for i, payload := range r.payloads {
+		events[i] = payload.Event
+	}
+	return events
+}
+
+func setupWebhookTest(t *testing.T) {
+	setupTestDB(t, &config.Config{Webhook: config.Webhook{
+		MaxAttempts:  2,
+		RetryBackoff: 30,
+		Timeout:      5,
+	}})
+}
+
+func getDeliveries(webhookId int) []*model.WebhookDelivery {
+	deliveries, err := GetWebhookDeliveries(context.Background(), webhookId, 0, 0, 100)
+	So(err, ShouldBeNil)
+	return deliveries
+}
+
+func TestWebhook(t *testing.T) {
+	Convey("TestWebhook", t, func() {
+		setupWebhookTest(t)
+		ctx := context.Background()
+		receiver := &webhookReceiver{}
+		srv := httptest.NewServer(receiver)
+		defer srv.Close()
+
+		So(CreateWebhook(ctx, &model.Webhook{Name: "crm", URL: srv.URL, Events: "user.nope"}), ShouldNotBeNil)
+		webhook := &model.Webhook{Name: "crm", URL: srv.URL, Events: "user.registered, user.disabled"}
+		So(CreateWebhook(ctx, webhook), ShouldBeNil)
+		So(webhook.Secret, ShouldNotBeEmpty)
+		So(webhook.Events, ShouldEqual, "user.registered,user.disabled")
+		receiver.secret = webhook.Secret
+		webhooks, err := GetWebhooks(ctx)
+		So(err, ShouldBeNil)
+		So(webhooks[0].Secret, ShouldEqual, redactedOptionValue)
+
+		// 只投递订阅的事件，载荷经过签名
+		user := &model.User{Username: "alice", Password: "12345678", Email: "alice@example.com"}
+		So(InsertUser(ctx, user, 0), ShouldBeNil)
+		So(UpdateUser(ctx, &model.User{Id: user.Id, Role: RoleAdminUser}, false), ShouldBeNil)
+		So(UpdateUser(ctx, &model.User{Id: user.Id, Status: UserStatusDisabled}, false), ShouldBeNil)
+		processWebhookQueue(ctx)
+		So(receiver.events(), ShouldResemble, []string{model.WebhookEventUserRegistered, model.WebhookEventUserDisabled})
+		data := receiver.payloads[0].Data.(map[string]any)
+		So(data["username"], ShouldEqual, "alice")
+		So(data, ShouldNotContainKey, "password")
+		var count int64
+		initialize.DB.Model(model.NewWebhookEvent()).Where("dispatched_at = 0").Count(&count)
+		So(count, ShouldEqual, 0)
+
+		// 事务回滚时事件不会写入 outbox
+		initialize.DB.Model(model.NewWebhookEvent()).Count(&count)
+		err = initialize.DB.Transaction(func(tx *gorm.DB) error {
+			So(emitWebhookEvent(tx, model.WebhookEventUserRegistered, map[string]any{"id": 0}), ShouldBeNil)
+			return errors.New("rollback")
+		})
+		So(err, ShouldNotBeNil)
+		var after int64
+		initialize.DB.Model(model.NewWebhookEvent()).Count(&after)
+		So(after, ShouldEqual, count)
+
+		// 失败时记录响应并按退避时间重试，达到最大次数后不再重试
+		receiver.fail = true
+		So(UpdateUser(ctx, &model.User{Id: user.Id, Status: UserStatusEnabled}, false), ShouldBeNil)
+		So(UpdateUser(ctx, &model.User{Id: user.Id, Status: UserStatusDisabled}, false), ShouldBeNil)
+		processWebhookQueue(ctx)
+		delivery := getDeliveries(webhook.Id)[0]
+		So(delivery.Status, ShouldEqual, WebhookDeliveryStatusQueued)
+		So(delivery.ResponseStatus, ShouldEqual, http.StatusServiceUnavailable)
+		So(delivery.ResponseBody, ShouldEqual, "maintenance")
+		So(delivery.NextAttemptAt, ShouldBeGreaterThan, delivery.CreatedAt)
+		So(initialize.DB.Model(delivery).Update("next_attempt_at", 0).Error, ShouldBeNil)
+		processWebhookQueue(ctx)
+		delivery = getDeliveries(webhook.Id)[0]
+		So(delivery.Status, ShouldEqual, WebhookDeliveryStatusFailed)
+		So(delivery.Attempts, ShouldEqual, 2)
+
+		// 重新投递会产生新的投递记录，事件 id 不变
+		receiver.fail = false
+		_, err = RedeliverWebhook(ctx, delivery.Id)
+		So(err, ShouldBeNil)
+		processWebhookQueue(ctx)
+		deliveries := getDeliveries(webhook.Id)
+		So(deliveries, ShouldHaveLength, 4)
+		So(deliveries[0].Status, ShouldEqual, WebhookDeliveryStatusDelivered)
+		So(deliveries[0].RedeliveryOf, ShouldEqual, delivery.Id)
+		So(receiver.payloads[2].Id, ShouldEqual, delivery.EventId)
+
+		// 修改时不提交密钥则保留原来的密钥，禁用后不再分发事件
+		webhook.Secret = ""
+		webhook.Status = WebhookStatusDisabled
+		So(UpdateWebhook(ctx, webhook), ShouldBeNil)
+		So(UpdateUser(ctx, &model.User{Id: user.Id, Status: UserStatusEnabled}, false), ShouldBeNil)
+		So(UpdateUser(ctx, &model.User{Id: user.Id, Status: UserStatusDisabled}, false), ShouldBeNil)
+		processWebhookQueue(ctx)
+		So(getDeliveries(webhook.Id), ShouldHaveLength, 4)
+		webhook.Status = WebhookStatusEnabled
+		So(UpdateWebhook(ctx, webhook), ShouldBeNil)
+		So(PingWebhook(ctx, webhook.Id), ShouldBeNil)
+		processWebhookQueue(ctx)
+		So(getDeliveries(webhook.Id)[0].Status, ShouldEqual, WebhookDeliveryStatusDelivered)
+
+		So(DeleteWebhook(ctx, webhook.Id), ShouldBeNil)
+		So(getDeliveries(webhook.Id), ShouldBeEmpty)
+	})
+}
@@ -4,58 +4,88 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"sync/atomic"
 	"time"
 
+	"github.com/9688101/hx-admin/config"
 	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/core/tracing"
 	"github.com/9688101/hx-admin/global"
 )
 
-var HTTPClient *http.Client
-var ImpatientHTTPClient *http.Client
-var UserContentRequestHTTPClient *http.Client
+// 配置热加载时会整体替换客户端，请求中可能同时读取，因此通过原子指针发布
+var (
+	httpClient                   atomic.Pointer[http.Client]
+	impatientHTTPClient          atomic.Pointer[http.Client]
+	userContentRequestHTTPClient atomic.Pointer[http.Client]
+)
+
+// HTTPClient 返回转发请求使用的客户端
+func HTTPClient() *http.Client {
+	return httpClient.Load()
+}
+
+// ImpatientHTTPClient 返回超时时间为 5 秒的客户端
+func ImpatientHTTPClient() *http.Client {
+	return impatientHTTPClient.Load()
+}
+
+// UserContentRequestHTTPClient 返回获取用户提供的内容（例如图片）使用的客户端
+func UserContentRequestHTTPClient() *http.Client {
+	return userContentRequestHTTPClient.Load()
+}
 
 func Init() {
-	if global.UserContentRequestProxy != "" {
-		logger.SysLog(fmt.Sprintf("using %s as proxy to fetch user content", global.UserContentRequestProxy))
-		proxyURL, err := url.Parse(global.UserContentRequestProxy)
+	if err := Reload(&global.GetConfig().System); err != nil {
+		logger.FatalLog(err.Error())
+	}
+}
+
+// Reload 按 cfg 中的代理与超时配置重新创建 HTTP 客户端，配置无效时返回错误并保留原有客户端
+func Reload(cfg *config.System) error {
+	userContentClient := &http.Client{Transport: tracing.NewTransport(nil)}
+	if cfg.UserContentRequestProxy != "" {
+		logger.SysLog(fmt.Sprintf("using %s as proxy to fetch user content", cfg.UserContentRequestProxy))
+		proxyURL, err := url.Parse(cfg.UserContentRequestProxy)
 		if err != nil {
-			logger.FatalLog(fmt.Sprintf("USER_CONTENT_REQUEST_PROXY set but invalid: %s", global.UserContentRequestProxy))
+			return fmt.Errorf("USER_CONTENT_REQUEST_PROXY set but invalid: %s", cfg.UserContentRequestProxy)
 		}
 		transport := &http.Transport{
 			Proxy: http.ProxyURL(proxyURL),
 		}
-		UserContentRequestHTTPClient = &http.Client{
-			Transport: transport,
-			Timeout:   time.Second * time.Duration(global.UserContentRequestTimeout),
+		userContentClient = &http.Client{
+			Transport: tracing.NewTransport(transport),
+			Timeout:   time.Second * time.Duration(cfg.UserContentRequestTimeout),
 		}
-	} else {
-		UserContentRequestHTTPClient = &http.Client{}
 	}
 	var transport http.RoundTripper
-	if global.RelayProxy != "" {
-		logger.SysLog(fmt.Sprintf("using %s as api relay proxy", global.RelayProxy))
-		proxyURL, err := url.Parse(global.RelayProxy)
+	if cfg.RelayProxy != "" {
+		logger.SysLog(fmt.Sprintf("using %s as api relay proxy", cfg.RelayProxy))
+		proxyURL, err := url.Parse(cfg.RelayProxy)
 		if err != nil {
-			logger.FatalLog(fmt.Sprintf("USER_CONTENT_REQUEST_PROXY set but invalid: %s", global.UserContentRequestProxy))
+			return fmt.Errorf("RELAY_PROXY set but invalid: %s", cfg.RelayProxy)
 		}
 		transport = &http.Transport{
 			Proxy: http.ProxyURL(proxyURL),
 		}
 	}
 
-	if global.RelayTimeout == 0 {
-		HTTPClient = &http.Client{
+	userContentRequestHTTPClient.Store(userContentClient)
+	transport = tracing.NewTransport(transport)
+	if cfg.RelayTimeout == 0 {
+		httpClient.Store(&http.Client{
 			Transport: transport,
-		}
+		})
 	} else {
-		HTTPClient = &http.Client{
-			Timeout:   time.Duration(global.RelayTimeout) * time.Second,
+		httpClient.Store(&http.Client{
+			Timeout:   time.Duration(cfg.RelayTimeout) * time.Second,
 			Transport: transport,
-		}
+		})
 	}
 
-	ImpatientHTTPClient = &http.Client{
+	impatientHTTPClient.Store(&http.Client{
 		Timeout:   5 * time.Second,
 		Transport: transport,
-	}
+	})
+	return nil
 }
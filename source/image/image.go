@@ -20,7 +20,7 @@ import (
 var dataURLPattern = regexp.MustCompile(`data:image/([^;]+);base64,(.*)`)
 
 func IsImageUrl(url string) (bool, error) {
-	resp, err := client.UserContentRequestHTTPClient.Head(url)
+	resp, err := client.UserContentRequestHTTPClient().Head(url)
 	if err != nil {
 		return false, err
 	}
@@ -35,7 +35,7 @@ func GetImageSizeFromUrl(url string) (width int, height int, err error) {
 	if !isImage {
 		return
 	}
-	resp, err := client.UserContentRequestHTTPClient.Get(url)
+	resp, err := client.UserContentRequestHTTPClient().Get(url)
 	if err != nil {
 		return
 	}
@@ -7,9 +7,13 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/9688101/hx-admin/config"
+	"github.com/9688101/hx-admin/core"
 	"github.com/9688101/hx-admin/core/logger"
 	"github.com/9688101/hx-admin/global"
 	"github.com/9688101/hx-admin/initialize"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -17,13 +21,16 @@ var (
 	PrintVersion = flag.Bool("version", false, "print version and exit")
 	PrintHelp    = flag.Bool("help", false, "print help and exit")
 	LogDir       = flag.String("log-dir", "./logs", "specify the log directory")
+	ConfigFile   = flag.String("c", "", "specify the config file (yaml)")
+	PrintConfig  = flag.Bool("print-config", false, "print the effective config with secrets redacted and exit")
 )
 
 func printHelp() {
 	fmt.Println("One API " + global.Version + " - All in one API service for OpenAI API.")
 	fmt.Println("Copyright (C) 2023 JustSong. All rights reserved.")
 	fmt.Println("GitHub: https://github.com/songquanpeng/one-api")
-	fmt.Println("Usage: one-api [--port <port>] [--log-dir <log directory>] [--version] [--help]")
+	fmt.Println("Usage: one-api [-c <config file>] [--port <port>] [--log-dir <log directory>] [--print-config] [--version] [--help] [<command> [args]]")
+	fmt.Println("Run `one-api help` to list the commands.")
 }
 
 func Init() {
@@ -39,28 +46,105 @@ func Init() {
 		os.Exit(0)
 	}
 
-	if os.Getenv("SESSION_SECRET") != "" {
-		if os.Getenv("SESSION_SECRET") == "random_string" {
-			logger.SysError("SESSION_SECRET is set to an example value, please change it to a random string.")
-		} else {
-			global.SessionSecret = os.Getenv("SESSION_SECRET")
-		}
+	v, cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal(err)
 	}
-	if os.Getenv("SQLITE_PATH") != "" {
-		initialize.SQLitePath = os.Getenv("SQLITE_PATH")
+	global.SetConfig(cfg)
+
+	if *PrintConfig {
+		data, err := yaml.Marshal(cfg.Redacted())
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(string(data))
+		os.Exit(0)
 	}
-	if *LogDir != "" {
-		var err error
-		*LogDir, err = filepath.Abs(*LogDir)
+
+	applyConfig(cfg)
+
+	if logDir := cfg.System.LogDir; logDir != "" {
+		logDir, err = filepath.Abs(logDir)
 		if err != nil {
 			log.Fatal(err)
 		}
-		if _, err := os.Stat(*LogDir); os.IsNotExist(err) {
-			err = os.Mkdir(*LogDir, 0777)
+		if _, err := os.Stat(logDir); os.IsNotExist(err) {
+			err = os.Mkdir(logDir, 0777)
 			if err != nil {
 				log.Fatal(err)
 			}
 		}
-		logger.LogDir = *LogDir
+		logger.LogDir = logDir
+	}
+
+	if *ConfigFile != "" && cfg.System.WatchConfig {
+		watchConfig(v)
+	}
+}
+
+// loadConfig 读取配置，并用显式指定的命令行参数覆盖
+func loadConfig() (*viper.Viper, *config.Config, error) {
+	v, cfg, err := core.Viper(*ConfigFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.System.Port = *Port
+		case "log-dir":
+			cfg.System.LogDir = *LogDir
+		}
+	})
+	return v, cfg, nil
+}
+
+// applyConfig 将启动配置赋值给 global 中对应的变量
+func applyConfig(cfg *config.Config) {
+	if cfg.System.SessionSecret != "" {
+		if cfg.System.SessionSecret == "random_string" {
+			logger.SysError("SESSION_SECRET is set to an example value, please change it to a random string.")
+		} else {
+			global.SessionSecret = cfg.System.SessionSecret
+		}
+	}
+	global.IsMasterNode = cfg.System.NodeType != "slave"
+	if cfg.System.SyncFrequency > 0 {
+		global.SyncFrequency = cfg.System.SyncFrequency
+	}
+	global.MemoryCacheEnabled = cfg.System.MemoryCacheEnabled
+	global.DebugSQLEnabled = cfg.System.DebugSQL
+	global.Theme = cfg.System.Theme
+	global.OnlyOneLogFile = cfg.System.OnlyOneLogFile
+	logger.Format = cfg.System.LogFormat
+	logger.MaxSize = cfg.System.LogMaxSize
+	logger.MaxAge = cfg.System.LogMaxAge
+	logger.MaxBackups = cfg.System.LogMaxBackups
+	logger.Compress = cfg.System.LogCompress
+
+	// 作为初始值，数据库中保存的系统设置会覆盖它们
+	global.SMTPServer = cfg.SMTP.Server
+	global.SMTPPort = cfg.SMTP.Port
+	global.SMTPAccount = cfg.SMTP.Account
+	global.SMTPFrom = cfg.SMTP.From
+	global.SMTPToken = cfg.SMTP.Token
+	global.SMTPSecurity = cfg.SMTP.Security
+	global.SMTPInsecureSkipVerify = cfg.SMTP.InsecureSkipVerify
+
+	initialize.SQLitePath = cfg.Database.SQLitePath
+	initialize.SQLiteBusyTimeout = cfg.Database.SQLiteBusyTimeout
+
+	applyReloadableConfig(cfg)
+}
+
+// applyReloadableConfig 赋值可以在运行时重新加载的配置，见 copyReloadable
+func applyReloadableConfig(cfg *config.Config) {
+	global.DebugEnabled.Store(cfg.System.Debug)
+	if err := logger.SetLevel(cfg.System.LogLevel); err != nil {
+		logger.SysError(err.Error())
 	}
+	global.RelayProxy = cfg.System.RelayProxy
+	global.RelayTimeout = cfg.System.RelayTimeout
+	global.UserContentRequestProxy = cfg.System.UserContentRequestProxy
+	global.UserContentRequestTimeout = cfg.System.UserContentRequestTimeout
 }
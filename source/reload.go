@@ -0,0 +1,178 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+
+	"github.com/9688101/hx-admin/config"
+	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/initialize"
+	"github.com/9688101/hx-admin/source/client"
+)
+
+var reloadLock sync.Mutex
+
+// copyReloadable 将可以在运行时生效的配置从 src 复制到 dst，其余配置修改后需要重启
+func copyReloadable(dst *config.Config, src *config.Config) {
+	dst.RateLimit = src.RateLimit
+	dst.System.Debug = src.System.Debug
+	dst.System.LogLevel = src.System.LogLevel
+	dst.System.CorsAllowedOrigins = src.System.CorsAllowedOrigins
+	dst.System.ShutdownTimeout = src.System.ShutdownTimeout
+	dst.System.ShutdownDelay = src.System.ShutdownDelay
+	dst.System.RelayProxy = src.System.RelayProxy
+	dst.System.RelayTimeout = src.System.RelayTimeout
+	dst.System.UserContentRequestProxy = src.System.UserContentRequestProxy
+	dst.System.UserContentRequestTimeout = src.System.UserContentRequestTimeout
+	dst.Database.MaxIdleConns = src.Database.MaxIdleConns
+	dst.Database.MaxOpenConns = src.Database.MaxOpenConns
+	dst.Database.MaxLifetime = src.Database.MaxLifetime
+	dst.LogDatabase.MaxIdleConns = src.LogDatabase.MaxIdleConns
+	dst.LogDatabase.MaxOpenConns = src.LogDatabase.MaxOpenConns
+	dst.LogDatabase.MaxLifetime = src.LogDatabase.MaxLifetime
+}
+
+// Reload 重新读取配置，应用可以在运行时生效的部分，并记录哪些配置项需要重启才能生效
+func Reload() error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	_, loaded, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	current := global.GetConfig()
+	next := *current
+	copyReloadable(&next, loaded)
+
+	changed, err := diffConfig(current, loaded)
+	if err != nil {
+		return err
+	}
+	applied, err := diffConfig(current, &next)
+	if err != nil {
+		return err
+	}
+	if len(changed) == 0 {
+		logger.SysLog("config reloaded, nothing changed")
+		return nil
+	}
+	var restartRequired []string
+	for _, key := range changed {
+		if !slices.Contains(applied, key) {
+			restartRequired = append(restartRequired, key)
+		}
+	}
+
+	// 先创建新的 HTTP 客户端，代理配置无效时不应用任何修改
+	if err = client.Reload(&next.System); err != nil {
+		return err
+	}
+
+	// 先记录日志，避免新的日志级别过滤掉它们
+	if len(applied) > 0 {
+		logger.SysLogf("config reloaded, applied: %s", strings.Join(applied, ", "))
+	}
+	if len(restartRequired) > 0 {
+		logger.SysWarnf("config changed but requires a restart to take effect: %s", strings.Join(restartRequired, ", "))
+	}
+
+	global.SetConfig(&next)
+	applyReloadableConfig(&next)
+	initialize.ResizeDBConns()
+	return nil
+}
+
+// WatchReload 收到 SIGHUP 信号时重新加载配置，直到 ctx 结束
+func WatchReload(ctx context.Context) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+			logger.SysLog("received SIGHUP, reloading config")
+			if err := Reload(); err != nil {
+				logger.SysError("failed to reload config: " + err.Error())
+			}
+		}
+	}
+}
+
+// watchConfig 配置文件修改后自动重新加载
+func watchConfig(v *viper.Viper) {
+	v.OnConfigChange(func(e fsnotify.Event) {
+		logger.SysLog(fmt.Sprintf("config file %s changed, reloading config", e.Name))
+		if err := Reload(); err != nil {
+			logger.SysError("failed to reload config: " + err.Error())
+		}
+	})
+	v.WatchConfig()
+}
+
+// diffConfig 返回两份配置中值不同的配置项，键名与配置文件一致，例如 rate-limit.global-api-num
+func diffConfig(a *config.Config, b *config.Config) ([]string, error) {
+	flatA, err := flattenConfig(a)
+	if err != nil {
+		return nil, err
+	}
+	flatB, err := flattenConfig(b)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for key, value := range flatA {
+		if !reflect.DeepEqual(value, flatB[key]) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range flatB {
+		if _, ok := flatA[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func flattenConfig(cfg *config.Config) (map[string]any, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	if err = yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	flat := make(map[string]any)
+	flattenInto(flat, "", raw)
+	return flat, nil
+}
+
+func flattenInto(flat map[string]any, prefix string, m map[string]any) {
+	for key, value := range m {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if sub, ok := value.(map[string]any); ok {
+			flattenInto(flat, key, sub)
+			continue
+		}
+		flat[key] = value
+	}
+}
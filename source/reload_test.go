@@ -0,0 +1,65 @@
+package source
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/9688101/hx-admin/core"
+	"github.com/9688101/hx-admin/global"
+)
+
+func TestReloadableConfig(t *testing.T) {
+	Convey("TestReloadableConfig", t, func() {
+		_, current, err := core.Viper("")
+		So(err, ShouldBeNil)
+
+		loaded := *current
+		loaded.RateLimit.GlobalApiNum = current.RateLimit.GlobalApiNum + 1
+		loaded.System.RelayTimeout = 30
+		loaded.System.Port = current.System.Port + 1
+		loaded.Database.DSN = "changed.db"
+		changed, err := diffConfig(current, &loaded)
+		So(err, ShouldBeNil)
+		So(changed, ShouldResemble, []string{"database.dsn", "rate-limit.global-api-num", "system.port", "system.relay-timeout"})
+
+		// 需要重启的配置项不会被复制
+		next := *current
+		copyReloadable(&next, &loaded)
+		applied, err := diffConfig(current, &next)
+		So(err, ShouldBeNil)
+		So(applied, ShouldResemble, []string{"rate-limit.global-api-num", "system.relay-timeout"})
+		So(next.System.Port, ShouldEqual, current.System.Port)
+		So(next.Database.DSN, ShouldEqual, current.Database.DSN)
+	})
+}
+
+func TestReload(t *testing.T) {
+	Convey("TestReload", t, func() {
+		oldConfigFile, oldConfig := *ConfigFile, global.GetConfig()
+		t.Cleanup(func() {
+			*ConfigFile = oldConfigFile
+			global.SetConfig(oldConfig)
+		})
+		*ConfigFile = filepath.Join(t.TempDir(), "config.yaml")
+		writeConfig := func(content string) {
+			So(os.WriteFile(*ConfigFile, []byte(content), 0o600), ShouldBeNil)
+		}
+		writeConfig("system:\n  port: 3001\n")
+		_, current, err := loadConfig()
+		So(err, ShouldBeNil)
+		global.SetConfig(current)
+
+		// 代理地址无效时不应用任何修改
+		writeConfig("system:\n  port: 3001\n  relay-proxy: \"://invalid\"\nrate-limit:\n  global-api-num: 1\n")
+		So(Reload(), ShouldNotBeNil)
+		So(global.GetConfig(), ShouldEqual, current)
+
+		writeConfig("system:\n  port: 3002\nrate-limit:\n  global-api-num: 1\n")
+		So(Reload(), ShouldBeNil)
+		So(global.GetConfig().RateLimit.GlobalApiNum, ShouldEqual, 1)
+		So(global.GetConfig().System.Port, ShouldEqual, 3001)
+	})
+}
@@ -1,29 +1,59 @@
 package utils
 
 import (
-	"fmt"
+	"sort"
 	"sync"
+	"time"
 )
 
+// blackList 当前节点内存中的封禁列表，用户 id 到封禁的过期时间（秒级时间戳），0 表示永久封禁。
+// 多节点时由 server 通过 Redis 同步，这里只负责本地查询。
 var blackList sync.Map
 
-func init() {
-	blackList = sync.Map{}
-}
-
-func userId2Key(id int) string {
-	return fmt.Sprintf("userid_%d", id)
+func BanUser(id int) {
+	blackList.Store(id, int64(0))
 }
 
-func BanUser(id int) {
-	blackList.Store(userId2Key(id), true)
+// BanUserUntil bans the user until expiresAt, 0 means permanent
+func BanUserUntil(id int, expiresAt int64) {
+	blackList.Store(id, expiresAt)
 }
 
 func UnbanUser(id int) {
-	blackList.Delete(userId2Key(id))
+	blackList.Delete(id)
 }
 
 func IsUserBanned(id int) bool {
-	_, ok := blackList.Load(userId2Key(id))
-	return ok
+	value, ok := blackList.Load(id)
+	if !ok {
+		return false
+	}
+	expiresAt := value.(int64)
+	return expiresAt == 0 || expiresAt > time.Now().Unix()
+}
+
+// ResetBannedUsers replaces the whole list, bans maps user ids to expiry timestamps
+func ResetBannedUsers(bans map[int]int64) {
+	blackList.Range(func(key, value any) bool {
+		if _, ok := bans[key.(int)]; !ok {
+			blackList.Delete(key)
+		}
+		return true
+	})
+	for id, expiresAt := range bans {
+		blackList.Store(id, expiresAt)
+	}
+}
+
+// BannedUserIds returns the ids of all banned users, for diagnostics only
+func BannedUserIds() []int {
+	var ids []int
+	blackList.Range(func(key, value any) bool {
+		if IsUserBanned(key.(int)) {
+			ids = append(ids, key.(int))
+		}
+		return true
+	})
+	sort.Ints(ids)
+	return ids
 }
@@ -20,5 +20,6 @@ const (
 	// BaseURL           = "base_url"
 	// AvailableModels   = "available_models"
 	KeyRequestBody = "key_request_body"
+	LoginSucceeded = "login_succeeded" // 由 controller.SetupLogin 设置，用于统计登录结果
 	// SystemPrompt = "system_prompt"
 )
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -56,9 +57,36 @@ type CustomEvent struct {
 
 func encode(writer io.Writer, event CustomEvent) error {
 	w := checkWriter(writer)
+	writeId(w, event.Id)
+	writeEvent(w, event.Event)
+	writeRetry(w, event.Retry)
 	return writeData(w, event.Data)
 }
 
+func writeId(w stringWriter, id string) {
+	if len(id) > 0 {
+		w.writeString("id:")
+		fieldReplacer.WriteString(w, id)
+		w.writeString("\n")
+	}
+}
+
+func writeEvent(w stringWriter, event string) {
+	if len(event) > 0 {
+		w.writeString("event:")
+		fieldReplacer.WriteString(w, event)
+		w.writeString("\n")
+	}
+}
+
+func writeRetry(w stringWriter, retry uint) {
+	if retry > 0 {
+		w.writeString("retry:")
+		w.writeString(strconv.FormatUint(uint64(retry), 10))
+		w.writeString("\n")
+	}
+}
+
 func writeData(w stringWriter, data interface{}) error {
 	dataReplacer.WriteString(w, fmt.Sprint(data))
 	if strings.HasPrefix(data.(string), "data") {
@@ -12,6 +12,8 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/9688101/hx-admin/utils/ctxkey"
 )
 
 const (
@@ -123,6 +125,17 @@ func GetRequestID(ctx context.Context) string {
 	return rawRequestId.(string)
 }
 
+// SetUserID 将登录用户的 ID 放入请求上下文，日志会带上它
+func SetUserID(ctx context.Context, id int) context.Context {
+	return context.WithValue(ctx, ctxkey.Id, id)
+}
+
+// GetUserID 返回上下文中登录用户的 ID，gin.Context 中由鉴权中间件设置，未登录时返回 0
+func GetUserID(ctx context.Context) int {
+	id, _ := ctx.Value(ctxkey.Id).(int)
+	return id
+}
+
 func GetResponseID(c *gin.Context) string {
 	logID := c.GetString(RequestIdKey)
 	return fmt.Sprintf("chatcmpl-%s", logID)
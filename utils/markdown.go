@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// markdownSafeSchemes 链接与图片允许使用的协议，没有协议的相对地址总是允许
+var markdownSafeSchemes = []string{"http", "https", "mailto"}
+
+var markdownSafeAutolink = regexp.MustCompile(`^<(?:https?://|mailto:)[^\s<>]*>`)
+
+// SanitizeMarkdown 清理用户提交的 Markdown，使前端用 marked 等不转义 HTML 的渲染器渲染时也是安全的：
+// 除了 http、https、mailto 的自动链接，所有的 < 都会被转义，原始 HTML 因此显示为文本；链接、图片与链接定义中
+// http、https、mailto 以外协议的地址会被替换为 #。
+// 不区分代码块与行内代码，避免与渲染器对代码块的判断不一致，代价是代码中的 < 会显示为 &lt;
+func SanitizeMarkdown(s string) string {
+	return sanitizeMarkdownText(strings.ReplaceAll(s, "\r\n", "\n"))
+}
+
+func sanitizeMarkdownText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s) && s[i+1] == '<':
+			// 转义的 < 与 &lt; 显示相同
+			b.WriteString("&lt;")
+			i += 2
+		case c == '\\' && i+1 < len(s) && isASCIIPunct(s[i+1]):
+			b.WriteString(s[i : i+2])
+			i += 2
+		case c == '<':
+			if autolink := markdownSafeAutolink.FindString(s[i:]); autolink != "" {
+				b.WriteString(autolink)
+				i += len(autolink)
+				continue
+			}
+			b.WriteString("&lt;")
+			i++
+		case c == ']' && i+1 < len(s) && (s[i+1] == '(' || s[i+1] == ':'):
+			b.WriteString(s[i : i+2])
+			i += 2
+			i = writeMarkdownDestination(&b, s, i)
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// writeMarkdownDestination 写入 ]( 或 ]: 之后的链接地址，不安全的地址替换为 #，返回地址之后的位置
+func writeMarkdownDestination(b *strings.Builder, s string, i int) int {
+	newline := false
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || (s[i] == '\n' && !newline)) {
+		newline = newline || s[i] == '\n'
+		b.WriteByte(s[i])
+		i++
+	}
+	start := i
+	if i < len(s) && s[i] == '<' {
+		for i < len(s) && s[i] != '>' && s[i] != '\n' {
+			i++
+		}
+		if i < len(s) && s[i] == '>' {
+			i++
+		}
+	} else {
+		depth := 0
+		for ; i < len(s) && s[i] > ' '; i++ {
+			if s[i] == '(' {
+				depth++
+			} else if s[i] == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+		}
+	}
+	destination := s[start:i]
+	if !isSafeMarkdownURL(strings.TrimSuffix(strings.TrimPrefix(destination, "<"), ">")) {
+		b.WriteString("#")
+		return i
+	}
+	b.WriteString(strings.ReplaceAll(destination, "<", "&lt;"))
+	return i
+}
+
+// isSafeMarkdownURL 按浏览器的方式去掉实体、转义与空白后检查地址的协议
+func isSafeMarkdownURL(u string) bool {
+	u = html.UnescapeString(u)
+	u = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '\\' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, u)
+	colon := strings.IndexByte(u, ':')
+	if colon < 0 || strings.ContainsAny(u[:colon], "/?#") {
+		return true
+	}
+	for _, scheme := range markdownSafeSchemes {
+		if strings.EqualFold(u[:colon], scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSanitizeMarkdown(t *testing.T) {
+	Convey("TestSanitizeMarkdown", t, func() {
+		// 普通的 Markdown 保持不变
+		text := "# 维护通知\n\n- **时间**：今晚 10 点\n- 详情见 [公告](https://example.com/a_(b)) 与 <https://example.com>\n\n> 1 < 2"
+		So(SanitizeMarkdown(text), ShouldEqual, "# 维护通知\n\n- **时间**：今晚 10 点\n- 详情见 [公告](https://example.com/a_(b)) 与 <https://example.com>\n\n> 1 &lt; 2")
+
+		// 原始 HTML 被转义，代码块与行内代码中的 < 同样被转义
+		So(SanitizeMarkdown(`<img src=x onerror=alert(1)>`), ShouldEqual, `&lt;img src=x onerror=alert(1)>`)
+		So(SanitizeMarkdown("use `<div>` here"), ShouldEqual, "use `&lt;div>` here")
+		So(SanitizeMarkdown("```html\n<div>\n```\n<b>"), ShouldEqual, "```html\n&lt;div>\n```\n&lt;b>")
+		So(SanitizeMarkdown("\\<b> \\`<b>`"), ShouldEqual, "&lt;b> \\`&lt;b>`")
+		So(SanitizeMarkdown("| `a | <b>` |"), ShouldEqual, "| `a | &lt;b>` |")
+
+		// 缩进与嵌套的围栏不会让后面的 HTML 被当作代码保留下来
+		So(SanitizeMarkdown("  ```\n\n```\n<img src=x onerror=alert(1)>\n```"), ShouldEqual, "  ```\n\n```\n&lt;img src=x onerror=alert(1)>\n```")
+		So(SanitizeMarkdown("- item\n\n  ```\n  <b>\n  ```\n<b>"), ShouldEqual, "- item\n\n  ```\n  &lt;b>\n  ```\n&lt;b>")
+		So(SanitizeMarkdown("````\n```\n<b>\n```\n````\n<i>"), ShouldEqual, "````\n```\n&lt;b>\n```\n````\n&lt;i>")
+		So(SanitizeMarkdown("> ```\n> <b>\n\n<i>"), ShouldEqual, "> ```\n> &lt;b>\n\n&lt;i>")
+
+		// 不安全的链接地址被替换
+		So(SanitizeMarkdown("[a](javascript:alert(1))"), ShouldEqual, "[a](#)")
+		So(SanitizeMarkdown("![a]( JaVa&#115;cript:alert(1) \"t\")"), ShouldEqual, "![a]( # \"t\")")
+		So(SanitizeMarkdown("[a](<java\\script:alert(1)>)"), ShouldEqual, "[a](#)")
+		So(SanitizeMarkdown("[a]:\n  data:text/html,x"), ShouldEqual, "[a]:\n  #")
+		So(SanitizeMarkdown("<javascript:alert(1)>"), ShouldEqual, "&lt;javascript:alert(1)>")
+		So(SanitizeMarkdown("[a](/path?x=1:2) [b](mailto:a@example.com)"), ShouldEqual, "[a](/path?x=1:2) [b](mailto:a@example.com)")
+	})
+}
@@ -0,0 +1,140 @@
+package message
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// dkimSignedHeaders 参与 DKIM 签名的邮件头，邮件中不存在的头也会被签名，防止之后被添加
+var dkimSignedHeaders = []string{"From", "To", "Cc", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type", "Content-Transfer-Encoding"}
+
+type dkimSigner struct {
+	domain    string
+	selector  string
+	key       crypto.Signer
+	algorithm string
+}
+
+var dkim atomic.Pointer[dkimSigner]
+
+func getDKIMSigner() *dkimSigner {
+	return dkim.Load()
+}
+
+// InitDKIM 加载 DKIM 签名使用的私钥，支持 PKCS#1、PKCS#8 格式的 RSA 私钥与 PKCS#8 格式的 Ed25519 私钥。
+// domain 为空时不签名。
+func InitDKIM(domain string, selector string, keyFile string) error {
+	if domain == "" {
+		dkim.Store(nil)
+		return nil
+	}
+	if selector == "" || keyFile == "" {
+		return errors.New("DKIM selector and private key file are required")
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return err
+	}
+	key, err := parseDKIMKey(data)
+	if err != nil {
+		return err
+	}
+	signer := &dkimSigner{domain: domain, selector: selector, key: key, algorithm: "rsa-sha256"}
+	if _, ok := key.(ed25519.PrivateKey); ok {
+		signer.algorithm = "ed25519-sha256"
+	}
+	dkim.Store(signer)
+	return nil
+}
+
+func parseDKIMKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found in DKIM private key file")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DKIM private key: %w", err)
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case ed25519.PrivateKey:
+		return key, nil
+	}
+	return nil, errors.New("DKIM private key must be RSA or Ed25519")
+}
+
+// sign 使用 relaxed/relaxed 规范化算法计算 DKIM-Signature 的值，RFC 6376
+func (s *dkimSigner) sign(headers []header, body []byte) (string, error) {
+	bodyHash := sha256.Sum256(dkimRelaxedBody(body))
+	value := fmt.Sprintf("v=1; a=%s; c=relaxed/relaxed; d=%s; s=%s; t=%s; h=%s; bh=%s; b=",
+		s.algorithm, s.domain, s.selector, strconv.FormatInt(time.Now().Unix(), 10),
+		strings.Join(dkimSignedHeaders, ":"), base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	hash := sha256.New()
+	for _, name := range dkimSignedHeaders {
+		for _, h := range headers {
+			if strings.EqualFold(h.name, name) {
+				hash.Write([]byte(dkimRelaxedHeader(h.name, h.value) + "\r\n"))
+				break
+			}
+		}
+	}
+	hash.Write([]byte(dkimRelaxedHeader("DKIM-Signature", value)))
+	digest := hash.Sum(nil)
+
+	var signature []byte
+	var err error
+	if _, ok := s.key.(ed25519.PrivateKey); ok {
+		// RFC 8463：Ed25519 对 SHA-256 的摘要签名
+		signature, err = s.key.Sign(rand.Reader, digest, crypto.Hash(0))
+	} else {
+		signature, err = s.key.Sign(rand.Reader, digest, crypto.SHA256)
+	}
+	if err != nil {
+		return "", err
+	}
+	return value + base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// dkimRelaxedHeader 名称转为小写，展开折行，连续的空白替换为一个空格，去掉值首尾的空白
+func dkimRelaxedHeader(name string, value string) string {
+	value = strings.ReplaceAll(value, "\r\n", "")
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + strings.Join(strings.Fields(value), " ")
+}
+
+// dkimRelaxedBody 去掉行尾的空白，行内连续的空白替换为一个空格，去掉末尾的空行
+func dkimRelaxedBody(body []byte) []byte {
+	lines := strings.Split(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		for strings.Contains(line, "  ") || strings.Contains(line, "\t") {
+			line = strings.ReplaceAll(strings.ReplaceAll(line, "\t", " "), "  ", " ")
+		}
+		lines[i] = line
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
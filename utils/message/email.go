@@ -1,111 +1,163 @@
 package message
 
 import (
-	"crypto/rand"
 	"crypto/tls"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"net"
+	"net/mail"
 	"net/smtp"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/9688101/hx-admin/core/logger"
+	"github.com/9688101/hx-admin/core/metrics"
 	"github.com/9688101/hx-admin/global"
 )
 
+// SMTP 连接的加密方式
+const (
+	SMTPSecurityAuto     = "auto"     // 465 端口使用 TLS，其他端口在服务器支持时使用 STARTTLS
+	SMTPSecurityTLS      = "tls"      // 连接时即使用 TLS
+	SMTPSecuritySTARTTLS = "starttls" // 必须使用 STARTTLS，服务器不支持时发送失败
+	SMTPSecurityNone     = "none"     // 不加密，此时只能向本机的 SMTP 服务器认证
+)
+
+// smtpTimeout 一封邮件从连接到发送完成的最长时间，避免卡住发件队列
+const smtpTimeout = time.Minute
+
 func shouldAuth() bool {
 	return global.SMTPAccount != "" || global.SMTPToken != ""
 }
 
 func SendEmail(subject string, receiver string, content string) error {
-	if receiver == "" {
-		return fmt.Errorf("receiver is empty")
+	return SendEmailWithText(subject, receiver, content, "")
+}
+
+// SendEmailWithText 发送 HTML 邮件，text 不为空时作为纯文本的备选内容，邮件客户端可以选择显示哪一种
+func SendEmailWithText(subject string, receiver string, content string, text string) error {
+	return Send(&Message{
+		To:      []string{receiver},
+		Subject: subject,
+		HTML:    content,
+		Text:    text,
+	})
+}
+
+// Send 通过系统设置中的 SMTP 服务器发送邮件，msg.From 为空时使用 SMTPFrom
+func Send(msg *Message) error {
+	err := send(msg)
+	metrics.ObserveEmail(err == nil)
+	return err
+}
+
+func send(msg *Message) error {
+	if msg.From.Address == "" {
+		from := global.SMTPFrom
+		if from == "" { // for compatibility
+			from = global.SMTPAccount
+		}
+		msg.From = mail.Address{Name: global.SystemName, Address: from}
 	}
-	if global.SMTPFrom == "" { // for compatibility
-		global.SMTPFrom = global.SMTPAccount
+	recipients, err := msg.Recipients()
+	if err != nil {
+		return err
 	}
-	encodedSubject := fmt.Sprintf("=?UTF-8?B?%s?=", base64.StdEncoding.EncodeToString([]byte(subject)))
-
-	// Extract domain from SMTPFrom
-	parts := strings.Split(global.SMTPFrom, "@")
-	var domain string
-	if len(parts) > 1 {
-		domain = parts[1]
-	}
-	// Generate a unique Message-ID
-	buf := make([]byte, 16)
-	_, err := rand.Read(buf)
+	data, err := msg.Bytes()
 	if err != nil {
 		return err
 	}
-	messageId := fmt.Sprintf("<%x@%s>", buf, domain)
-
-	mail := []byte(fmt.Sprintf("To: %s\r\n"+
-		"From: %s<%s>\r\n"+
-		"Subject: %s\r\n"+
-		"Message-ID: %s\r\n"+ // add Message-ID header to avoid being treated as spam, RFC 5322
-		"Date: %s\r\n"+
-		"Content-Type: text/html; charset=UTF-8\r\n\r\n%s\r\n",
-		receiver, global.SystemName, global.SMTPFrom, encodedSubject, messageId, time.Now().Format(time.RFC1123Z), content))
 
-	auth := smtp.PlainAuth("", global.SMTPAccount, global.SMTPToken, global.SMTPServer)
-	addr := fmt.Sprintf("%s:%d", global.SMTPServer, global.SMTPPort)
-	to := strings.Split(receiver, ";")
-
-	if global.SMTPPort == 465 || !shouldAuth() {
-		// need advanced client
-		var conn net.Conn
-		var err error
-		if global.SMTPPort == 465 {
-			tlsglobal := &tls.Config{
-				InsecureSkipVerify: true,
-				ServerName:         global.SMTPServer,
-			}
-			conn, err = tls.Dial("tcp", fmt.Sprintf("%s:%d", global.SMTPServer, global.SMTPPort), tlsglobal)
-		} else {
-			conn, err = net.Dial("tcp", fmt.Sprintf("%s:%d", global.SMTPServer, global.SMTPPort))
-		}
-		if err != nil {
-			return err
-		}
-		client, err := smtp.NewClient(conn, global.SMTPServer)
-		if err != nil {
-			return err
-		}
-		defer client.Close()
-		if shouldAuth() {
-			if err = client.Auth(auth); err != nil {
-				return err
-			}
-		}
-		if err = client.Mail(global.SMTPFrom); err != nil {
+	client, err := dialSMTP()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err = client.Mail(msg.From.Address); err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		if err = client.Rcpt(recipient); err != nil {
 			return err
 		}
-		receiverEmails := strings.Split(receiver, ";")
-		for _, receiver := range receiverEmails {
-			if err = client.Rcpt(receiver); err != nil {
-				return err
-			}
-		}
-		w, err := client.Data()
-		if err != nil {
-			return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(data); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	// 邮件已经被服务器接受，部分提供商在 QUIT 时返回 short response 等错误，不影响发送结果
+	if err = client.Quit(); err != nil {
+		logger.SysWarnf("failed to quit SMTP session, the email has been sent: %s", err.Error())
+	}
+	return nil
+}
+
+// dialSMTP 连接 SMTP 服务器，按配置建立 TLS 并完成认证。证书默认会被校验，只有配置了 SMTP_INSECURE_SKIP_VERIFY 时跳过。
+func dialSMTP() (*smtp.Client, error) {
+	if global.SMTPServer == "" {
+		return nil, errors.New("SMTP server is not configured")
+	}
+	addr := net.JoinHostPort(global.SMTPServer, strconv.Itoa(global.SMTPPort))
+	tlsConfig := &tls.Config{
+		ServerName:         global.SMTPServer,
+		InsecureSkipVerify: global.SMTPInsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	security := global.SMTPSecurity
+	switch security {
+	case "", SMTPSecurityAuto:
+		security = SMTPSecurityAuto
+		if global.SMTPPort == 465 {
+			security = SMTPSecurityTLS
 		}
-		_, err = w.Write(mail)
-		if err != nil {
-			return err
+	case SMTPSecurityTLS, SMTPSecuritySTARTTLS, SMTPSecurityNone:
+	default:
+		return nil, fmt.Errorf("unknown SMTP security %q", security)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	if security == SMTPSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(smtpTimeout))
+	client, err := smtp.NewClient(conn, global.SMTPServer)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if security == SMTPSecurityAuto || security == SMTPSecuritySTARTTLS {
+		ok, _ := client.Extension("STARTTLS")
+		if ok {
+			err = client.StartTLS(tlsConfig)
+		} else if security == SMTPSecuritySTARTTLS {
+			err = errors.New("SMTP server does not support STARTTLS")
 		}
-		err = w.Close()
 		if err != nil {
-			return err
+			_ = client.Close()
+			return nil, err
 		}
-		return nil
 	}
-	err = smtp.SendMail(addr, auth, global.SMTPAccount, to, mail)
-	if err != nil && strings.Contains(err.Error(), "short response") { // 部分提供商返回该错误，但实际上邮件已经发送成功
-		logger.SysWarnf("short response from SMTP server, return nil instead of error: %s", err.Error())
-		return nil
+	if shouldAuth() {
+		// PlainAuth 拒绝在未加密的连接上向非本机的服务器发送密码
+		auth := smtp.PlainAuth("", global.SMTPAccount, global.SMTPToken, global.SMTPServer)
+		if err = client.Auth(auth); err != nil {
+			_ = client.Close()
+			return nil, err
+		}
 	}
-	return err
+	return client, nil
 }
@@ -1,10 +1,9 @@
 package message
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
-	"net/http"
 
 	"github.com/9688101/hx-admin/global"
 )
@@ -24,26 +23,32 @@ type response struct {
 }
 
 func SendMessage(title string, description string, content string) error {
-	if global.MessagePusherAddress == "" {
+	return sendMessage(context.Background(), "", "", title, description, content)
+}
+
+// sendMessage address 与 token 为空时使用系统设置中的值
+func sendMessage(ctx context.Context, address string, token string, title string, description string, content string) error {
+	if address == "" {
+		address = global.MessagePusherAddress
+	}
+	if token == "" {
+		token = global.MessagePusherToken
+	}
+	if address == "" {
 		return errors.New("message pusher address is not set")
 	}
 	req := request{
 		Title:       title,
 		Description: description,
 		Content:     content,
-		Token:       global.MessagePusherToken,
-	}
-	data, err := json.Marshal(req)
-	if err != nil {
-		return err
+		Token:       token,
 	}
-	resp, err := http.Post(global.MessagePusherAddress,
-		"application/json", bytes.NewBuffer(data))
+	body, err := postJSON(ctx, address, req, nil)
 	if err != nil {
 		return err
 	}
 	var res response
-	err = json.NewDecoder(resp.Body).Decode(&res)
+	err = json.Unmarshal(body, &res)
 	if err != nil {
 		return err
 	}
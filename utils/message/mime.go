@@ -0,0 +1,295 @@
+package message
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Attachment 邮件附件，ContentType 为空时根据文件名推断
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Message 一封待发送的邮件，HTML 与 Text 至少需要一个，同时存在时生成 multipart/alternative。
+// Bcc 中的收件人只在发送时使用，不会出现在邮件头中。
+type Message struct {
+	From        mail.Address
+	To          []string
+	Cc          []string
+	Bcc         []string
+	Subject     string
+	HTML        string
+	Text        string
+	Attachments []*Attachment
+}
+
+// header 邮件头，按添加的顺序输出，DKIM 签名时需要按名称取值
+type header struct {
+	name  string
+	value string
+}
+
+// Recipients 返回所有收件人的地址，去除重复的地址
+func (m *Message) Recipients() ([]string, error) {
+	var recipients []string
+	seen := make(map[string]bool)
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		addresses, err := parseAddresses(list)
+		if err != nil {
+			return nil, err
+		}
+		for _, address := range addresses {
+			key := strings.ToLower(address.Address)
+			if !seen[key] {
+				seen[key] = true
+				recipients = append(recipients, address.Address)
+			}
+		}
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New("receiver is empty")
+	}
+	return recipients, nil
+}
+
+func parseAddresses(list []string) ([]*mail.Address, error) {
+	addresses := make([]*mail.Address, 0, len(list))
+	for _, s := range list {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		address, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid email address %q: %w", s, err)
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+func formatAddresses(addresses []*mail.Address) string {
+	formatted := make([]string, len(addresses))
+	for i, address := range addresses {
+		formatted[i] = address.String()
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// build 生成邮件头与正文，正文的换行统一为 CRLF
+func (m *Message) build() ([]header, []byte, error) {
+	if m.HTML == "" && m.Text == "" {
+		return nil, nil, errors.New("email content is empty")
+	}
+	if m.From.Address == "" {
+		return nil, nil, errors.New("sender is empty")
+	}
+	if _, err := m.Recipients(); err != nil {
+		return nil, nil, err
+	}
+	to, err := parseAddresses(m.To)
+	if err != nil {
+		return nil, nil, err
+	}
+	cc, err := parseAddresses(m.Cc)
+	if err != nil {
+		return nil, nil, err
+	}
+	messageId, err := newMessageId(m.From.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	headers := []header{
+		{"From", m.From.String()},
+	}
+	if len(to) > 0 {
+		headers = append(headers, header{"To", formatAddresses(to)})
+	}
+	if len(cc) > 0 {
+		headers = append(headers, header{"Cc", formatAddresses(cc)})
+	}
+	headers = append(headers,
+		header{"Subject", mime.BEncoding.Encode("UTF-8", m.Subject)},
+		header{"Date", time.Now().Format(time.RFC1123Z)},
+		header{"Message-ID", messageId}, // add Message-ID header to avoid being treated as spam, RFC 5322
+		header{"MIME-Version", "1.0"},
+	)
+
+	var body bytes.Buffer
+	contentType, err := m.writeContent(&body)
+	if err != nil {
+		return nil, nil, err
+	}
+	headers = append(headers, header{"Content-Type", contentType})
+	if !strings.HasPrefix(contentType, "multipart/") {
+		headers = append(headers, header{"Content-Transfer-Encoding", "quoted-printable"})
+	}
+	return headers, body.Bytes(), nil
+}
+
+// writeContent 写入正文并返回正文的 Content-Type，有附件时外层为 multipart/mixed
+func (m *Message) writeContent(w *bytes.Buffer) (string, error) {
+	if len(m.Attachments) == 0 {
+		return m.writeAlternative(w)
+	}
+	writer := multipart.NewWriter(w)
+	var alternative bytes.Buffer
+	contentType, err := m.writeAlternative(&alternative)
+	if err != nil {
+		return "", err
+	}
+	partHeader := textproto.MIMEHeader{"Content-Type": {contentType}}
+	if !strings.HasPrefix(contentType, "multipart/") {
+		partHeader.Set("Content-Transfer-Encoding", "quoted-printable")
+	}
+	part, err := writer.CreatePart(partHeader)
+	if err != nil {
+		return "", err
+	}
+	if _, err = part.Write(alternative.Bytes()); err != nil {
+		return "", err
+	}
+	for _, attachment := range m.Attachments {
+		if err = writeAttachment(writer, attachment); err != nil {
+			return "", err
+		}
+	}
+	if err = writer.Close(); err != nil {
+		return "", err
+	}
+	return "multipart/mixed; boundary=" + writer.Boundary(), nil
+}
+
+// writeAlternative 只有一种内容时直接写入，否则生成 multipart/alternative，纯文本在前，HTML 在后
+func (m *Message) writeAlternative(w *bytes.Buffer) (string, error) {
+	if m.Text == "" {
+		return "text/html; charset=UTF-8", writeQuotedPrintable(w, m.HTML)
+	}
+	if m.HTML == "" {
+		return "text/plain; charset=UTF-8", writeQuotedPrintable(w, m.Text)
+	}
+	writer := multipart.NewWriter(w)
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=UTF-8", m.Text},
+		{"text/html; charset=UTF-8", m.HTML},
+	}
+	for _, p := range parts {
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return "", err
+		}
+		var buf bytes.Buffer
+		if err = writeQuotedPrintable(&buf, p.content); err != nil {
+			return "", err
+		}
+		if _, err = part.Write(buf.Bytes()); err != nil {
+			return "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return "multipart/alternative; boundary=" + writer.Boundary(), nil
+}
+
+func writeAttachment(writer *multipart.Writer, attachment *Attachment) error {
+	contentType := attachment.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(attachment.Filename))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(encodeBase64Lines(attachment.Data))
+	return err
+}
+
+// writeQuotedPrintable 将换行统一为 CRLF 后编码，quoted-printable 会保留 CRLF 换行
+func writeQuotedPrintable(w *bytes.Buffer, content string) error {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	content = strings.ReplaceAll(content, "\n", "\r\n")
+	writer := quotedprintable.NewWriter(w)
+	if _, err := writer.Write([]byte(content)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	w.WriteString("\r\n")
+	return nil
+}
+
+// encodeBase64Lines 按 RFC 2045 的要求每 76 个字符换行
+func encodeBase64Lines(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76])
+		buf.WriteString("\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+func newMessageId(from string) (string, error) {
+	var domain string
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("<%x@%s>", buf, domain), nil
+}
+
+// Bytes 生成完整的邮件，配置了 DKIM 时添加 DKIM-Signature
+func (m *Message) Bytes() ([]byte, error) {
+	headers, body, err := m.build()
+	if err != nil {
+		return nil, err
+	}
+	if signer := getDKIMSigner(); signer != nil {
+		signature, err := signer.sign(headers, body)
+		if err != nil {
+			return nil, err
+		}
+		headers = append([]header{{"DKIM-Signature", signature}}, headers...)
+	}
+	var buf bytes.Buffer
+	for _, h := range headers {
+		buf.WriteString(h.name + ": " + h.value + "\r\n")
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	return buf.Bytes(), nil
+}
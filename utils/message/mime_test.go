@@ -0,0 +1,136 @@
+package message
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func readPart(part *multipart.Part) string {
+	data, err := io.ReadAll(part)
+	So(err, ShouldBeNil)
+	return string(data)
+}
+
+func TestMessage(t *testing.T) {
+	Convey("TestMessage", t, func() {
+		msg := &Message{
+			From:        mail.Address{Name: "系统", Address: "noreply@example.com"},
+			To:          []string{"a@example.com", "张三 <b@example.com>"},
+			Cc:          []string{"c@example.com"},
+			Bcc:         []string{"d@example.com", "A@example.com"},
+			Subject:     "验证码 123456",
+			HTML:        "<p>你好</p>",
+			Text:        "你好",
+			Attachments: []*Attachment{{Filename: "报告.txt", Data: []byte("hello")}},
+		}
+		recipients, err := msg.Recipients()
+		So(err, ShouldBeNil)
+		So(recipients, ShouldResemble, []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com"})
+
+		data, err := msg.Bytes()
+		So(err, ShouldBeNil)
+		parsed, err := mail.ReadMessage(bytes.NewReader(data))
+		So(err, ShouldBeNil)
+		subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+		So(err, ShouldBeNil)
+		So(subject, ShouldEqual, "验证码 123456")
+		to, err := parsed.Header.AddressList("To")
+		So(err, ShouldBeNil)
+		So(to[1].Name, ShouldEqual, "张三")
+		So(parsed.Header.Get("Cc"), ShouldEqual, "<c@example.com>")
+		So(parsed.Header.Get("Bcc"), ShouldBeEmpty)
+
+		mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+		So(err, ShouldBeNil)
+		So(mediaType, ShouldEqual, "multipart/mixed")
+		mixed := multipart.NewReader(parsed.Body, params["boundary"])
+		part, err := mixed.NextPart()
+		So(err, ShouldBeNil)
+		mediaType, params, err = mime.ParseMediaType(part.Header.Get("Content-Type"))
+		So(err, ShouldBeNil)
+		So(mediaType, ShouldEqual, "multipart/alternative")
+		alternative := multipart.NewReader(part, params["boundary"])
+		text, err := alternative.NextPart()
+		So(err, ShouldBeNil)
+		So(text.Header.Get("Content-Type"), ShouldStartWith, "text/plain")
+		So(readPart(text), ShouldEqual, "你好\r\n")
+		html, err := alternative.NextPart()
+		So(err, ShouldBeNil)
+		So(html.Header.Get("Content-Type"), ShouldStartWith, "text/html")
+		So(readPart(html), ShouldEqual, "<p>你好</p>\r\n")
+
+		attachment, err := mixed.NextPart()
+		So(err, ShouldBeNil)
+		So(attachment.FileName(), ShouldEqual, "报告.txt")
+		content, err := base64.StdEncoding.DecodeString(strings.TrimSpace(readPart(attachment)))
+		So(err, ShouldBeNil)
+		So(string(content), ShouldEqual, "hello")
+
+		_, err = (&Message{From: msg.From, To: []string{"not an address"}, Text: "x"}).Bytes()
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestDKIM(t *testing.T) {
+	Convey("TestDKIM", t, func() {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		So(err, ShouldBeNil)
+		keyFile := filepath.Join(t.TempDir(), "dkim.pem")
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		So(os.WriteFile(keyFile, keyPEM, 0600), ShouldBeNil)
+		So(InitDKIM("example.com", "mail", keyFile), ShouldBeNil)
+		defer InitDKIM("", "", "")
+
+		msg := &Message{
+			From:    mail.Address{Address: "noreply@example.com"},
+			To:      []string{"a@example.com"},
+			Subject: "hello",
+			Text:    "hello  world \n\n",
+		}
+		data, err := msg.Bytes()
+		So(err, ShouldBeNil)
+		parsed, err := mail.ReadMessage(bytes.NewReader(data))
+		So(err, ShouldBeNil)
+		signature := parsed.Header.Get("DKIM-Signature")
+		So(signature, ShouldContainSubstring, "d=example.com; s=mail;")
+
+		// 按 RFC 6376 重新计算摘要并用公钥校验
+		tags := make(map[string]string)
+		for _, tag := range strings.Split(signature, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(tag), "=")
+			tags[name] = value
+		}
+		body, err := io.ReadAll(parsed.Body)
+		So(err, ShouldBeNil)
+		bodyHash := sha256.Sum256(dkimRelaxedBody(body))
+		So(tags["bh"], ShouldEqual, base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+		hash := sha256.New()
+		for _, name := range strings.Split(tags["h"], ":") {
+			if value := parsed.Header.Get(name); value != "" {
+				hash.Write([]byte(dkimRelaxedHeader(name, value) + "\r\n"))
+			}
+		}
+		unsigned := signature[:strings.LastIndex(signature, "b=")+2]
+		hash.Write([]byte(dkimRelaxedHeader("DKIM-Signature", unsigned)))
+		b, err := base64.StdEncoding.DecodeString(tags["b"])
+		So(err, ShouldBeNil)
+		So(rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash.Sum(nil), b), ShouldBeNil)
+	})
+}
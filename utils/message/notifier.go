@@ -0,0 +1,280 @@
+package message
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/9688101/hx-admin/core/tracing"
+)
+
+// Notification 发送给通知渠道的消息，Content 为纯文本
+type Notification struct {
+	Event   string `json:"event"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Time    int64  `json:"time"`
+}
+
+func (n *Notification) text() string {
+	if n.Content == "" {
+		return n.Title
+	}
+	return n.Title + "\n\n" + n.Content
+}
+
+// Notifier 通知渠道，Notify 只发送一次，由调用方决定是否重试
+type Notifier interface {
+	Notify(ctx context.Context, notification *Notification) error
+}
+
+// notifierHTTPClient 通知渠道使用的 HTTP 客户端，设置超时避免对方无响应时一直等待
+var notifierHTTPClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: tracing.NewTransport(nil),
+}
+
+// postJSON 发送 JSON 请求，返回 2xx 响应的响应体
+func postJSON(ctx context.Context, address string, payload any, header http.Header) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return postBody(ctx, address, data, header)
+}
+
+func postBody(ctx context.Context, address string, data []byte, header http.Header) ([]byte, error) {
+	statusCode, body, err := post(ctx, notifierHTTPClient, address, data, header)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode < 200 || statusCode >= 300 {
+		return nil, fmt.Errorf("unexpected status code %d: %s", statusCode, body)
+	}
+	return body, nil
+}
+
+// post 发送 JSON 请求，返回响应的状态码与最多 64KB 的响应体
+func post(ctx context.Context, client *http.Client, address string, data []byte, header http.Header) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address, bytes.NewReader(data))
+	if err != nil {
+		return 0, nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// SignWebhook 计算 webhook 的签名，签名内容为 "<timestamp>.<body>"，接收方应同时校验时间戳以防重放
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// setWebhookSignature 通过 X-Webhook-Timestamp 与 X-Webhook-Signature 请求头携带签名，secret 为空时不签名
+func setWebhookSignature(header http.Header, secret string, body []byte) {
+	if secret == "" {
+		return
+	}
+	timestamp := time.Now().Unix()
+	header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	header.Set("X-Webhook-Signature", SignWebhook(secret, timestamp, body))
+}
+
+// webhookHTTPClient 投递领域事件使用的 HTTP 客户端，超时由调用方的 ctx 控制
+var webhookHTTPClient = &http.Client{
+	Transport: tracing.NewTransport(nil),
+}
+
+// WebhookResponse webhook 接收方的响应，Body 最多保留 64KB
+type WebhookResponse struct {
+	StatusCode int
+	Body       []byte
+}
+
+// PostWebhook POST 已编码的 JSON 请求体并签名。收到响应时总是返回响应，状态码不是 2xx 时同时返回错误
+func PostWebhook(ctx context.Context, address string, secret string, header http.Header, body []byte) (*WebhookResponse, error) {
+	if header == nil {
+		header = http.Header{}
+	}
+	setWebhookSignature(header, secret, body)
+	statusCode, respBody, err := post(ctx, webhookHTTPClient, address, body, header)
+	if err != nil {
+		return nil, err
+	}
+	resp := &WebhookResponse{StatusCode: statusCode, Body: respBody}
+	if statusCode < 200 || statusCode >= 300 {
+		return resp, fmt.Errorf("unexpected status code %d", statusCode)
+	}
+	return resp, nil
+}
+
+// WebhookNotifier 以 JSON 格式 POST Notification，配置了 Secret 时通过
+// X-Webhook-Timestamp 与 X-Webhook-Signature 请求头携带签名
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+}
+
+func (w *WebhookNotifier) Notify(ctx context.Context, notification *Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	setWebhookSignature(header, w.Secret, body)
+	_, err = postBody(ctx, w.URL, body, header)
+	return err
+}
+
+// FeishuNotifier 飞书群机器人，Secret 为机器人的签名校验密钥
+type FeishuNotifier struct {
+	URL    string
+	Secret string
+}
+
+func (f *FeishuNotifier) Notify(ctx context.Context, notification *Notification) error {
+	payload := map[string]any{
+		"msg_type": "text",
+		"content":  map[string]string{"text": notification.text()},
+	}
+	if f.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		// 飞书以 timestamp + "\n" + secret 为密钥，对空字符串计算 HMAC
+		mac := hmac.New(sha256.New, []byte(timestamp+"\n"+f.Secret))
+		payload["timestamp"] = timestamp
+		payload["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	body, err := postJSON(ctx, f.URL, payload, nil)
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err = json.Unmarshal(body, &res); err != nil {
+		return err
+	}
+	if res.Code != 0 {
+		return fmt.Errorf("feishu error %d: %s", res.Code, res.Msg)
+	}
+	return nil
+}
+
+// DingTalkNotifier 钉钉群机器人，Secret 为加签密钥
+type DingTalkNotifier struct {
+	URL    string
+	Secret string
+}
+
+func (d *DingTalkNotifier) Notify(ctx context.Context, notification *Notification) error {
+	address := d.URL
+	if d.Secret != "" {
+		u, err := url.Parse(d.URL)
+		if err != nil {
+			return err
+		}
+		timestamp := strconv.FormatInt(time.Now().UnixMilli(), 10)
+		mac := hmac.New(sha256.New, []byte(d.Secret))
+		mac.Write([]byte(timestamp + "\n" + d.Secret))
+		query := u.Query()
+		query.Set("timestamp", timestamp)
+		query.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+		u.RawQuery = query.Encode()
+		address = u.String()
+	}
+	return postRobotText(ctx, address, notification, "dingtalk")
+}
+
+// WeComNotifier 企业微信群机器人
+type WeComNotifier struct {
+	URL string
+}
+
+func (w *WeComNotifier) Notify(ctx context.Context, notification *Notification) error {
+	return postRobotText(ctx, w.URL, notification, "wecom")
+}
+
+// postRobotText 钉钉与企业微信的机器人使用相同格式的文本消息与响应
+func postRobotText(ctx context.Context, address string, notification *Notification, name string) error {
+	payload := map[string]any{
+		"msgtype": "text",
+		"text":    map[string]string{"content": notification.text()},
+	}
+	body, err := postJSON(ctx, address, payload, nil)
+	if err != nil {
+		return err
+	}
+	var res struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err = json.Unmarshal(body, &res); err != nil {
+		return err
+	}
+	if res.ErrCode != 0 {
+		return fmt.Errorf("%s error %d: %s", name, res.ErrCode, res.ErrMsg)
+	}
+	return nil
+}
+
+// SlackNotifier Slack 的 Incoming Webhook，也适用于 Mattermost、Discord（/slack 地址）等兼容的服务
+type SlackNotifier struct {
+	URL string
+}
+
+func (s *SlackNotifier) Notify(ctx context.Context, notification *Notification) error {
+	text := "*" + notification.Title + "*"
+	if notification.Content != "" {
+		text += "\n" + notification.Content
+	}
+	_, err := postJSON(ctx, s.URL, map[string]string{"text": text}, nil)
+	return err
+}
+
+// EmailNotifier 直接通过 SMTP 发送纯文本邮件，不经过发件队列
+type EmailNotifier struct {
+	Receivers []string
+}
+
+func (e *EmailNotifier) Notify(ctx context.Context, notification *Notification) error {
+	return Send(&Message{
+		To:      e.Receivers,
+		Subject: notification.Title,
+		Text:    notification.text(),
+	})
+}
+
+// MessagePusherNotifier Message Pusher，URL 与 Token 为空时使用系统设置中的 MessagePusherAddress 与 MessagePusherToken
+type MessagePusherNotifier struct {
+	URL   string
+	Token string
+}
+
+func (m *MessagePusherNotifier) Notify(ctx context.Context, notification *Notification) error {
+	return sendMessage(ctx, m.URL, m.Token, notification.Title, notification.Title, notification.Content)
+}
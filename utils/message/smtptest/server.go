@@ -0,0 +1,172 @@
+// Package smtptest 提供进程内的 SMTP 服务器，记录收到的邮件，仅用于测试
+package smtptest
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Message 服务器收到的一封邮件
+type Message struct {
+	From string
+	To   []string
+	Data string // 包括邮件头在内的完整内容
+}
+
+// Server 只实现发送邮件需要的命令，接受任意 AUTH PLAIN 凭据，不支持 STARTTLS
+type Server struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	messages []*Message
+	failures int
+	wg       sync.WaitGroup
+}
+
+// NewServer 在 127.0.0.1 的随机端口上启动服务器，使用完毕后需要调用 Close
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{listener: listener}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *Server) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Messages 返回已收到的邮件
+func (s *Server) Messages() []*Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*Message(nil), s.messages...)
+}
+
+// FailNext 使接下来的 n 次发送在 MAIL 命令时返回临时错误
+func (s *Server) FailNext(n int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = n
+}
+
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) shouldFail() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return true
+	}
+	return false
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := textproto.NewReader(bufio.NewReader(conn))
+	w := textproto.NewWriter(bufio.NewWriter(conn))
+	reply := func(lines ...string) bool {
+		return w.PrintfLine("%s", strings.Join(lines, "\r\n")) == nil
+	}
+	if !reply("220 smtptest ESMTP") {
+		return
+	}
+	var message *Message
+	for {
+		line, err := r.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		ok := true
+		switch strings.ToUpper(command) {
+		case "EHLO":
+			ok = reply("250-smtptest", "250-8BITMIME", "250 AUTH PLAIN")
+		case "HELO", "NOOP":
+			ok = reply("250 OK")
+		case "AUTH":
+			ok = reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			if s.shouldFail() {
+				ok = reply("451 4.3.0 Try again later")
+				continue
+			}
+			message = &Message{From: address(arg)}
+			ok = reply("250 OK")
+		case "RCPT":
+			if message == nil {
+				ok = reply("503 5.5.1 MAIL first")
+				continue
+			}
+			message.To = append(message.To, address(arg))
+			ok = reply("250 OK")
+		case "DATA":
+			if message == nil || len(message.To) == 0 {
+				ok = reply("503 5.5.1 RCPT first")
+				continue
+			}
+			if !reply("354 End data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			data, err := io.ReadAll(r.DotReader())
+			if err != nil {
+				return
+			}
+			message.Data = string(data)
+			s.mutex.Lock()
+			s.messages = append(s.messages, message)
+			s.mutex.Unlock()
+			message = nil
+			ok = reply("250 OK: queued as " + strconv.Itoa(len(s.Messages())))
+		case "RSET":
+			message = nil
+			ok = reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			ok = reply("502 5.5.2 Command not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+// address 从 FROM:<a@b.c> 或 TO:<a@b.c> 中取出邮件地址
+func address(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(strings.TrimSpace(addr), " ")
+	return strings.Trim(addr, "<>")
+}
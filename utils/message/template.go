@@ -1,34 +1,215 @@
 package message
 
 import (
+	"bytes"
+	"embed"
 	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"maps"
+	"strings"
+	texttemplate "text/template"
 
 	"github.com/9688101/hx-admin/global"
+	"github.com/9688101/hx-admin/utils"
 )
 
-// EmailTemplate 生成美观的 HTML 邮件内容
-func EmailTemplate(title, content string) string {
-	return fmt.Sprintf(`
-<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-</head>
-<body style="margin: 0; padding: 20px; font-family: Arial, sans-serif; line-height: 1.6; background-color: #f4f4f4;">
-    <div style="max-width: 600px; margin: 20px auto; padding: 30px; background-color: #ffffff; border-radius: 8px; box-shadow: 0 2px 4px rgba(0, 0, 0, 0.1);">
-        <div style="text-align: center; margin-bottom: 30px;">
-            <h2 style="color: #333; margin: 0; font-size: 24px;">%s</h2>
-        </div>
-        <div style="color: #555; font-size: 16px;">
-            %s
-        </div>
-        <div style="margin-top: 40px; padding-top: 20px; border-top: 1px solid #eee; color: #888; font-size: 14px; text-align: center;">
-            <p style="margin: 5px 0;">此邮件由系统自动发送，请勿直接回复</p>
-            <p style="margin: 5px 0;">%s</p>
-        </div>
-    </div>
-</body>
-</html>
-`, title, content, global.SystemName)
+// 内置的邮件模板，每个事件每种语言一个文件，文件中需要定义 subject、content 与 text 三个模板，
+// content 为 HTML 正文，会被放入同一语言的 layout 中；subject 与 text 为纯文本，不做 HTML 转义。
+//
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+const (
+	EmailEventVerification  = "verification"
+	EmailEventPasswordReset = "password_reset"
+	EmailEventQuotaWarning  = "quota_warning"
+	EmailEventLoginAlert    = "login_alert"
+)
+
+// DefaultEmailLanguage 没有对应语言的模板时使用的语言，与 i18n 的默认语言一致
+const DefaultEmailLanguage = "en"
+
+// EmailLanguages 内置模板支持的语言
+var EmailLanguages = []string{"zh-CN", "en"}
+
+// EmailEvent 邮件事件，SampleData 为预览模板时使用的示例数据，同时列出了模板可以使用的字段
+type EmailEvent struct {
+	Name       string         `json:"name"`
+	SampleData map[string]any `json:"sample_data"`
+}
+
+var EmailEvents = []*EmailEvent{
+	{
+		Name: EmailEventVerification,
+		SampleData: map[string]any{
+			"Code":         "123456",
+			"ValidMinutes": utils.VerificationValidMinutes,
+		},
+	},
+	{
+		Name: EmailEventPasswordReset,
+		SampleData: map[string]any{
+			"Link":         "https://example.com/user/reset?email=someone@example.com&token=abcdef",
+			"ValidMinutes": utils.VerificationValidMinutes,
+		},
+	},
+	{
+		Name: EmailEventQuotaWarning,
+		SampleData: map[string]any{
+			"Username":  "someone",
+			"Quota":     1000,
+			"Exhausted": false,
+			"TopUpLink": "https://example.com/topup",
+		},
+	},
+	{
+		Name: EmailEventLoginAlert,
+		SampleData: map[string]any{
+			"Username":  "someone",
+			"Time":      "2024-01-01 12:00:00",
+			"IP":        "203.0.113.1",
+			"UserAgent": "Mozilla/5.0",
+		},
+	},
+}
+
+// Email 渲染后的邮件，Text 为纯文本的备选内容
+type Email struct {
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+func GetEmailEvent(name string) *EmailEvent {
+	for _, event := range EmailEvents {
+		if event.Name == name {
+			return event
+		}
+	}
+	return nil
+}
+
+func IsEmailLanguage(lang string) bool {
+	for _, l := range EmailLanguages {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchEmailLanguage 返回与 lang（语言代码或 Accept-Language 的值）最接近的模板语言
+func MatchEmailLanguage(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	for _, l := range EmailLanguages {
+		if strings.ToLower(l) == lang {
+			return l
+		}
+	}
+	if strings.HasPrefix(lang, "zh") {
+		return "zh-CN"
+	}
+	return DefaultEmailLanguage
+}
+
+// EmailTemplateOptionKey 管理员修改后的模板保存在该配置项中，值为空时使用内置模板
+func EmailTemplateOptionKey(event string, lang string) string {
+	return "EmailTemplate." + event + "." + lang
+}
+
+// ParseEmailTemplateOptionKey 从配置项名称中解析出事件与语言
+func ParseEmailTemplateOptionKey(key string) (event string, lang string, ok bool) {
+	rest, ok := strings.CutPrefix(key, "EmailTemplate.")
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ".")
+}
+
+func DefaultEmailTemplate(event string, lang string) (string, error) {
+	data, err := templatesFS.ReadFile("templates/" + event + "." + lang + ".html")
+	if err != nil {
+		return "", fmt.Errorf("邮件模板 %s.%s 不存在", event, lang)
+	}
+	return string(data), nil
+}
+
+// EmailTemplateSource 返回事件当前使用的模板，customized 表示是否为管理员修改后的模板
+func EmailTemplateSource(event string, lang string) (source string, customized bool, err error) {
+	global.OptionMapRWMutex.RLock()
+	source = global.OptionMap[EmailTemplateOptionKey(event, lang)]
+	global.OptionMapRWMutex.RUnlock()
+	if source != "" {
+		return source, true, nil
+	}
+	source, err = DefaultEmailTemplate(event, lang)
+	return source, false, err
+}
+
+// RenderEmail 使用与 lang 最接近的语言的模板渲染事件的邮件
+func RenderEmail(event string, lang string, data map[string]any) (*Email, error) {
+	lang = MatchEmailLanguage(lang)
+	source, _, err := EmailTemplateSource(event, lang)
+	if err != nil {
+		return nil, err
+	}
+	return RenderEmailTemplate(source, lang, data)
+}
+
+// RenderEmailTemplate 渲染模板 source，模板中可以使用 SystemName、ServerAddress 以及 data 中的字段，
+// 引用不存在的字段会返回错误，layout 中还可以使用渲染后的 Subject
+func RenderEmailTemplate(source string, lang string, data map[string]any) (*Email, error) {
+	layout, err := DefaultEmailTemplate("layout", lang)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]any, len(data)+3)
+	maps.Copy(values, data)
+	values["SystemName"] = global.SystemName
+	values["ServerAddress"] = global.ServerAddress
+
+	text, err := texttemplate.New("email").Option("missingkey=error").Parse(layout)
+	if err == nil {
+		_, err = text.Parse(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("邮件模板解析失败：%w", err)
+	}
+	for _, name := range []string{"subject", "content", "text"} {
+		if text.Lookup(name) == nil {
+			return nil, fmt.Errorf("邮件模板缺少 %s 模板", name)
+		}
+	}
+	email := &Email{}
+	subject, err := executeTemplate(text.ExecuteTemplate, "subject", values)
+	if err != nil {
+		return nil, err
+	}
+	// 标题中不能出现换行，否则会破坏邮件头
+	email.Subject = strings.Join(strings.Fields(subject), " ")
+	values["Subject"] = email.Subject
+	if email.Text, err = executeTemplate(text.ExecuteTemplate, "text", values); err != nil {
+		return nil, err
+	}
+
+	html, err := htmltemplate.New("email").Option("missingkey=error").Parse(layout)
+	if err == nil {
+		_, err = html.Parse(source)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("邮件模板解析失败：%w", err)
+	}
+	if email.HTML, err = executeTemplate(html.ExecuteTemplate, "layout", values); err != nil {
+		return nil, err
+	}
+	return email, nil
+}
+
+func executeTemplate(execute func(w io.Writer, name string, data any) error, name string, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := execute(&buf, name, data); err != nil {
+		return "", fmt.Errorf("邮件模板渲染失败：%w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
 }
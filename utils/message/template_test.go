@@ -0,0 +1,38 @@
+package message
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRenderEmailTemplate(t *testing.T) {
+	Convey("TestRenderEmailTemplate", t, func() {
+		for _, event := range EmailEvents {
+			for _, lang := range EmailLanguages {
+				email, err := RenderEmail(event.Name, lang, event.SampleData)
+				So(err, ShouldBeNil)
+				So(email.Subject, ShouldNotBeEmpty)
+				So(email.HTML, ShouldStartWith, "<!DOCTYPE html>")
+				So(email.Text, ShouldNotBeEmpty)
+			}
+		}
+
+		So(MatchEmailLanguage("zh-TW"), ShouldEqual, "zh-CN")
+		So(MatchEmailLanguage("fr"), ShouldEqual, DefaultEmailLanguage)
+
+		source := `{{define "subject"}}Hi
+{{.Name}}{{end}}{{define "content"}}<p>{{.Name}}</p>{{end}}{{define "text"}}{{.Name}}{{end}}`
+		email, err := RenderEmailTemplate(source, "en", map[string]any{"Name": "<b>&"})
+		So(err, ShouldBeNil)
+		So(email.Subject, ShouldEqual, "Hi <b>&")
+		So(email.Text, ShouldEqual, "<b>&")
+		So(strings.Contains(email.HTML, "<p>&lt;b&gt;&amp;</p>"), ShouldBeTrue)
+
+		_, err = RenderEmailTemplate(source, "en", nil)
+		So(err, ShouldNotBeNil)
+		_, err = RenderEmailTemplate(`{{define "subject"}}x{{end}}`, "en", nil)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -1,70 +1,138 @@
 package utils
 
 import (
+	"hash/fnv"
+	"math"
 	"sync"
 	"time"
 )
 
+// rateLimitShards 分段锁的段数，不同 key 的请求大多落在不同的段上，互不阻塞
+const rateLimitShards = 64
+
+// RateLimitResult 一次令牌桶请求的结果，用于设置 X-RateLimit-* 与 Retry-After 响应头
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // 令牌桶恢复满所需的时间
+	RetryAfter time.Duration // 被拒绝时距离下一个令牌产生的时间
+}
+
+// RateLimitBucket 令牌桶的状态，仅用于诊断
+type RateLimitBucket struct {
+	Tokens    float64 `json:"tokens"`
+	UpdatedAt int64   `json:"updated_at"` // 毫秒时间戳
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt int64 // 毫秒
+	expireAt  int64 // 毫秒，桶在此之后已恢复满，可以删除
+}
+
+type rateLimitShard struct {
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+// InMemoryRateLimiter 单节点的令牌桶限流器，与 Redis 中的 Lua 脚本使用相同的算法
 type InMemoryRateLimiter struct {
-	store              map[string]*[]int64
-	mutex              sync.Mutex
-	expirationDuration time.Duration
+	shards   [rateLimitShards]rateLimitShard
+	initOnce sync.Once
 }
 
 func (l *InMemoryRateLimiter) Init(expirationDuration time.Duration) {
-	if l.store == nil {
-		l.mutex.Lock()
-		if l.store == nil {
-			l.store = make(map[string]*[]int64)
-			l.expirationDuration = expirationDuration
-			if expirationDuration > 0 {
-				go l.clearExpiredItems()
-			}
+	l.initOnce.Do(func() {
+		for i := range l.shards {
+			l.shards[i].buckets = make(map[string]*tokenBucket)
 		}
-		l.mutex.Unlock()
-	}
+		if expirationDuration > 0 {
+			go l.clearExpiredItems(expirationDuration)
+		}
+	})
 }
 
-func (l *InMemoryRateLimiter) clearExpiredItems() {
+func (l *InMemoryRateLimiter) clearExpiredItems(interval time.Duration) {
 	for {
-		time.Sleep(l.expirationDuration)
-		l.mutex.Lock()
-		now := time.Now().Unix()
-		for key := range l.store {
-			queue := l.store[key]
-			size := len(*queue)
-			if size == 0 || now-(*queue)[size-1] > int64(l.expirationDuration.Seconds()) {
-				delete(l.store, key)
+		time.Sleep(interval)
+		now := time.Now().UnixMilli()
+		for i := range l.shards {
+			shard := &l.shards[i]
+			shard.mutex.Lock()
+			for key, bucket := range shard.buckets {
+				if now >= bucket.expireAt {
+					delete(shard.buckets, key)
+				}
 			}
+			shard.mutex.Unlock()
 		}
-		l.mutex.Unlock()
 	}
 }
 
-// Request parameter duration's unit is seconds
-func (l *InMemoryRateLimiter) Request(key string, maxRequestNum int, duration int64) bool {
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
-	// [old <-- new]
-	queue, ok := l.store[key]
-	now := time.Now().Unix()
-	if ok {
-		if len(*queue) < maxRequestNum {
-			*queue = append(*queue, now)
-			return true
-		} else {
-			if now-(*queue)[0] >= duration {
-				*queue = (*queue)[1:]
-				*queue = append(*queue, now)
-				return true
-			} else {
-				return false
-			}
+func (l *InMemoryRateLimiter) shard(key string) *rateLimitShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return &l.shards[h.Sum32()%rateLimitShards]
+}
+
+// Request 从 key 对应的令牌桶中取一个令牌，桶的容量为 maxRequestNum，每 duration 秒恢复满
+func (l *InMemoryRateLimiter) Request(key string, maxRequestNum int, duration int64) RateLimitResult {
+	shard := l.shard(key)
+	now := time.Now().UnixMilli()
+	rate := TokenRefillRate(maxRequestNum, duration)
+
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	bucket, ok := shard.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(maxRequestNum), updatedAt: now}
+		shard.buckets[key] = bucket
+	}
+	if now > bucket.updatedAt {
+		bucket.tokens = math.Min(float64(maxRequestNum), bucket.tokens+float64(now-bucket.updatedAt)*rate)
+		bucket.updatedAt = now
+	}
+	allowed := bucket.tokens >= 1
+	if allowed {
+		bucket.tokens--
+	}
+	bucket.expireAt = now + duration*1000
+	return NewRateLimitResult(allowed, bucket.tokens, maxRequestNum, rate)
+}
+
+// Snapshot returns a copy of every bucket, for diagnostics only
+func (l *InMemoryRateLimiter) Snapshot() map[string]RateLimitBucket {
+	snapshot := make(map[string]RateLimitBucket)
+	for i := range l.shards {
+		shard := &l.shards[i]
+		shard.mutex.Lock()
+		for key, bucket := range shard.buckets {
+			snapshot[key] = RateLimitBucket{Tokens: bucket.tokens, UpdatedAt: bucket.updatedAt}
 		}
-	} else {
-		s := make([]int64, 0, maxRequestNum)
-		l.store[key] = &s
-		*(l.store[key]) = append(*(l.store[key]), now)
+		shard.mutex.Unlock()
+	}
+	return snapshot
+}
+
+// TokenRefillRate 返回每毫秒恢复的令牌数
+func TokenRefillRate(maxRequestNum int, duration int64) float64 {
+	if duration <= 0 {
+		duration = 1
+	}
+	return float64(maxRequestNum) / float64(duration*1000)
+}
+
+// NewRateLimitResult 根据取令牌后桶中剩余的令牌数计算响应头需要的信息
+func NewRateLimitResult(allowed bool, tokens float64, maxRequestNum int, rate float64) RateLimitResult {
+	result := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     maxRequestNum,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration(math.Ceil((float64(maxRequestNum)-tokens)/rate)) * time.Millisecond,
+	}
+	if !allowed {
+		result.RetryAfter = time.Duration(math.Ceil((1-tokens)/rate)) * time.Millisecond
 	}
-	return true
+	return result
 }
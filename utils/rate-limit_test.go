@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInMemoryRateLimiter(t *testing.T) {
+	Convey("TestInMemoryRateLimiter", t, func() {
+		var l InMemoryRateLimiter
+		l.Init(0)
+
+		for i := 0; i < 3; i++ {
+			result := l.Request("a", 3, 60)
+			So(result.Allowed, ShouldBeTrue)
+			So(result.Remaining, ShouldEqual, 2-i)
+		}
+		result := l.Request("a", 3, 60)
+		So(result.Allowed, ShouldBeFalse)
+		So(result.Limit, ShouldEqual, 3)
+		So(result.RetryAfter, ShouldBeGreaterThan, 19*time.Second)
+		So(result.RetryAfter, ShouldBeLessThanOrEqualTo, 20*time.Second)
+		So(result.Reset, ShouldBeLessThanOrEqualTo, 60*time.Second)
+
+		// 不同的 key 互不影响
+		So(l.Request("b", 3, 60).Allowed, ShouldBeTrue)
+
+		// 并发请求不会超过容量
+		var wg sync.WaitGroup
+		var mutex sync.Mutex
+		allowed := 0
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if l.Request("c", 10, 3600).Allowed {
+					mutex.Lock()
+					allowed++
+					mutex.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		So(allowed, ShouldEqual, 10)
+	})
+}
@@ -24,6 +24,17 @@ func ObjectData(c *gin.Context, object interface{}) error {
 	return nil
 }
 
+// EventData 推送一个带事件名的 SSE 消息，object 编码为 JSON
+func EventData(c *gin.Context, event string, object interface{}) error {
+	jsonData, err := json.Marshal(object)
+	if err != nil {
+		return fmt.Errorf("error marshalling object: %w", err)
+	}
+	c.Render(-1, CustomEvent{Event: event, Data: "data: " + string(jsonData)})
+	c.Writer.Flush()
+	return nil
+}
+
 func Done(c *gin.Context) {
 	StringData(c, "[DONE]")
 }
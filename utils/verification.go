@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"crypto/subtle"
 	"strings"
 	"sync"
 	"time"
@@ -9,8 +10,9 @@ import (
 )
 
 type verificationValue struct {
-	code string
-	time time.Time
+	code     string
+	time     time.Time
+	attempts int
 }
 
 const (
@@ -22,6 +24,7 @@ var verificationMutex sync.Mutex
 var verificationMap map[string]verificationValue
 var verificationMapMaxSize = 10
 var VerificationValidMinutes = 10
+var VerificationMaxAttempts = 5 // 验证码输错该次数后失效，需要重新获取
 
 func GenerateVerificationCode(length int) string {
 	code := uuid.New().String()
@@ -52,7 +55,16 @@ func VerifyCodeWithKey(key string, code string, purpose string) bool {
 	if !okay || int(now.Sub(value.time).Seconds()) >= VerificationValidMinutes*60 {
 		return false
 	}
-	return code == value.code
+	if subtle.ConstantTimeCompare([]byte(code), []byte(value.code)) == 1 {
+		return true
+	}
+	value.attempts++
+	if value.attempts >= VerificationMaxAttempts {
+		delete(verificationMap, purpose+key)
+	} else {
+		verificationMap[purpose+key] = value
+	}
+	return false
 }
 
 func DeleteKey(key string, purpose string) {
@@ -76,3 +88,10 @@ func init() {
 	defer verificationMutex.Unlock()
 	verificationMap = make(map[string]verificationValue)
 }
+
+// VerificationCodeCount returns the number of codes kept in memory, including the expired ones not yet removed
+func VerificationCodeCount() int {
+	verificationMutex.Lock()
+	defer verificationMutex.Unlock()
+	return len(verificationMap)
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestVerificationAttempts(t *testing.T) {
+	Convey("TestVerificationAttempts", t, func() {
+		RegisterVerificationCodeWithKey("a@example.com", "123456", EmailVerificationPurpose)
+		So(VerifyCodeWithKey("a@example.com", "123456", PasswordResetPurpose), ShouldBeFalse)
+		So(VerifyCodeWithKey("a@example.com", "123456", EmailVerificationPurpose), ShouldBeTrue)
+
+		// 输错达到上限后正确的验证码也会失效
+		for i := 0; i < VerificationMaxAttempts; i++ {
+			So(VerifyCodeWithKey("a@example.com", "000000", EmailVerificationPurpose), ShouldBeFalse)
+		}
+		So(VerifyCodeWithKey("a@example.com", "123456", EmailVerificationPurpose), ShouldBeFalse)
+
+		// 重新获取后可以再次使用
+		RegisterVerificationCodeWithKey("a@example.com", "654321", EmailVerificationPurpose)
+		So(VerifyCodeWithKey("a@example.com", "654321", EmailVerificationPurpose), ShouldBeTrue)
+	})
+}
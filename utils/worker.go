@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// WorkerGroup runs background goroutines that share one context, so that they can be stopped together
+type WorkerGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewWorkerGroup() *WorkerGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WorkerGroup{ctx: ctx, cancel: cancel}
+}
+
+// Go starts f in a new goroutine, f should return once ctx is done
+func (g *WorkerGroup) Go(f func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		f(g.ctx)
+	}()
+}
+
+// Stop cancels the workers and waits for them to return, or until ctx is done
+func (g *WorkerGroup) Stop(ctx context.Context) error {
+	g.cancel()
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}